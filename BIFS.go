@@ -30,27 +30,51 @@ type Bank struct {
 // Kyc : this Struct store kyc data of customer
 //=============================================
 type Kyc struct {
-	ObjectType        string             `json:"doc_type"` //docType is used to distinguish the various types of objects in state database
-	CustID            string             `json:"cust_id"`
-	PersonalHash      string             `json:"personal_hash"`
-	PersonalKycStatus KycStatus          `json:"kyc_status"`
-	IsBlackList       bool               `json:"is_black_list"`
-	AgentID           string             `json:"agent_id"`
-	MSPID             string             `json:"msp_id"`
-	Accounts          map[string]Account `json:"account_number_with_name"`
+	ObjectType          string             `json:"doc_type"` //docType is used to distinguish the various types of objects in state database
+	CustID              string             `json:"cust_id"`
+	PersonalHash        string             `json:"personal_hash"`
+	PersonalKycStatus   KycStatus          `json:"kyc_status"`
+	IsBlackList         bool               `json:"is_black_list"`
+	AgentID             string             `json:"agent_id"`
+	MSPID               string             `json:"msp_id"`
+	Accounts            map[string]Account `json:"account_number_with_name"`
+	ApprovedAt          string             `json:"approved_at"`
+	RiskTier            Riskrating         `json:"risk_tier"`
+	NextReviewDate      string             `json:"next_review_date"`
+	Occupation          string             `json:"occupation"`
+	Country             string             `json:"country"`
+	IsPEP               bool               `json:"is_pep"`
+	RiskScore           int                `json:"risk_score"`
+	RiskLevel           Riskrating         `json:"risk_level"`
+	RiskFactors         []string           `json:"risk_factors"`
+	RiskScoredAt        string             `json:"risk_scored_at"`
+	IdentityFingerprint string             `json:"identity_fingerprint,omitempty"`
+	CreatedAt           string             `json:"created_at,omitempty"`
+	CreatedBy           string             `json:"created_by,omitempty"`
+	UpdatedAt           string             `json:"updated_at,omitempty"`
+	UpdatedBy           string             `json:"updated_by,omitempty"`
 }
 
 //=====================================================================
 //BranchAccount: this struct will store Account number and branch code
 //=====================================================================
 type Account struct {
-	ObjectType       string      `json:"doc_type"` //docType is used to distinguish the various types of objects in state database
-	AccountNumber    string      `json:"account_number"`
-	BranchCode       string      `json:"branch"`
-	OwnerName        string      `json: "owner_name"`
-	AccountType      AccountType `json:"account_type"`
-	AccountKycStatus KycStatus   `json:"kyc_status"`
-	BusinessHash     string      `json:"business_hash"`
+	ObjectType       string                `json:"doc_type"` //docType is used to distinguish the various types of objects in state database
+	AccountNumber    string                `json:"account_number"`
+	BranchCode       string                `json:"branch"`
+	OwnerName        string                `json: "owner_name"`
+	AccountType      AccountType           `json:"account_type"`
+	AccountKycStatus KycStatus             `json:"kyc_status"`
+	BusinessHash     string                `json:"business_hash"`
+	Owners           []AccountOwner        `json:"owners,omitempty"`
+	Mandate          SigningMandate        `json:"mandate,omitempty"`
+	EntityID         string                `json:"entity_id,omitempty"`
+	Status           AccountStatus         `json:"account_status,omitempty"`
+	StatusHistory    []AccountStatusChange `json:"status_history,omitempty"`
+	CreatedAt        string                `json:"created_at,omitempty"`
+	CreatedBy        string                `json:"created_by,omitempty"`
+	UpdatedAt        string                `json:"updated_at,omitempty"`
+	UpdatedBy        string                `json:"updated_by,omitempty"`
 }
 
 //================================================
@@ -65,43 +89,88 @@ type CertDetail struct {
 //Branch :
 //============================================
 type Branch struct {
-	BranchCode    string `json:"branch_code"`
-	BranchName    string `json:"branch_name"`
-	BranchAddress string `json:"branch_address"`
-	MSPID         string `json:"msp_id"`
+	BranchCode      string       `json:"branch_code"`
+	BranchName      string       `json:"branch_name"`
+	BranchAddress   string       `json:"branch_address"`
+	MSPID           string       `json:"msp_id"`
+	Code            string       `json:"code,omitempty"`
+	Status          BranchStatus `json:"branch_status,omitempty"`
+	StatusReason    string       `json:"status_reason,omitempty"`
+	StatusChangedAt string       `json:"status_changed_at,omitempty"`
+	ParentCode      string       `json:"parent_code,omitempty"`
+	Region          string       `json:"region,omitempty"`
+	CreatedAt       string       `json:"created_at,omitempty"`
+	CreatedBy       string       `json:"created_by,omitempty"`
+	UpdatedAt       string       `json:"updated_at,omitempty"`
+	UpdatedBy       string       `json:"updated_by,omitempty"`
 }
 
 //=============================================================
 //Transaction : this strut store data related to IR transaction
 //=============================================================
 type Transaction struct {
-	ObjectType         string            `json:"doc_type"` //docType is used to distinguish the various types of objects in state database
-	TransactionID      string            `json:'transaction_id'`
-	SenderAccount      string            `json:'sender_account'`
-	SenderName         string            `json:'sender_id'`
-	SenderBranchName   string            `json:'sender_branch_name'`
-	ReceiverAccount    string            `json:'receiver_account'`
-	ReceiverName       string            `json:'receiver_name'`
-	ReceiverBranchName string            `json:'receiver_branch_name'`
-	Created            string            `json:'created'`
-	Amount             float64           `json :'amount'`
-	Reference          string            `json:"reference"`
-	Purpose            string            `json:'purpose'`
-	TransactionStatus  TransactionStatus `json:'transaction_status'`
-	Comment            string            `json:'comment'`
-	Flagged            bool              `json:'flagged'`
-	DocHash            string            `json:'doc_hash'`
-	Riskrating         Riskrating        `json:'risk_rating'`
-	Crimerelated       string            `json:'crime_related'`
-	RecieverEDD        string            `json:'reciever_edd'`
+	ObjectType         string              `json:"doc_type"` //docType is used to distinguish the various types of objects in state database
+	TransactionID      string              `json:'transaction_id'`
+	SenderAccount      string              `json:'sender_account'`
+	SenderName         string              `json:'sender_id'`
+	SenderBranchName   string              `json:'sender_branch_name'`
+	ReceiverAccount    string              `json:'receiver_account'`
+	ReceiverName       string              `json:'receiver_name'`
+	ReceiverBranchName string              `json:'receiver_branch_name'`
+	Created            string              `json:'created'`
+	Amount             float64             `json :'amount'`
+	Reference          string              `json:"reference"`
+	Purpose            string              `json:'purpose'`
+	TransactionStatus  TransactionStatus   `json:'transaction_status'`
+	Comment            string              `json:'comment'`
+	Flagged            bool                `json:'flagged'`
+	DocHash            string              `json:'doc_hash'`
+	Riskrating         Riskrating          `json:'risk_rating'`
+	Crimerelated       string              `json:'crime_related'`
+	RecieverEDD        string              `json:'reciever_edd'`
+	Currency           string              `json:"currency"`
+	CreatedAt          string              `json:"created_at"`
+	RequiredSignatures int                 `json:"required_signatures,omitempty"`
+	Signatures         []TransferSignature `json:"signatures,omitempty"`
+	SignatureDeadline  string              `json:"signature_deadline,omitempty"`
+	PendingStatus      TransactionStatus   `json:"pending_status,omitempty"`
+	Legs               []TransferLeg       `json:"legs,omitempty"`
+	RoutingFees        float64             `json:"routing_fees,omitempty"`
+	ChargeBearer       ChargeBearer        `json:"charge_bearer,omitempty"`
+	Fees               []TransferFee       `json:"fees,omitempty"`
+	TotalFees          float64             `json:"total_fees,omitempty"`
+	TotalDebit         float64             `json:"total_debit,omitempty"`
+	ReceiverAmount     float64             `json:"receiver_amount,omitempty"`
+	BatchID            string              `json:"batch_id,omitempty"`
+	StageStatus        TransactionStatus   `json:"stage_status,omitempty"`
+	StatusChangedAt    string              `json:"status_changed_at,omitempty"`
+	ExpiredFrom        TransactionStatus   `json:"expired_from,omitempty"`
+	ValueDate          string              `json:"value_date,omitempty"`
+	CreatedBy          string              `json:"created_by,omitempty"`
+	UpdatedAt          string              `json:"updated_at,omitempty"`
+	UpdatedBy          string              `json:"updated_by,omitempty"`
+	Reversals          []TransferReversal  `json:"reversals,omitempty"`
+	RefundedAmount     float64             `json:"refunded_amount,omitempty"`
+	ReversalOf         string              `json:"reversal_of,omitempty"`
+	ReasonCode         string              `json:"reason_code,omitempty"`
+	DisputeID          string              `json:"dispute_id,omitempty"`
+	PayeeCheck         *PayeeCheck         `json:"payee_check,omitempty"`
 }
 
 //=====================================================================
 // AccountTypes: This Struct will store Account type with their Limites
 //=====================================================================
 type AccountType struct {
-	AccountTypeName string  `json: "account_type_name"`
-	Limit           float64 `json:"limit"`
+	AccountTypeName   string          `json: "account_type_name"`
+	Limit             float64         `json:"limit"`
+	Category          AccountCategory `json:"category,omitempty"`
+	MaxOwners         int             `json:"max_owners,omitempty"`
+	RequiredDocuments []string        `json:"required_documents,omitempty"`
+	AllowedCurrencies []string        `json:"allowed_currencies,omitempty"`
+	Version           int             `json:"version,omitempty"`
+	Deleted           bool            `json:"deleted,omitempty"`
+	UpdatedBy         string          `json:"updated_by,omitempty"`
+	UpdatedAt         string          `json:"updated_at,omitempty"`
 }
 
 //=====================================================================================================================================================
@@ -114,26 +183,24 @@ func (k *Kyc) setCustomerNameWithAccountNo(stub shim.ChaincodeStubInterface, acc
 	// if AccountWithNumber == nil {
 	// 	AccountWithNumber = make(map[string]string)
 	// }
-	accountType := AccountType{}
-	accountTypeAsBytes, err := stub.GetState(accType)
+	accountType, err := lookupAccountType(stub, accType)
 	if err != nil {
 		fmt.Println("Account Type Not Found")
-		return shim.Error(`{"status": 500 , "message": "Account Type Not Found ` + string(err.Error()) + `"}`), "Account Not Found", false
-	}
-	json.Unmarshal(accountTypeAsBytes, &accountType)
-	if accountType.AccountTypeName == "" {
-		return shim.Error(`{"status": 500 , "message": "Account Not Found "}`), "Account  Type Not Found", false
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`), err.Error(), false
 	}
 	mspID, err := cid.GetMSPID(stub)
 	if err != nil {
 		return shim.Error(""), "can not find mspID", false
 	}
-	branchAsBytes, _ := stub.GetState(mspID + "_" + branchCode)
+	branchAsBytes, _ := getBranchState(stub, mspID+"_"+branchCode)
 	branch := Branch{}
 	json.Unmarshal(branchAsBytes, &branch)
 	if branch.BranchCode == "" {
 		return shim.Error(`{"Status : 500 , "message": "Please Provide correct Branch Code :  ` + branchCode + `}`), "branch Not Found", false
 	}
+	if branch.status() == BRANCHCLOSED {
+		return shim.Error(`{"status": 403 , "message": "Branch ` + branchCode + ` is closed"}`), "Branch " + branchCode + " is closed", false
+	}
 
 	account := Account{
 		ObjectType:       "account",
@@ -144,25 +211,20 @@ func (k *Kyc) setCustomerNameWithAccountNo(stub shim.ChaincodeStubInterface, acc
 		BusinessHash:     buisenessHash,
 		AccountKycStatus: PENDING,
 	}
+	if account.CreatedAt, account.CreatedBy, err = auditStamp(stub); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`), err.Error(), false
+	}
 	k.Accounts[accNo] = account
 	fmt.Println(k.Accounts)
-	var AccountWithNumberFromState = make(map[string]string)
-	asBytes, _ := stub.GetState("accNo")
-	json.Unmarshal(asBytes, &AccountWithNumberFromState)
-	//if AccountWithNumber[accNo] == "" {
-	AccountWithNumberFromState[accNo] = accName + "," + k.CustID
-	// for k, v := range AccountWithNumberFromState {
-	// 	AccountWithNumber[k] = v
-	// }
-	ValAsBytes, _ := json.Marshal(AccountWithNumberFromState)
-	//mspID , _ := stub.GetMSPID()
-
-	err = stub.PutState("accNo", ValAsBytes)
+	err = putAccountIndex(stub, AccountIndexEntry{
+		AccountNumber: accNo,
+		OwnerName:     accName,
+		CustID:        k.CustID,
+		MSPID:         bankOfBranch(k.MSPID),
+	})
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`), "", false
 	}
-	//}
-	fmt.Printf(AccountWithNumberFromState[accNo])
 	return shim.Success(nil), "", true
 
 }
@@ -183,30 +245,81 @@ func (k *Kyc) setCustomerNameWithAccountNo(stub shim.ChaincodeStubInterface, acc
 
 // }
 
-//===============================================================
-//setBusinessAccount : this func will add business account with kyc
-//===============================================================
-func (k Kyc) setBuisenessAccount(stub shim.ChaincodeStubInterface, accNo string, accName string, branchCode string, accType string, buisenessHash string, businessAcc map[string]interface{}) (pb.Response, string, bool) {
-	businesAccountList := businessAcc["Customers"].([]interface{})
-	for _, value := range businesAccountList {
-		customer := value.(map[string]interface{})
-		valueAsBytes, _ := stub.GetState(customer["CustID"].(string))
+//=================================================================================
+//setBusinessAccount : this func will add joint or business account with kyc. Account
+//is stored once with its owners and mandate and every owner kyc keeps a reference
+//=================================================================================
+func (k *Kyc) setBuisenessAccount(stub shim.ChaincodeStubInterface, accNo string, accName string, branchCode string, accType string, buisenessHash string, request SharedAccountRequest) (pb.Response, string, bool) {
+	mandate, ok := parseMandate(request.Mandate)
+	if !ok {
+		return shim.Error(`{"status": 500 , "message": "Please Provide correct Mandate (any-one , any-two , all)"}`), "Please Provide correct Mandate (any-one , any-two , all)", false
+	}
+	owners := request.Customers
+	listed := false
+	for _, owner := range owners {
+		if owner.CustID == k.CustID {
+			listed = true
+		}
+	}
+	if !listed {
+		owners = append([]AccountOwner{{CustID: k.CustID, AccountName: accName, Role: SIGNATORY}}, owners...)
+	}
+	owners, err := validateOwners(owners, mandate)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + err.Error() + `"}`), err.Error(), false
+	}
+	accountType, err := lookupAccountType(stub, accType)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + err.Error() + `"}`), err.Error(), false
+	}
+	if accountType.MaxOwners > 0 && len(owners) > accountType.MaxOwners {
+		msg := "Account Type " + accountType.AccountTypeName + " allows at most " + strconv.Itoa(accountType.MaxOwners) + " owners"
+		return shim.Error(`{"status": 500 , "message": "` + msg + `"}`), msg, false
+	}
+	members := make(map[string]Kyc)
+	for _, owner := range owners {
+		if owner.CustID == k.CustID {
+			continue
+		}
+		valueAsBytes, _ := getKycState(stub, owner.CustID)
 		kyc := Kyc{}
 		json.Unmarshal(valueAsBytes, &kyc)
 		if kyc.CustID == "" {
-			str := `{"CustID Does not have Personal KYC     ` + customer["CustID"].(string) + `"}`
-			return shim.Error(str), "CustID Does Not Found   " + customer["CustID"].(string), false
+			str := `{"CustID Does not have Personal KYC     ` + owner.CustID + `"}`
+			return shim.Error(str), "CustID Does Not Found   " + owner.CustID, false
 
 		}
-		_, err1, isTrue := kyc.setCustomerNameWithAccountNo(stub, accNo, customer["AccountName"].(string), branchCode, accType, buisenessHash)
-		if isTrue != true {
-			return shim.Error(`{"` + err1 + `"}`), err1, false
+		if bankOfBranch(kyc.MSPID) != bankOfBranch(k.MSPID) {
+			msg := "Customer " + owner.CustID + " belongs to " + bankOfBranch(kyc.MSPID)
+			return shim.Error(`{"status": 403 , "message": "` + msg + `"}`), msg, false
+		}
+		members[owner.CustID] = kyc
+	}
+	_, err1, isTrue := k.setCustomerNameWithAccountNo(stub, accNo, accName, branchCode, accType, buisenessHash)
+	if isTrue != true {
+		return shim.Error(`{"` + err1 + `"}`), err1, false
+	}
+	account := k.Accounts[accNo]
+	account.ObjectType = sharedAccountType
+	account.Owners = owners
+	account.Mandate = mandate
+	if err := putAccount(stub, k, account); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + err.Error() + `"}`), err.Error(), false
+	}
+	for _, owner := range owners {
+		if owner.CustID == k.CustID {
+			k.Accounts[accNo] = accountReference(account, accName)
+			continue
+		}
+		kyc := members[owner.CustID]
+		if kyc.Accounts == nil {
+			kyc.Accounts = make(map[string]Account)
+		}
+		kyc.Accounts[accNo] = accountReference(account, owner.AccountName)
+		if err := putKyc(stub, kyc); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + err.Error() + `"}`), err.Error(), false
 		}
-		writeKycToLedger(stub, kyc)
-
 	}
-	// k.setCustomerNameWithAccountNo(stub, accNo, accName, branchCode, "Business", buisenessHash, branchName)
-	// writeKycToLedger(stub, k)
 
 	return shim.Success(nil), "", true
 }
@@ -214,18 +327,12 @@ func (k Kyc) setBuisenessAccount(stub shim.ChaincodeStubInterface, accNo string,
 // Checking whether the account exists in the ledger or not bassing account number
 
 func (b *Bank) IsAccountExists(stub shim.ChaincodeStubInterface, args string) (bool, string) {
-	var AccountFromState = make(map[string]string)
-	accNoAsBytes, err := stub.GetState("accNo")
-	if err != nil {
+	entry, err := getAccountIndex(stub, args)
+	if err != nil || entry.CustID == "" {
 		return false, ""
 	}
-	json.Unmarshal(accNoAsBytes, &AccountFromState)
-	if value, found := AccountFromState[args]; found {
-		fmt.Println("Value::", value)
-		custID := strings.Split(value, ",")
-		return true, custID[1]
-	}
-	return false, ""
+	fmt.Println("Value::", entry.OwnerName)
+	return true, entry.ref()
 }
 
 //============================================================================
@@ -236,77 +343,84 @@ func (b *Bank) addUpdateBankAccount(stub shim.ChaincodeStubInterface, args []str
 		return shim.Error(`{"status": 500 , "message":"Expecting 5 Arguments , Please Provide 5 arguments"}`)
 	}
 	kyc := Kyc{}
-	asBytes, _ := stub.GetState(args[0])
+	asBytes, _ := getKycState(stub, args[0])
 	json.Unmarshal(asBytes, &kyc)
 	if kyc.CustID == "" {
 		return shim.Error(`{"status":500 , "message" :"Sorry Customer Not Found , Please Provide Correct Customer ID"}`)
 	}
-	err, value := b.IsAccountExists(stub, args[1])
+	err, _ := b.IsAccountExists(stub, args[1])
 	if err == true {
-		return shim.Error(`{"status": 404 , "message" : "Account Already Found and register with Account Holder ` + value + `"}`)
+		return shim.Error(`{"status": 404 , "message" : "Account Already Found and register with another Account Holder"}`)
+	}
+	accountType, typeErr := lookupAccountType(stub, args[4])
+	if typeErr != nil {
+		return shim.Error(`{"status": 500 , "message": "` + typeErr.Error() + `"}`)
+	}
+	if accountType.isShared() {
+		return shim.Error(`{"status": 500 , "message": "Joint and Business Accounts are opened with their Customers through addkyc"}`)
 	}
 	_, err1, isTrue := kyc.setCustomerNameWithAccountNo(stub, args[1], args[2], args[3], args[4], args[5])
 	if isTrue != true {
 		return shim.Error(`{"status": 500 , "message": "` + err1 + `"}`)
 
 	}
-	writeKycToLedger(stub, kyc)
+	if err := putKyc(stub, kyc); err != nil {
+		return shim.Error(`{"status": 403 , "message": "` + string(err.Error()) + `"}`)
+	}
 
 	return shim.Success([]byte(`{"status":200 , "message: "Data Updated"}`))
 }
 
 //=========================================================================================================
 //updateAccountLimit: this function will takes custid and account number then update limit of that account
+//Limit is recorded as outgoing override starting now without end , account type default is left untouched
+//args[0]: custID
+//args[1]: account number
+//args[2]: limit
+//args[3]: (optional) reason
 //=========================================================================================================
 func (b *Bank) updateAccountLimit(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 3 {
+	if len(args) != 3 && len(args) != 4 {
 		return shim.Error(`{"status": 500 , "message" :"Expecting three (3) arguments"}`)
 	}
-	value, _ := stub.GetCreator()
-	fmt.Println(string(value))
-	customerKyc := Kyc{}
-	customerKycAsBytes, err := stub.GetState(args[0])
-	if err != nil {
-		return shim.Error(`{"status": 500 , "message" : "` + string(err.Error()) + `"}`)
-	}
-	// accountTypeStruct := AccountType{}
-	json.Unmarshal(customerKycAsBytes, &customerKyc)
-	account := Account{}
-	account = customerKyc.Accounts[args[1]]
-	if account.AccountNumber == "" {
-		return shim.Error(`{"status": 404 , "message" : "Account Not Found"}`)
-
+	reason := "Limit updated"
+	if len(args) == 4 && args[3] != "" {
+		reason = args[3]
 	}
-	limit, err1 := strconv.ParseFloat(args[2], 64)
-	if err1 != nil {
-		return shim.Error(`{"status": 500 , "message": "Can Not Convert Limit to Number"}`)
-	}
-
-	account.AccountType.Limit = limit
-	// accountTypeStruct = accountType
-	customerKyc.Accounts[args[1]] = account
-	valueAsBytes, _ := json.Marshal(customerKyc)
-	err = stub.PutState(customerKyc.CustID, valueAsBytes)
-	if err != nil {
-		return shim.Error(`{"status": 500 , "message" : "` + string(err.Error()) + `"}`)
-	}
-	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
+	return putLimitOverride(stub, args[0], args[1], args[2], string(OUTGOING), "", "", reason)
 }
 
 //======================================================================
-//checkAccount: this function will retrun account name
+//checkAccount: this function will retrun account name to bank of account
+//and regulator
+//args[0]: account number
+//args[1]: (optional) expected name , result of confirmation of payee is
+//returned instead of name , any bank can confirm payee
 //======================================================================
 func (b *Bank) checkAccount(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 1 {
-		return shim.Error(`{"status" : 500 , "message" :"Please Provide 1 Argument"}`)
+	if len(args) != 1 && len(args) != 2 {
+		return shim.Error(`{"status" : 500 , "message" :"Please Provide Account Number and optional Name"}`)
+	}
+	if len(args) == 2 {
+		return b.confirmPayee(stub, args)
+	}
+	entry, err := getAccountIndex(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	}
-	err, value := b.IsAccountExists(stub, args[0])
-	if err == false {
+	if entry.CustID == "" {
 		return shim.Error(`{"status": 404 , "message" : "Account Not Found"}`)
-	} else {
-		return shim.Success([]byte(`{"status": 200 , "data": "` + string(value) + `"}`))
 	}
-
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	// other banks confirm payee by expected name and never read name of holder
+	if entry.MSPID != mspID && !isRegulator(stub) {
+		return shim.Error(`{"status": 403 , "message": "Account does not belong to ` + mspID + ` , Please Provide expected Name"}`)
+	}
+	// only name of holder is returned , customer reference stays with its bank
+	return shim.Success([]byte(`{"status": 200 , "data": "` + entry.OwnerName + `"}`))
 }
 
 //======================================================================================================================
@@ -319,8 +433,11 @@ func (b *Bank) checkAccount(stub shim.ChaincodeStubInterface, args []string) pb.
 //args[6] : Bank MSPID
 //======================================================================================================================
 func (b *Bank) addKyc(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) > 0 && strings.Contains(args[0], customerRefSeparator) {
+		return shim.Error(`{"status": 500 , "message": "Customer ID can not contain ` + customerRefSeparator + `"}`)
+	}
 	if len(args) == 3 {
-		value, _ := stub.GetState(args[0])
+		value, _ := getKycState(stub, args[0])
 
 		if value != nil {
 			return shim.Error(`{ "status":403 , "message":"Customer with same ID Exist  ` + args[0] + `  Please Provide Unique ID"}`)
@@ -338,17 +455,23 @@ func (b *Bank) addKyc(stub shim.ChaincodeStubInterface, args []string) pb.Respon
 			IsBlackList:       isBlack,
 			MSPID:             mspid + "_" + id,
 		}
+		if newKyc.CreatedAt, newKyc.CreatedBy, err = auditStamp(stub); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		if err := registerIdentity(stub, &newKyc); err != nil {
+			return shim.Error(`{"status": 403 , "message": "` + string(err.Error()) + `"}`)
+		}
 		writeKycToLedger(stub, newKyc)
 	} else if len(args) == 9 {
-		value, _ := stub.GetState(args[0])
+		value, _ := getKycState(stub, args[0])
 
 		if value != nil {
 			return shim.Error(`{ "status":403 , "message":"Customer with same ID Exist  ` + args[0] + `  Please Provide Unique ID"}`)
 		}
 		//to be checked here
-		isExist, value1 := b.IsAccountExists(stub, args[4])
+		isExist, _ := b.IsAccountExists(stub, args[4])
 		if isExist == true {
-			return shim.Error(`{"status": 404 , "message" :"Account Already Found and register with Account Holder ` + value1 + `"}`)
+			return shim.Error(`{"status": 404 , "message" :"Account Already Found and register with another Account Holder"}`)
 		}
 		isBlack, _ := strconv.ParseBool(args[2])
 		mspid, err := cid.GetMSPID(stub)
@@ -356,9 +479,13 @@ func (b *Bank) addKyc(stub shim.ChaincodeStubInterface, args []string) pb.Respon
 		if err != nil {
 			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 		}
-		accountType := AccountType{}
-		accountTypeAsBytes, _ := stub.GetState(args[3])
-		json.Unmarshal(accountTypeAsBytes, &accountType)
+		accountType, err := lookupAccountType(stub, args[3])
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		if len(accountType.RequiredDocuments) > 0 && args[7] == "" {
+			return shim.Error(`{"status": 500 , "message": "Please Provide hash of documents (` + strings.Join(accountType.RequiredDocuments, " , ") + `)"}`)
+		}
 
 		newKyc := Kyc{
 			CustID:            args[0],
@@ -367,30 +494,31 @@ func (b *Bank) addKyc(stub shim.ChaincodeStubInterface, args []string) pb.Respon
 			IsBlackList:       isBlack,
 			MSPID:             mspid + "_" + id,
 		}
-		if args[3] == "Business" || args[3] == "Joint" || args[3] == "business" || args[3] == "joint" {
+		if newKyc.CreatedAt, newKyc.CreatedBy, err = auditStamp(stub); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		if err := registerIdentity(stub, &newKyc); err != nil {
+			return shim.Error(`{"status": 403 , "message": "` + string(err.Error()) + `"}`)
+		}
+		if accountType.isShared() {
 
 			fmt.Println(args[8])
-			var businessAccounts map[string]interface{}
+			var businessAccounts SharedAccountRequest
 			if len(args[8]) != 0 {
 				if err := json.Unmarshal([]byte(args[8]), &businessAccounts); err != nil {
-					fmt.Println(businessAccounts)
-					panic(err)
+					return shim.Error(`{"status": 500 , "message": "Please check Customers of account ` + string(err.Error()) + `"}`)
 				}
 				_, err1, isTrue := newKyc.setBuisenessAccount(stub, args[4], args[5], args[6], args[3], args[7], businessAccounts)
 				if isTrue != true {
 					return shim.Error(`{"status": 500 , "message": "` + err1 + `"}`)
 				}
-				_, err1, isTrue = newKyc.setCustomerNameWithAccountNo(stub, args[4], args[5], args[6], args[3], args[7])
-				if isTrue != true {
-					return shim.Error(`{"status": 500 , "message": "` + err1 + `"}`)
-				}
 				writeKycToLedger(stub, newKyc)
 
 			} else {
 				//to be checked here
-				isExist, value1 := b.IsAccountExists(stub, args[4])
+				isExist, _ := b.IsAccountExists(stub, args[4])
 				if isExist == true {
-					return shim.Error(`{"status": 404 , "message" :"Account Already Found and register with Account Holder ` + value1 + `"}`)
+					return shim.Error(`{"status": 404 , "message" :"Account Already Found and register with another Account Holder"}`)
 				}
 				_, err1, isTrue := newKyc.setCustomerNameWithAccountNo(stub, args[4], args[5], args[6], args[3], args[7])
 				if isTrue != true {
@@ -402,7 +530,7 @@ func (b *Bank) addKyc(stub shim.ChaincodeStubInterface, args []string) pb.Respon
 
 			//return shim.Error(`{ "status":500 , "message":"Please Provide Correct Name of account type"}`)
 
-		} else if args[3] == "Individual" || args[3] == "individual" {
+		} else {
 
 			_, err1, isTrue := newKyc.setCustomerNameWithAccountNo(stub, args[4], args[5], args[6], args[3], args[7])
 			if isTrue == false {
@@ -410,8 +538,6 @@ func (b *Bank) addKyc(stub shim.ChaincodeStubInterface, args []string) pb.Respon
 
 			}
 			writeKycToLedger(stub, newKyc)
-		} else {
-			return shim.Error(`{"status": 500 , "message": "Please Provide Correct Account Type"}`)
 		}
 
 	} else {
@@ -424,18 +550,20 @@ func (b *Bank) addKyc(stub shim.ChaincodeStubInterface, args []string) pb.Respon
 //===================================================================
 //addBranch: this function will add new branch to ledger
 // args[0]: BranchCode    string `json:"branch_code"`
+//
 //	args[1]:BranchName    string `json:"branch_name"`
 //	args[2]:BranchAddress string `json:"branch_address"`
-//	args[3]:MSPID         string `json:"msp_id"`
+//	args[3]:(optional) Region
+//	args[4]:(optional) parent branch code
+//
 //=====================================================================
 func (b *Bank) addBranch(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 3 {
-		return shim.Error(`{"status": 500 , "message" : "Please Provide 3 arguments"}`)
+	if len(args) < 3 || len(args) > 5 {
+		return shim.Error(`{"status": 500 , "message" : "Please Provide 3 arguments , optional Region and Parent Branch Code"}`)
 	}
 	mspID, _ := cid.GetMSPID(stub)
 
-	branchKey := mspID + "_" + args[0]
-	asBytes, _ := stub.GetState(branchKey)
+	asBytes, _ := getBranchState(stub, mspID+"_"+args[0])
 	branch := Branch{}
 	json.Unmarshal(asBytes, &branch)
 	fmt.Println(branch.BranchCode)
@@ -449,9 +577,23 @@ func (b *Bank) addBranch(stub shim.ChaincodeStubInterface, args []string) pb.Res
 		BranchName:    args[1],
 		BranchAddress: args[2],
 		MSPID:         mspId,
+		Code:          args[0],
+		Status:        BRANCHACTIVE,
+	}
+	var err error
+	if branch.CreatedAt, branch.CreatedBy, err = auditStamp(stub); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if len(args) > 3 {
+		branch.Region = args[3]
+	}
+	if len(args) > 4 {
+		if err := checkBranchParent(stub, mspId, args[0], args[4]); err != nil {
+			return shim.Error(`{"status": 403 , "message": "` + string(err.Error()) + `"}`)
+		}
+		branch.ParentCode = args[4]
 	}
-	asBytes, _ = json.Marshal(branch)
-	err := stub.PutState(branch.BranchCode, asBytes)
+	err = putBranch(stub, branch)
 	if err != nil {
 		return shim.Error(`{"status": 500 , message: "` + string(err.Error()) + `"}`)
 	}
@@ -469,7 +611,7 @@ func (b *Bank) updateBranchAddress(stub shim.ChaincodeStubInterface, args []stri
 	branchKey := mspID + "_" + args[0]
 	fmt.Println(branchKey)
 
-	asBytes, _ := stub.GetState(branchKey)
+	asBytes, _ := getBranchState(stub, branchKey)
 
 	branch := Branch{}
 	err := json.Unmarshal(asBytes, &branch)
@@ -481,8 +623,7 @@ func (b *Bank) updateBranchAddress(stub shim.ChaincodeStubInterface, args []stri
 
 	}
 	branch.BranchAddress = args[1]
-	asBytes, _ = json.Marshal(branch)
-	err = stub.PutState(branch.BranchCode, asBytes)
+	err = putBranch(stub, branch)
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message" : "` + string(err.Error()) + `"}`)
 	}
@@ -490,17 +631,22 @@ func (b *Bank) updateBranchAddress(stub shim.ChaincodeStubInterface, args []stri
 }
 
 //==============================================================================
-//getBranchDetail :
+//getBranchDetail : this func will return branch of caller bank or of bank given
+//args[0]: branch code
+//args[1]: (optional) MSPID of bank , used to look up receiver branch
 //============================================================================
 func (b *Bank) getBranchDetail(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 1 {
-		return shim.Error(`{"status": 500 , "message" : "Please Provide 1 Argument"}`)
+	if len(args) != 1 && len(args) != 2 {
+		return shim.Error(`{"status": 500 , "message" : "Please Provide Branch Code and optional MSPID"}`)
 	}
 	mspID, _ := cid.GetMSPID(stub)
+	if len(args) == 2 && args[1] != "" {
+		mspID = args[1]
+	}
 	branchKey := mspID + "_" + args[0]
 	fmt.Println(branchKey)
 
-	asBytes, _ := stub.GetState(branchKey)
+	asBytes, _ := getBranchState(stub, branchKey)
 	branch := Branch{}
 	// if err := json.Unmarshal(asBytes, &branch); err != nil {
 	//	return shim.Error(`{"status": 404 , "message" : "Branch Not Found"}`)
@@ -520,10 +666,7 @@ func (b *Bank) getBranchDetail(stub shim.ChaincodeStubInterface, args []string)
 //============================================================
 func writeKycToLedger(stub shim.ChaincodeStubInterface, kyc Kyc) pb.Response {
 	fmt.Println(kyc)
-	kyc.ObjectType = "kyc"
-	asBytes, _ := json.Marshal(kyc)
-
-	err := stub.PutState(kyc.CustID, asBytes)
+	err := putKyc(stub, kyc)
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	}
@@ -541,7 +684,7 @@ func (b *Bank) updateKycDocumentHash(stub shim.ChaincodeStubInterface, args []st
 
 	}
 
-	customerAsBytes, _ := stub.GetState(args[0])
+	customerAsBytes, _ := getKycState(stub, args[0])
 	kyc := Kyc{}
 	// val, ok, err := cid.GetAttributeValue(stub, "agent")
 	// if err != nil {
@@ -566,8 +709,7 @@ func (b *Bank) updateKycDocumentHash(stub shim.ChaincodeStubInterface, args []st
 		// kyc.AgentID = val
 		kyc.PersonalHash = args[1]
 
-		customerAsBytes, _ = json.Marshal(kyc)
-		err := stub.PutState(args[0], customerAsBytes)
+		err := putKyc(stub, kyc)
 		if err != nil {
 			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 		}
@@ -586,7 +728,7 @@ func (b *Bank) addToBlackList(stub shim.ChaincodeStubInterface, args []string) p
 		return shim.Error(`{"status": 500 , "message": "Incorrect number of arguments , Expecting 1"}`)
 
 	}
-	customerAsBytes, _ := stub.GetState(args[0])
+	customerAsBytes, _ := getKycState(stub, args[0])
 	kyc := Kyc{}
 	json.Unmarshal(customerAsBytes, &kyc)
 	kyc.IsBlackList = true
@@ -599,11 +741,16 @@ func (b *Bank) addToBlackList(stub shim.ChaincodeStubInterface, args []string) p
 
 	}
 	//kyc.AgentID = val
-	customerAsBytes, _ = json.Marshal(kyc)
-	err = stub.PutState(args[0], customerAsBytes)
+	if err := computeCustomerRiskScore(stub, &kyc); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	err = putKyc(stub, kyc)
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	}
+	if err := propagateBlackList(stub, kyc); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
 	return shim.Success([]byte(`{"status":200 ,"message":"Data Updated"}`))
 }
 
@@ -615,7 +762,7 @@ func (b *Bank) removeFromBlackList(stub shim.ChaincodeStubInterface, args []stri
 		return shim.Error(`{"status": 500 , "message": "Incorrect number of arguments , Expecting 1"}`)
 
 	}
-	customerAsBytes, _ := stub.GetState(args[0])
+	customerAsBytes, _ := getKycState(stub, args[0])
 	kyc := Kyc{}
 	_, ok, err := cid.GetAttributeValue(stub, "userType")
 	if err != nil {
@@ -631,8 +778,11 @@ func (b *Bank) removeFromBlackList(stub shim.ChaincodeStubInterface, args []stri
 		return shim.Error(`{"status": 500 , "message":"Custid not Found"}`)
 	}
 	kyc.IsBlackList = false
-	customerAsBytes, _ = json.Marshal(kyc)
-	err = stub.PutState(args[0], customerAsBytes)
+	if err := computeCustomerRiskScore(stub, &kyc); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	// records of same person at other banks keep their own flag
+	err = putKyc(stub, kyc)
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	}
@@ -643,11 +793,11 @@ func (b *Bank) removeFromBlackList(stub shim.ChaincodeStubInterface, args []stri
 //getPersonalKycStatus:
 //=======================================================================
 func (b *Bank) updatedPersonalKycStatus(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 2 {
-		return shim.Error(`{"status": 500 , "message": "Incorrect number of arguments , Expecting id of customer and Kyc Status}`)
+	if len(args) != 2 && len(args) != 3 {
+		return shim.Error(`{"status": 500 , "message": "Incorrect number of arguments , Expecting id of customer , Kyc Status and optional Risk Tier}`)
 
 	}
-	customerAsBytes, err := stub.GetState(args[0])
+	customerAsBytes, err := getKycState(stub, args[0])
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	} else if customerAsBytes == nil {
@@ -660,6 +810,16 @@ func (b *Bank) updatedPersonalKycStatus(stub shim.ChaincodeStubInterface, args [
 		return shim.Error(`{"status" : 500 , "message" : "Kyc Not Found"}`)
 	}
 	if args[1] == "approved" || args[1] == "Approved" {
+		var tier string
+		if len(args) == 3 {
+			tier = args[2]
+		}
+		if err := computeCustomerRiskScore(stub, &kyc); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		if err := scheduleKycReview(stub, &kyc, tier); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
 		kyc.PersonalKycStatus = APPROVED
 	} else if args[1] == "disapproved" || args[1] == "Disapproved" {
 		kyc.PersonalKycStatus = DISAPPROVED
@@ -670,8 +830,7 @@ func (b *Bank) updatedPersonalKycStatus(stub shim.ChaincodeStubInterface, args [
 	} else {
 		return shim.Error(`{"status": 500 , "message": "please provide correct Status(pending , approved , disaproved , rejected )"}`)
 	}
-	customerAsBytes, _ = json.Marshal(kyc)
-	err = stub.PutState(args[0], customerAsBytes)
+	err = putKyc(stub, kyc)
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	}
@@ -686,7 +845,7 @@ func (b *Bank) checkPersonalKycStatus(stub shim.ChaincodeStubInterface, args []s
 		return shim.Error(`{"status": 500 , "message": "Incorrect number of arguments , Expecting id of customer to query"}`)
 
 	}
-	customerAsBytes, err := stub.GetState(args[0])
+	customerAsBytes, err := getKycState(stub, args[0])
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	} else if customerAsBytes == nil {
@@ -706,7 +865,7 @@ func (b *Bank) checkStatusOfAccount(stub shim.ChaincodeStubInterface, args []str
 		return shim.Error(`{"status": 500 , "message": "Incorrect number of arguments , Expecting id of customer and Account number"}`)
 
 	}
-	customerAsBytes, err := stub.GetState(args[0])
+	customerAsBytes, err := getKycState(stub, args[0])
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	} else if customerAsBytes == nil {
@@ -723,7 +882,11 @@ func (b *Bank) checkStatusOfAccount(stub shim.ChaincodeStubInterface, args []str
 		return shim.Error(`{"status": 404 , "message": "Account Number does not belong to this Customer"}`)
 
 	}
-	return shim.Success([]byte(`{"status" : 200 , "data":"` + kyc.Accounts[args[1]].AccountKycStatus + `"}`))
+	account, err := resolveAccount(stub, kyc.Accounts[args[1]])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status" : 200 , "data":"` + account.AccountKycStatus + `"}`))
 }
 
 //==================================================
@@ -734,7 +897,7 @@ func (b *Bank) updateAccountKycStatus(stub shim.ChaincodeStubInterface, args []s
 		return shim.Error(`{"status": 500 , "message":"Incorrect Number of argument , Expecting 3 argument"}`)
 
 	}
-	customerAsBytes, _ := stub.GetState(args[0])
+	customerAsBytes, _ := getKycState(stub, args[0])
 	kyc := Kyc{}
 	err := json.Unmarshal(customerAsBytes, &kyc)
 
@@ -743,13 +906,21 @@ func (b *Bank) updateAccountKycStatus(stub shim.ChaincodeStubInterface, args []s
 		return shim.Error(`{"status": 404 , "message": "Customer Id  Not Found"}`)
 
 	}
-	account := Account(kyc.Accounts[args[1]])
+	account, err := resolveAccount(stub, kyc.Accounts[args[1]])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
 	if account.AccountType.AccountTypeName == "" {
 		return shim.Error(`{"status": 404 , "message": "Customer Does Not have this Account "}`)
 
 	}
 	// json.Unmarshal(kyc.Accounts[args[1]], &branchAccount)
 	if args[2] == "approved" || args[2] == "Approved" {
+		if account.AccountType.category() == BUSINESSCATEGORY {
+			if err := checkBeneficialOwnersKyc(stub, account); err != nil {
+				return shim.Error(`{"status": 403 , "message": "` + string(err.Error()) + `"}`)
+			}
+		}
 		account.AccountKycStatus = APPROVED
 	} else if args[2] == "disapproved" || args[2] == "Disapproved" {
 		account.AccountKycStatus = DISAPPROVED
@@ -776,9 +947,11 @@ func (b *Bank) updateAccountKycStatus(stub shim.ChaincodeStubInterface, args []s
 	// 	return shim.Error(`{"status": 403 , "message": "` + branchCode.Error() + "" + regionalCheck.Error() + `"}`)
 	// }
 	kyc.AgentID = ""
-	kyc.Accounts[args[1]] = account
-	customerAsBytes, _ = json.Marshal(kyc)
-	err = stub.PutState(args[0], customerAsBytes)
+	err = putAccount(stub, &kyc, account)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	err = putKyc(stub, kyc)
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	}
@@ -789,7 +962,7 @@ func (b *Bank) updateAccountKycStatus(stub shim.ChaincodeStubInterface, args []s
 //UpdateKYcOfAccount :
 //===================================================
 // func (ba  *BranchAccount)
-//================================================================================================
+// ================================================================================================
 //Query Customer's Kyc History
 //This method will return the KYC data of the customer for banks to perform customer Due diligence
 //=================================================================================================
@@ -799,11 +972,18 @@ func (b *Bank) queryCustomerKycHistory(stub shim.ChaincodeStubInterface, args []
 		return shim.Error(`{"status": 500 , "message": "Incorrect number of arguments , Expecting 1"}`)
 	}
 
-	customerID := args[0]
+	mspID, customerID, err := customerRef(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	key, err := kycKey(stub, mspID, customerID)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
 
 	fmt.Printf("- start getHistoryKYCForCustomer: %s\n", customerID)
 	//Retreive All the Kyc data of
-	resultsIterator, err := stub.GetHistoryForKey(customerID)
+	resultsIterator, err := stub.GetHistoryForKey(key)
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	}
@@ -852,7 +1032,7 @@ func (b *Bank) queryCustomerKycHistory(stub shim.ChaincodeStubInterface, args []
 // KycStatus Pending , new and updated
 //====================================================================
 func (b *Bank) searchPendingCustomer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	resultsIterator, err := stub.GetStateByRange("", "")
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(kycKeyType, []string{})
 	if err != nil {
 		return shim.Error(`{"status" : 500 , "message": "` + string(err.Error()) + `"}`)
 	}
@@ -909,7 +1089,7 @@ func (b *Bank) getCustomerKycData(stub shim.ChaincodeStubInterface, args []strin
 
 	}
 
-	valAsbytes, err := stub.GetState(args[0]) //get the Customer from chaincode state
+	valAsbytes, err := getKycState(stub, args[0]) //get the Customer from chaincode state
 	kyc := Kyc{}
 	err = json.Unmarshal(valAsbytes, &kyc)
 	if err != nil {
@@ -920,6 +1100,29 @@ func (b *Bank) getCustomerKycData(stub shim.ChaincodeStubInterface, args []strin
 		return shim.Error(`{"status": 500 , "message": "No KYC found for Customer id:  ` + kyc.CustID + `"}`)
 
 	} else {
+		mspID, err := cid.GetMSPID(stub)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		seeAll, err := canSeeAccounts(stub, kyc, mspID)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		// accounts of other banks are hidden as in consolidated view
+		accounts := make(map[string]Account)
+		for accNo, account := range kyc.Accounts {
+			if !seeAll && bankOfBranch(account.BranchCode) != mspID {
+				hidden := hiddenAccount(account)
+				accounts[hidden.AccountNumber] = hidden
+				continue
+			}
+			account, err = resolveAccount(stub, account)
+			if err != nil {
+				return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+			}
+			accounts[accNo] = account
+		}
+		kyc.Accounts = accounts
 		valuesASbytes, err := json.Marshal(&kyc)
 		if err != nil {
 			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
@@ -965,17 +1168,26 @@ func (b *Bank) getCustomerKycData(stub shim.ChaincodeStubInterface, args []strin
 // }
 
 //=====================================================================================
-//verifyHash: this function will check and verify business account hash and kyc
+//verifyHash: this function will check and verify business account hash and kyc ,
+//category is category of sender account. withHash is false for standing instruction
+//run , its customers were verified when instruction was created but black list and
+//kyc status are checked on every run
 //======================================================================================
-func verifyHash(stub shim.ChaincodeStubInterface, accounts map[string]interface{}) (bool, []string) {
-	accountList := accounts["Customers"].([]interface{})
+func verifyHash(stub shim.ChaincodeStubInterface, accounts map[string]interface{}, category AccountCategory, withHash bool) (bool, []string) {
+	accountList, _ := accounts["Customers"].([]interface{})
 	var found = true
 	var custID []string
 
 	for _, value := range accountList {
-		customer := value.(map[string]interface{})
+		customer, isMap := value.(map[string]interface{})
+		customerID, _ := customer["CustID"].(string)
+		if !isMap || customerID == "" {
+			found = false
+			custID = append(custID, "Please Provide CustID of every Customer")
+			continue
+		}
 
-		valueAsBytes, _ := stub.GetState(customer["CustID"].(string))
+		valueAsBytes, _ := getKycState(stub, customerID)
 		kyc := Kyc{}
 		json.Unmarshal(valueAsBytes, &kyc)
 		if kyc.IsBlackList == true {
@@ -984,11 +1196,11 @@ func verifyHash(stub shim.ChaincodeStubInterface, accounts map[string]interface{
 		}
 		fmt.Println("HASH::", customer["Hash"])
 		fmt.Println("Personal HASH::", kyc.PersonalHash)
-		if customer["Hash"] != kyc.PersonalHash {
+		if withHash && customer["Hash"] != kyc.PersonalHash {
 			found = false
-			custID = append(custID, customer["CustID"].(string)+" Personal Hash not Match")
+			custID = append(custID, customerID+" Personal Hash not Match")
 		}
-		if accounts["AccountType"] == "Individual" {
+		if category == INDIVIDUALCATEGORY {
 			if kyc.PersonalKycStatus != "approved" {
 				found = false
 				custID = append(custID, kyc.CustID+"  KYC not Approved")
@@ -1004,104 +1216,210 @@ func verifyHash(stub shim.ChaincodeStubInterface, accounts map[string]interface{
 	}
 }
 
+//=========================================================================
+//transferOptions : how transfer created by batch or standing instruction is
+//initiated , zero value is transfer entered at branch
+//=========================================================================
+type transferOptions struct {
+	// TxID is id of child transfer , tx id of invocation when empty
+	TxID string
+	// BatchID is set for line of batch , line waits for decision on whole batch
+	BatchID string
+	// Scheduled is set for standing instruction run , customers were verified
+	// when instruction was created
+	Scheduled bool
+	// Ctr carries CTR totals and events between transfers of one invocation
+	Ctr *ctrBatch
+}
+
 //=======================================================================================
 //transfer: this function will initiate transfer transaction
+//args[11]: (optional) currency
+//args[12]: (optional) charge bearer (OUR , SHA , BEN) , SHA when not given
+//args[13]: (optional) idempotency key , unique per sender account
+//args[14]: (optional) receiver name for confirmation of payee
 //=======================================================================================
 func (b *Bank) transferInitiate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 11 {
-		return shim.Error(`{"status": 500 , "message" :"Please Provide 11 Arguments"}`)
+	response, _, err := b.initiateTransfer(stub, args, transferOptions{})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return response
+}
+
+//=======================================================================================
+//initiateTransfer: this func will check and write transfer and return id of transfer
+//written or replayed. Every check is done before first write , so a refused transfer
+//leaves nothing on ledger and error is returned only when a write failed
+//=======================================================================================
+func (b *Bank) initiateTransfer(stub shim.ChaincodeStubInterface, args []string, opts transferOptions) (pb.Response, string, error) {
+	if len(args) < 11 || len(args) > 15 {
+		return shim.Error(`{"status": 500 , "message" :"Please Provide 11 Arguments , optional Currency , Charge Bearer , Idempotency Key and Receiver Name"}`), "", nil
+	}
+	if opts.TxID == "" {
+		opts.TxID = stub.GetTxID()
 	}
 	var isOk = true
 	var customerArray []string
 	_, ok, err := cid.GetAttributeValue(stub, "userType")
 	if err != nil {
-		return shim.Error(`{"status": 404 , "message": "` + string(err.Error()) + `"}`)
+		return shim.Error(`{"status": 404 , "message": "` + string(err.Error()) + `"}`), "", nil
 	}
 	if !ok {
-		return shim.Error(`{"status": 403 , "message":"Client has no attribute UserType"}`)
+		return shim.Error(`{"status": 403 , "message":"Client has no attribute UserType"}`), "", nil
 
 	}
+	idempotencyKey := ""
+	if len(args) >= 14 {
+		idempotencyKey = strings.TrimSpace(args[13])
+	}
+	if idempotencyKey != "" {
+		if response, txID, replayed := replayTransfer(stub, idempotencyKey, args); replayed {
+			return response, txID, nil
+		}
+	}
 	var accountsForVerification map[string]interface{}
 	var limit float64
 	// var isOk bool
 	// var customerArray []string
 	if len(args[4]) != 0 {
 		if err := json.Unmarshal([]byte(args[4]), &accountsForVerification); err != nil {
-			return shim.Error(`{"status" 500 , "message" : "Please check   ` + args[4] + `"}`)
+			return shim.Error(`{"status" 500 , "message" : "Please check   ` + args[4] + `"}`), "", nil
 		}
 	}
-	if accountsForVerification["Customers"] != nil {
-		isOk, customerArray = verifyHash(stub, accountsForVerification)
-		fmt.Println(isOk, customerArray)
-	}
 	senderIsExist, senderCustID := b.IsAccountExists(stub, args[0])
 	senderKyc := Kyc{}
 	if senderIsExist == false {
-		return shim.Error(`{"status": 500 , "message": "Sender Account not found"}`)
+		return shim.Error(`{"status": 500 , "message": "Sender Account not found"}`), "", nil
 	}
-	senderAsBytes, _ := stub.GetState(senderCustID)
+	senderAsBytes, _ := getKycState(stub, senderCustID)
 	json.Unmarshal(senderAsBytes, &senderKyc)
+	lapsed, err := isKycReviewLapsed(stub, senderKyc)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`), "", nil
+	}
+	if lapsed {
+		return shim.Error(`{"status": 403 , "message": "Sender KYC review is due (` + senderKyc.NextReviewDate + `) , Please re-verify customer KYC"}`), "", nil
+	}
+	// stored risk level gates auto approval , it is recomputed on events that change risk
 	amount, err1 := strconv.ParseFloat(args[2], 64)
 	if err1 != nil {
-		return shim.Error(`{"status": 500 , "message": "Can Not Convert Amount to Number"}`)
+		return shim.Error(`{"status": 500 , "message": "Can Not Convert Amount to Number"}`), "", nil
 	}
 	receiverIsExist, receiverCustID := b.IsAccountExists(stub, args[1])
 	if receiverIsExist == false {
-		return shim.Error(`{"status": 500 , "message": "Receiver Account not found"}`)
+		return shim.Error(`{"status": 500 , "message": "Receiver Account not found"}`), "", nil
 	}
 
-	receiverAsBytes, _ := stub.GetState(receiverCustID)
+	receiverAsBytes, _ := getKycState(stub, receiverCustID)
 	receiverKyc := Kyc{}
 	json.Unmarshal(receiverAsBytes, &receiverKyc)
-	limit = senderKyc.Accounts[args[0]].AccountType.Limit
+	senderAccount, err := resolveAccount(stub, senderKyc.Accounts[args[0]])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`), "", nil
+	}
+	receiverAccount, err := resolveAccount(stub, receiverKyc.Accounts[args[1]])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`), "", nil
+	}
+	if senderAccount.isAccountBlocked() {
+		return shim.Error(`{"status": 403 , "message": "Sender Account is ` + string(senderAccount.status()) + `"}`), "", nil
+	}
+	if receiverAccount.isAccountBlocked() {
+		return shim.Error(`{"status": 403 , "message": "Receiver Account is ` + string(receiverAccount.status()) + `"}`), "", nil
+	}
+	// account kind comes from sender account on ledger , not from payload
+	category := senderAccount.category()
+	// standing instruction runs skip only hash , customers were verified when instruction was created
+	if accountsForVerification["Customers"] != nil {
+		isOk, customerArray = verifyHash(stub, accountsForVerification, category, !opts.Scheduled)
+		fmt.Println(isOk, customerArray)
+	}
+	if err := checkBranchOpen(stub, senderAccount.BranchCode); err != nil {
+		return shim.Error(`{"status": 403 , "message": "Sender ` + string(err.Error()) + `"}`), "", nil
+	}
+	if err := checkBranchOpen(stub, receiverAccount.BranchCode); err != nil {
+		return shim.Error(`{"status": 403 , "message": "Receiver ` + string(err.Error()) + `"}`), "", nil
+	}
+	receiverName := ""
+	if len(args) == 15 {
+		receiverName = strings.TrimSpace(args[14])
+	}
+	payee, err := verifyPayee(stub, receiverAccount, receiverName)
+	if err != nil {
+		return shim.Error(`{"status": 403 , "message": "` + string(err.Error()) + `"}`), "", nil
+	}
+	limit, err = transferLimit(stub, senderAccount, receiverAccount)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`), "", nil
+	}
+	var signing *TransferSigning
+	if senderAccount.ObjectType == sharedAccountType {
+		signing, err = newTransferSigning(stub, senderAccount, accountsForVerification)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`), "", nil
+		}
+	}
+	currency := currencyOfBranch(senderAccount.BranchCode)
+	if len(args) >= 12 && args[11] != "" {
+		currency = strings.ToUpper(args[11])
+	}
+	if !senderAccount.AccountType.allowsCurrency(currency) {
+		return shim.Error(`{"status": 403 , "message": "Currency ` + currency + ` is not allowed for ` + senderAccount.AccountType.AccountTypeName + ` Account"}`), "", nil
+	}
+	bearer := SHA
+	if len(args) >= 13 {
+		if bearer, err = parseChargeBearer(args[12]); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`), "", nil
+		}
+	}
+	charges, err := computeTransferCharges(stub, senderAccount, receiverAccount, amount, currency, bearer)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`), "", nil
+	}
 	if receiverKyc.IsBlackList == true {
-		return shim.Error(`{"status" :  500 , "message": "Receiver is BlackList"}`)
+		return shim.Error(`{"status" :  500 , "message": "Receiver is BlackList"}`), "", nil
 	}
 	fmt.Println(limit, receiverKyc.CustID)
-	if accountsForVerification["AccountType"] == "Business" {
-		if senderKyc.Accounts[args[0]].BusinessHash == accountsForVerification["BusinessHash"] && senderKyc.Accounts[args[0]].AccountKycStatus == "approved" {
-			if isOk == true {
-				if amount <= limit {
-					//senderAcc , senderName , senderBranch , amount , purpose , receiverAcc , receiverName , receiverBranch , reference , transactionStatusFlag
-					writeTransactionToLedger(stub, senderKyc.Accounts[args[0]].AccountNumber, senderKyc.Accounts[args[0]].OwnerName, senderKyc.Accounts[args[0]].BranchCode, amount, args[3], receiverKyc.Accounts[args[1]].AccountNumber, receiverKyc.Accounts[args[1]].OwnerName, receiverKyc.Accounts[args[1]].BranchCode, args[5], "A", args[6], args[7], args[8], args[9], args[10])
-				} else {
-					writeTransactionToLedger(stub, senderKyc.Accounts[args[0]].AccountNumber, senderKyc.Accounts[args[0]].OwnerName, senderKyc.Accounts[args[0]].BranchCode, amount, args[3], receiverKyc.Accounts[args[1]].AccountNumber, receiverKyc.Accounts[args[1]].OwnerName, receiverKyc.Accounts[args[1]].BranchCode, args[5], "i", args[6], args[7], args[8], args[9], args[10])
-				}
-			} else {
-				return shim.Error(`{"status": 500 , "message": ` + strings.Join(customerArray, ",") + `}`)
-			}
-		} else {
-			return shim.Error(`{"status": 403 , "message": "Business Hash Not Match"}`)
+	if category == BUSINESSCATEGORY {
+		if (!opts.Scheduled && senderAccount.BusinessHash != accountsForVerification["BusinessHash"]) || senderAccount.AccountKycStatus != "approved" {
+			return shim.Error(`{"status": 403 , "message": "Business Hash Not Match"}`), "", nil
 		}
-	} else if accountsForVerification["AccountType"] == "Joint" {
-		if isOk == true && senderKyc.Accounts[args[0]].AccountKycStatus == "approved" {
-			if amount <= limit {
-				//senderAcc , senderName , senderBranch , amount , purpose , receiverAcc , receiverName , receiverBranch , reference , transactionStatusFlag
-				writeTransactionToLedger(stub, senderKyc.Accounts[args[0]].AccountNumber, senderKyc.Accounts[args[0]].OwnerName, senderKyc.Accounts[args[0]].BranchCode, amount, args[3], receiverKyc.Accounts[args[1]].AccountNumber, receiverKyc.Accounts[args[1]].OwnerName, receiverKyc.Accounts[args[1]].BranchCode, args[5], "A", args[6], args[7], args[8], args[9], args[10])
-			} else {
-				writeTransactionToLedger(stub, senderKyc.Accounts[args[0]].AccountNumber, senderKyc.Accounts[args[0]].OwnerName, senderKyc.Accounts[args[0]].BranchCode, amount, args[3], receiverKyc.Accounts[args[1]].AccountNumber, receiverKyc.Accounts[args[1]].OwnerName, receiverKyc.Accounts[args[1]].BranchCode, args[5], "i", args[6], args[7], args[8], args[9], args[10])
-			}
-		} else {
-			return shim.Error(`{"status":500 , "message":` + strings.Join(customerArray, ",") + `}`)
+		if isOk != true {
+			return shim.Error(`{"status": 500 , "message": ` + strings.Join(customerArray, ",") + `}`), "", nil
 		}
-	} else if accountsForVerification["AccountType"] == "Individual" {
-		if isOk == true {
-			if amount <= limit {
-				//senderAcc , senderName , senderBranch , amount , purpose , receiverAcc , receiverName , receiverBranch , reference , transactionStatusFlag
-				writeTransactionToLedger(stub, senderKyc.Accounts[args[0]].AccountNumber, senderKyc.Accounts[args[0]].OwnerName, senderKyc.Accounts[args[0]].BranchCode, amount, args[3], receiverKyc.Accounts[args[1]].AccountNumber, receiverKyc.Accounts[args[1]].OwnerName, receiverKyc.Accounts[args[1]].BranchCode, args[5], "A", args[6], args[7], args[8], args[9], args[10])
-			} else {
-				writeTransactionToLedger(stub, senderKyc.Accounts[args[0]].AccountNumber, senderKyc.Accounts[args[0]].OwnerName, senderKyc.Accounts[args[0]].BranchCode, amount, args[3], receiverKyc.Accounts[args[1]].AccountNumber, receiverKyc.Accounts[args[1]].OwnerName, receiverKyc.Accounts[args[1]].BranchCode, args[5], "i", args[6], args[7], args[8], args[9], args[10])
-			}
-		} else {
-			fmt.Println("Return Status", isOk)
-			fmt.Println("ACCC:", senderKyc.Accounts[args[0]].AccountKycStatus)
-			return shim.Error(`{"status":500 , "message":"` + strings.Join(customerArray, ",") + `"}`)
+	} else if category == JOINTCATEGORY {
+		if isOk != true || senderAccount.AccountKycStatus != "approved" {
+			return shim.Error(`{"status":500 , "message":` + strings.Join(customerArray, ",") + `}`), "", nil
+		}
+	} else if isOk != true {
+		fmt.Println("Return Status", isOk)
+		fmt.Println("ACCC:", senderAccount.AccountKycStatus)
+		return shim.Error(`{"status":500 , "message":"` + strings.Join(customerArray, ",") + `"}`), "", nil
+	}
+	transactionStatusFlag := "i"
+	if autoApproveTransfer(amount, limit, senderKyc) {
+		transactionStatusFlag = "A"
+	}
+	//senderAcc , senderName , senderBranch , amount , purpose , receiverAcc , receiverName , receiverBranch , reference , transactionStatusFlag
+	transaction, err := newTransferTransaction(stub, opts, senderAccount.AccountNumber, senderAccount.OwnerName, senderAccount.BranchCode, amount, args[3], receiverAccount.AccountNumber, receiverAccount.OwnerName, receiverAccount.BranchCode, args[5], transactionStatusFlag, args[6], args[7], args[8], args[9], args[10], currency, signing, charges, payee)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`), "", nil
+	}
+	// writes start here , a failed write fails whole invocation
+	if err := putTransaction(stub, transaction); err != nil {
+		return pb.Response{}, "", err
+	}
+	if err := checkCurrencyTransactionReport(stub, senderKyc, senderAccount.AccountNumber, amount, currency, opts.TxID, opts.Ctr); err != nil {
+		return pb.Response{}, "", err
+	}
+	if idempotencyKey != "" {
+		if err := putIdempotencyKey(stub, args[0], idempotencyKey, opts.TxID); err != nil {
+			return pb.Response{}, "", err
 		}
-	} else {
-		return shim.Error(`{"status": 500 , "message":"Please Provide Valid Account Type (Individual , Joint , Business)" `)
 	}
 
-	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
+	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`)), opts.TxID, nil
 }
 
 //============================================================================
@@ -1109,10 +1427,11 @@ func (b *Bank) transferInitiate(stub shim.ChaincodeStubInterface, args []string)
 //==============================================================================
 // func
 //===========================================================
-// writeTransactionToLedger: this func will write Transaction to ledger state
+// newTransferTransaction: this func will build Transaction of transfer with its
+// route and fees , nothing is written so route or fee error leaves ledger as it is
 //============================================================
-func writeTransactionToLedger(stub shim.ChaincodeStubInterface, senderAcc string, senderName string, senderBranch string, amount float64, purpose string, receiverAcc string, receiverName string, receiverBranch string, reference string, transactionStatusFlag string, dochash string, timecreated string, riskrating string, crimerelated string, recieveredd string) pb.Response {
-	txID := stub.GetTxID()
+func newTransferTransaction(stub shim.ChaincodeStubInterface, opts transferOptions, senderAcc string, senderName string, senderBranch string, amount float64, purpose string, receiverAcc string, receiverName string, receiverBranch string, reference string, transactionStatusFlag string, dochash string, valueDate string, riskrating string, crimerelated string, recieveredd string, currency string, signing *TransferSigning, charges *TransferCharges, payee *PayeeCheck) (Transaction, error) {
+	txID := opts.TxID
 	fmt.Println(senderAcc, senderName, receiverAcc)
 	//receiverKyc := Kyc{}
 	// senderKyc := Kyc{}
@@ -1136,6 +1455,10 @@ func writeTransactionToLedger(stub shim.ChaincodeStubInterface, senderAcc string
 	} else {
 		transactionStatus = ACCEPTEDSENDERBANK
 	}
+	// line of batch waits for decision on whole batch
+	if opts.BatchID != "" {
+		transactionStatus = INITIATED
+	}
 	var risk_rating Riskrating
 	if riskrating == "low" || riskrating == "LOW" {
 		risk_rating = LOW
@@ -1149,6 +1472,10 @@ func writeTransactionToLedger(stub shim.ChaincodeStubInterface, senderAcc string
 	if riskrating == "" || riskrating == "" {
 		risk_rating = UNKNOWN
 	}
+	now, actor, err := auditStamp(stub)
+	if err != nil {
+		return transaction, err
+	}
 	transaction = Transaction{
 		ObjectType:         "transaction",
 		TransactionID:      txID,
@@ -1160,7 +1487,8 @@ func writeTransactionToLedger(stub shim.ChaincodeStubInterface, senderAcc string
 		ReceiverBranchName: receiverBranch,
 		Amount:             amount,
 		TransactionStatus:  transactionStatus,
-		Created:            timecreated,
+		Created:            now,
+		ValueDate:          valueDate,
 		Purpose:            purpose,
 		Reference:          reference,
 		Flagged:            true,
@@ -1168,16 +1496,30 @@ func writeTransactionToLedger(stub shim.ChaincodeStubInterface, senderAcc string
 		Riskrating:         risk_rating,
 		Crimerelated:       crimerelated,
 		RecieverEDD:        recieveredd,
+		Currency:           currency,
+		CreatedAt:          now,
+		CreatedBy:          actor,
+		PayeeCheck:         payee,
+		BatchID:            opts.BatchID,
+	}
+	if signing != nil {
+		transaction.RequiredSignatures = signing.Required
+		transaction.Signatures = signing.Signatures
+		transaction.SignatureDeadline = signing.Deadline
+		if len(signing.Signatures) < signing.Required {
+			transaction.PendingStatus = transactionStatus
+			transaction.TransactionStatus = AWAITINGSIGNATURES
+		}
 	}
-
-	asBytes, _ := json.Marshal(transaction)
-
-	err := stub.PutState(transaction.TransactionID, asBytes)
-	if err != nil {
-		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	if err := routeTransaction(stub, &transaction); err != nil {
+		return transaction, err
 	}
-	return shim.Success([]byte(`{"status":200 , "message": "Data Updated" }`))
-
+	if charges != nil {
+		if err := applyTransferCharges(&transaction, charges); err != nil {
+			return transaction, err
+		}
+	}
+	return transaction, nil
 }
 
 //===============================================
@@ -1187,7 +1529,7 @@ func (b *Bank) verifyEDD(stub shim.ChaincodeStubInterface, args []string) pb.Res
 	if len(args) != 2 {
 		return shim.Error(`{"status": 500 , "message": "Please provide 2 arguments Transaction Id and Hash"}`)
 	}
-	asBytes, err := stub.GetState(args[0])
+	asBytes, err := getTransactionState(stub, args[0])
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	}
@@ -1210,7 +1552,7 @@ func (b *Bank) verifyReceiverEDD(stub shim.ChaincodeStubInterface, args []string
 	if len(args) != 2 {
 		return shim.Error(`{"status": 500 , "message": "Please provide 2 arguments Transaction Id and Hash"}`)
 	}
-	asBytes, err := stub.GetState(args[0])
+	asBytes, err := getTransactionState(stub, args[0])
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	}
@@ -1226,9 +1568,9 @@ func (b *Bank) verifyReceiverEDD(stub shim.ChaincodeStubInterface, args []string
 	}
 }
 
-//==========================================================================
+// ==========================================================================
 // getPendingTransactionSenderBank::: of organization MSPID
-//==========================================================================
+// ==========================================================================
 func (b *Bank) getPendingTransactionSenderBank(stub shim.ChaincodeStubInterface) pb.Response {
 	val, ok, err := cid.GetAttributeValue(stub, "branchCode")
 	if err != nil {
@@ -1246,7 +1588,7 @@ func (b *Bank) getPendingTransactionSenderBank(stub shim.ChaincodeStubInterface)
 
 	}
 	mspId, _ := cid.GetMSPID(stub)
-	resultsIterator, err := stub.GetStateByRange("", "")
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(transactionKeyType, []string{})
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	}
@@ -1304,9 +1646,9 @@ func (b *Bank) getPendingTransactionSenderBank(stub shim.ChaincodeStubInterface)
 
 }
 
-//==========================================================================
+// ==========================================================================
 // getPendingTransactionReceiverBank of organization MSPID
-//==========================================================================
+// ==========================================================================
 func (b *Bank) getPendingTransactionReceiverBank(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	val, ok, err := cid.GetAttributeValue(stub, "branchCode")
 	if err != nil {
@@ -1315,7 +1657,7 @@ func (b *Bank) getPendingTransactionReceiverBank(stub shim.ChaincodeStubInterfac
 	if !ok {
 		return shim.Error(`{"status": 500 , "message": "Client has no Attribute BranchCode"}`)
 	}
-	resultsIterator, err := stub.GetStateByRange("", "")
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(transactionKeyType, []string{})
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	}
@@ -1346,6 +1688,10 @@ func (b *Bank) getPendingTransactionReceiverBank(stub shim.ChaincodeStubInterfac
 		if err != nil {
 			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 		}
+		// receiver side amount is what is left after fees
+		if transaction.ReceiverAmount > 0 {
+			transaction.Amount = transaction.ReceiverAmount
+		}
 		if mspId == "HBLTR" {
 			transaction.Amount = transaction.Amount / 28
 		} else if mspId == "HBLPK" {
@@ -1399,7 +1745,7 @@ func (b *Bank) updateTransactionStatusSender(stub shim.ChaincodeStubInterface, a
 	}
 
 	transaction := Transaction{}
-	transactionAsBytes, _ := stub.GetState(args[0])
+	transactionAsBytes, _ := getTransactionState(stub, args[0])
 	json.Unmarshal(transactionAsBytes, &transaction)
 	// senderkyc := Kyc{}
 	//_, _ := getKycOfBankAccount(stub, transaction.SenderAccount)
@@ -1411,6 +1757,9 @@ func (b *Bank) updateTransactionStatusSender(stub shim.ChaincodeStubInterface, a
 	if branchCode != transaction.SenderBranchName {
 		return shim.Error(`{"status": 403 , "message":"You are not allowed to Change Transaction Status" }`)
 	}
+	if transaction.TransactionStatus == AWAITINGSIGNATURES || transaction.TransactionStatus == EXPIRED {
+		return shim.Error(`{"status": 403 , "message": "Transaction is ` + string(transaction.TransactionStatus) + `"}`)
+	}
 	_, ok, err = cid.GetAttributeValue(stub, "userType")
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
@@ -1431,8 +1780,7 @@ func (b *Bank) updateTransactionStatusSender(stub shim.ChaincodeStubInterface, a
 	} else {
 		return shim.Error(`{"status": 500 , "message": "Please Provide Rejected , Approved  or pending Status"}`)
 	}
-	asBytes, _ := json.Marshal(transaction)
-	err = stub.PutState(transaction.TransactionID, asBytes)
+	err = putTransaction(stub, transaction)
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	}
@@ -1459,7 +1807,7 @@ func (b *Bank) CancelTransacation(stub shim.ChaincodeStubInterface, args []strin
 	}
 
 	transaction := Transaction{}
-	transactionAsBytes, _ := stub.GetState(args[0])
+	transactionAsBytes, _ := getTransactionState(stub, args[0])
 	json.Unmarshal(transactionAsBytes, &transaction)
 	// senderkyc := Kyc{}
 	//_, _ := getKycOfBankAccount(stub, transaction.SenderAccount)
@@ -1468,6 +1816,10 @@ func (b *Bank) CancelTransacation(stub shim.ChaincodeStubInterface, args []strin
 	if transaction.TransactionStatus == REJECTEDRECEIVERBANK || transaction.TransactionStatus == ACCEPTEDRECEIVERBANK {
 		return shim.Error(`{"status": 403 , "message": "Can not change because transaction has been processed on receiver Bank"}`)
 	}
+	// refund is counted on reversed transfer once written , it can not be taken back
+	if transaction.ReversalOf != "" {
+		return shim.Error(`{"status": 403 , "message": "Reversal of ` + transaction.ReversalOf + ` can not be cancelled"}`)
+	}
 	mspId, _ := cid.GetMSPID(stub)
 	//branchCode := cid.AssertAttributeValue(stub, "branchCode"+"_"+mspId, transaction.SenderBranchName)
 	branchCode := mspId + "_" + val
@@ -1487,8 +1839,7 @@ func (b *Bank) CancelTransacation(stub shim.ChaincodeStubInterface, args []strin
 	} else {
 		return shim.Error(`{"status": 500 , "message": "Please Provide Status cancelled"}`)
 	}
-	asBytes, _ := json.Marshal(transaction)
-	err = stub.PutState(transaction.TransactionID, asBytes)
+	err = putTransaction(stub, transaction)
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	}
@@ -1514,7 +1865,7 @@ func (b *Bank) processPendingTransactionReceiver(stub shim.ChaincodeStubInterfac
 		return shim.Error(`{"status": 500 , "message": "Client has no Attribute Branch Code"}`)
 	}
 	transaction := Transaction{}
-	transactionAsBytes, _ := stub.GetState(args[0])
+	transactionAsBytes, _ := getTransactionState(stub, args[0])
 	json.Unmarshal(transactionAsBytes, &transaction)
 	// account:= BranchAccount{}
 	//_, _ = getKycOfBankAccount(stub, transaction.ReceiverAccount)
@@ -1528,11 +1879,17 @@ func (b *Bank) processPendingTransactionReceiver(stub shim.ChaincodeStubInterfac
 	if transaction.TransactionStatus == REJECTEDSENDERBANK {
 		return shim.Error(`{"status": 504 , "message": "Sorry Sender Bank has Rejected this transaction"}`)
 	}
+	if transaction.TransactionStatus == AWAITINGSIGNATURES || transaction.TransactionStatus == EXPIRED {
+		return shim.Error(`{"status": 403 , "message": "Transaction is ` + string(transaction.TransactionStatus) + `"}`)
+	}
 	// if receiverKyc.Accounts[transaction.ReceiverAccount].MSPID
 	if args[1] == "rejected" || args[1] == "Rejected" {
 		transaction.TransactionStatus = REJECTEDRECEIVERBANK
 		transaction.Comment = args[2]
 	} else if args[1] == "Approved" || args[1] == "approved" {
+		if !isRouteDelivered(transaction) {
+			return shim.Error(`{"status": 403 , "message": "Transfer legs through correspondent banks are not confirmed"}`)
+		}
 		transaction.TransactionStatus = ACCEPTEDRECEIVERBANK
 		transaction.Comment = args[2]
 	} else if args[1] == "pending" {
@@ -1543,13 +1900,30 @@ func (b *Bank) processPendingTransactionReceiver(stub shim.ChaincodeStubInterfac
 
 	}
 	transaction.RecieverEDD = args[3]
-	transactionBytes, err := json.Marshal(transaction)
+	err = putTransaction(stub, transaction)
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	}
-	err = stub.PutState(transaction.TransactionID, transactionBytes)
-	if err != nil {
-		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	if transaction.TransactionStatus == REJECTEDRECEIVERBANK && transaction.RecieverEDD != "" {
+		// rejection after EDD raises risk of receiver
+		entry, err := getAccountIndex(stub, transaction.ReceiverAccount)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		receiverAsBytes, err := getKycStateOf(stub, entry.MSPID, entry.CustID)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		receiverKyc := Kyc{}
+		json.Unmarshal(receiverAsBytes, &receiverKyc)
+		if receiverKyc.CustID != "" {
+			if err := computeCustomerRiskScore(stub, &receiverKyc, transaction); err != nil {
+				return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+			}
+			if err := putKyc(stub, receiverKyc); err != nil {
+				return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+			}
+		}
 	}
 	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
 }
@@ -1567,7 +1941,7 @@ func (b *Bank) getRejectTransactionOfSenderBank(stub shim.ChaincodeStubInterface
 	mspId, _ := cid.GetMSPID(stub)
 	branchCode := mspId + "_" + val
 	fmt.Println(branchCode)
-	resultsIterator, err := stub.GetStateByRange("", "")
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(transactionKeyType, []string{})
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	}
@@ -1637,7 +2011,7 @@ func (b *Bank) getRejectTransactionOfReceiverBank(stub shim.ChaincodeStubInterfa
 	mspId, _ := cid.GetMSPID(stub)
 	branchCode := mspId + "_" + val
 	fmt.Println(branchCode)
-	resultsIterator, err := stub.GetStateByRange("", "")
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(transactionKeyType, []string{})
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	}
@@ -1710,7 +2084,7 @@ func (b *Bank) getTransactionByID(stub shim.ChaincodeStubInterface, args []strin
 	if len(args) != 1 {
 		return shim.Error(`{"status": 500 , "message":"Please Provide 1 argument"}`)
 	}
-	asBytes, err := stub.GetState(args[0])
+	asBytes, err := getTransactionState(stub, args[0])
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	}
@@ -1735,7 +2109,7 @@ func (b *Bank) getNotificationFromReceiverBank(stub shim.ChaincodeStubInterface,
 	mspId, _ := cid.GetMSPID(stub)
 	branchCode := mspId + "_" + val
 	fmt.Println(branchCode)
-	resultsIterator, err := stub.GetStateByRange("", "")
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(transactionKeyType, []string{})
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	}
@@ -1795,7 +2169,7 @@ func (b *Bank) getNotificationFromReceiverBank(stub shim.ChaincodeStubInterface,
 //getKycOfBankAccount: this function will return kyc of account provided
 //=======================================================================
 func getKycOfBankAccount(stub shim.ChaincodeStubInterface, accNo string) (bool, Kyc) {
-	resultsIterator, err := stub.GetStateByRange("", "")
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(kycKeyType, []string{})
 	if err != nil {
 		return false, Kyc{}
 	}
@@ -1827,11 +2201,15 @@ func getKycOfBankAccount(stub shim.ChaincodeStubInterface, accNo string) (bool,
 //========================================================================
 func writeAccountTypeLedger(stub shim.ChaincodeStubInterface, accountTypes []AccountType) pb.Response {
 	for i := 0; i < len(accountTypes); i++ {
-		key := accountTypes[i].AccountTypeName
+		key, err := accountTypeKey(stub, accountTypes[i].AccountTypeName)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message" : "` + string(err.Error()) + `"}`)
+		}
 		chkBytes, _ := stub.GetState(key)
 		if chkBytes == nil { //only add if it is not already present
+			accountTypes[i].Version = 1
 			asBytes, _ := json.Marshal(accountTypes[i])
-			err := stub.PutState(accountTypes[i].AccountTypeName, asBytes)
+			err := stub.PutState(key, asBytes)
 			if err != nil {
 				return shim.Error(`{"status": 500 , "message" : "` + string(err.Error()) + `"}`)
 			}
@@ -1843,6 +2221,38 @@ func writeAccountTypeLedger(stub shim.ChaincodeStubInterface, accountTypes []Acc
 	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
 }
 
+//=========================================================================
+//getTxTime: this func will return the ledger timestamp of current proposal
+//so that every endorsing peer works out the same time
+//=========================================================================
+func getTxTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC(), nil
+}
+
+//=====================================================================
+//auditStamp: this func will return ledger time of transaction and
+//identity of client (mspid_id) to stamp on records it writes
+//=====================================================================
+func auditStamp(stub shim.ChaincodeStubInterface) (string, string, error) {
+	now, err := getTxTime(stub)
+	if err != nil {
+		return "", "", err
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return "", "", err
+	}
+	id, err := cid.GetID(stub)
+	if err != nil {
+		return "", "", err
+	}
+	return now.Format(time.RFC3339), mspID + "_" + id, nil
+}
+
 //====================================================================
 //getCertDetail: this function will return client(requester) details
 //====================================================================
@@ -1874,7 +2284,7 @@ func (b *Bank) getTransactionByAccountNumber(stub shim.ChaincodeStubInterface, a
 		accountNumber = args[0]
 		status = TransactionStatus("")
 	}
-	resultsIterator, err := stub.GetStateByRange("", "")
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(transactionKeyType, []string{})
 	if err != nil {
 		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
 	}
@@ -1950,9 +2360,9 @@ func (b *Bank) getTransactionByAccountNumber(stub shim.ChaincodeStubInterface, a
 func (b *Bank) Init(stub shim.ChaincodeStubInterface) pb.Response {
 
 	accountTypes := []AccountType{
-		{AccountTypeName: "Individual", Limit: 20000},
-		{AccountTypeName: "Business", Limit: 500000},
-		{AccountTypeName: "Joint", Limit: 10000},
+		{AccountTypeName: "Individual", Limit: 20000, Category: INDIVIDUALCATEGORY, MaxOwners: 1},
+		{AccountTypeName: "Business", Limit: 500000, Category: BUSINESSCATEGORY},
+		{AccountTypeName: "Joint", Limit: 10000, Category: JOINTCATEGORY, MaxOwners: 4},
 	}
 	writeAccountTypeLedger(stub, accountTypes)
 	return shim.Success(nil)
@@ -1963,6 +2373,9 @@ func (b *Bank) Init(stub shim.ChaincodeStubInterface) pb.Response {
 //=======================================
 func (b *Bank) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 	function, args := stub.GetFunctionAndParameters()
+	if isRegulator(stub) && !regulatorFunctions[function] {
+		return shim.Error(`{"status": 403 , "message": "Regulator is only allowed read only functions"}`)
+	}
 	if function == "addkyc" {
 		return b.addKyc(stub, args)
 	} else if function == "addBranch" {
@@ -2029,6 +2442,164 @@ func (b *Bank) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 		return b.verifyEDD(stub, args)
 	} else if function == "verifyReceiverEDD" {
 		return b.verifyReceiverEDD(stub, args)
+	} else if function == "getKycDueForReview" {
+		return b.getKycDueForReview(stub, args)
+	} else if function == "updateCustomerRiskFactors" {
+		return b.updateCustomerRiskFactors(stub, args)
+	} else if function == "getCustomerRiskProfile" {
+		return b.getCustomerRiskProfile(stub, args)
+	} else if function == "createSuspiciousTransactionReport" {
+		return b.createSuspiciousTransactionReport(stub, args)
+	} else if function == "getSuspiciousTransactionReport" {
+		return b.getSuspiciousTransactionReport(stub, args)
+	} else if function == "getCorridorVolumes" {
+		return b.getCorridorVolumes(stub, args)
+	} else if function == "getFlaggedTransactions" {
+		return b.getFlaggedTransactions(stub, args)
+	} else if function == "getBlackListChanges" {
+		return b.getBlackListChanges(stub, args)
+	} else if function == "getKycApprovalStatistics" {
+		return b.getKycApprovalStatistics(stub, args)
+	} else if function == "setCtrThreshold" {
+		return b.setCtrThreshold(stub, args)
+	} else if function == "getCtrThresholds" {
+		return b.getCtrThresholds(stub, args)
+	} else if function == "getCurrencyTransactionReports" {
+		return b.getCurrencyTransactionReports(stub, args)
+	} else if function == "getAccountOwners" {
+		return b.getAccountOwners(stub, args)
+	} else if function == "signTransfer" {
+		return b.signTransfer(stub, args)
+	} else if function == "setSignatureTimeout" {
+		return b.setSignatureTimeout(stub, args)
+	} else if function == "addCorporateEntity" {
+		return b.addCorporateEntity(stub, args)
+	} else if function == "setEntityShareholders" {
+		return b.setEntityShareholders(stub, args)
+	} else if function == "linkBusinessAccountEntity" {
+		return b.linkBusinessAccountEntity(stub, args)
+	} else if function == "getCorporateEntity" {
+		return b.getCorporateEntity(stub, args)
+	} else if function == "getUltimateBeneficialOwners" {
+		return b.getUltimateBeneficialOwners(stub, args)
+	} else if function == "freezeAccount" {
+		return b.freezeAccount(stub, args)
+	} else if function == "unfreezeAccount" {
+		return b.unfreezeAccount(stub, args)
+	} else if function == "markAccountDormant" {
+		return b.markAccountDormant(stub, args)
+	} else if function == "closeAccount" {
+		return b.closeAccount(stub, args)
+	} else if function == "reopenAccount" {
+		return b.reopenAccount(stub, args)
+	} else if function == "getDormancyCandidates" {
+		return b.getDormancyCandidates(stub, args)
+	} else if function == "createAccountType" {
+		return b.createAccountType(stub, args)
+	} else if function == "updateAccountType" {
+		return b.updateAccountType(stub, args)
+	} else if function == "deleteAccountType" {
+		return b.deleteAccountType(stub, args)
+	} else if function == "getAccountType" {
+		return b.getAccountType(stub, args)
+	} else if function == "getAccountTypes" {
+		return b.getAccountTypes(stub, args)
+	} else if function == "getAccountTypeHistory" {
+		return b.getAccountTypeHistory(stub, args)
+	} else if function == "addLimitOverride" {
+		return b.addLimitOverride(stub, args)
+	} else if function == "revokeLimitOverride" {
+		return b.revokeLimitOverride(stub, args)
+	} else if function == "getAccountLimits" {
+		return b.getAccountLimits(stub, args)
+	} else if function == "grantCustomerConsent" {
+		return b.grantCustomerConsent(stub, args)
+	} else if function == "revokeCustomerConsent" {
+		return b.revokeCustomerConsent(stub, args)
+	} else if function == "getConsolidatedCustomerView" {
+		return b.getConsolidatedCustomerView(stub, args)
+	} else if function == "migrateKeys" {
+		return b.migrateKeys(stub, args)
+	} else if function == "setIdentitySalt" {
+		return b.setIdentitySalt(stub, args)
+	} else if function == "setCustomerIdentity" {
+		return b.setCustomerIdentity(stub, args)
+	} else if function == "getLinkedCustomers" {
+		return b.getLinkedCustomers(stub, args)
+	} else if function == "setBranchStatus" {
+		return b.setBranchStatus(stub, args)
+	} else if function == "updateBranchHierarchy" {
+		return b.updateBranchHierarchy(stub, args)
+	} else if function == "getBranches" {
+		return b.getBranches(stub, args)
+	} else if function == "getBranchHierarchy" {
+		return b.getBranchHierarchy(stub, args)
+	} else if function == "setCorrespondentRoute" {
+		return b.setCorrespondentRoute(stub, args)
+	} else if function == "getCorrespondentRoutes" {
+		return b.getCorrespondentRoutes(stub, args)
+	} else if function == "settleTransferLeg" {
+		return b.settleTransferLeg(stub, args)
+	} else if function == "confirmTransferLeg" {
+		return b.confirmTransferLeg(stub, args)
+	} else if function == "getTransferLegs" {
+		return b.getTransferLegs(stub, args)
+	} else if function == "setFeeSchedule" {
+		return b.setFeeSchedule(stub, args)
+	} else if function == "removeFeeSchedule" {
+		return b.removeFeeSchedule(stub, args)
+	} else if function == "getFeeSchedules" {
+		return b.getFeeSchedules(stub, args)
+	} else if function == "quoteTransferFees" {
+		return b.quoteTransferFees(stub, args)
+	} else if function == "createStandingInstruction" {
+		return b.createStandingInstruction(stub, args)
+	} else if function == "cancelStandingInstruction" {
+		return b.cancelStandingInstruction(stub, args)
+	} else if function == "getStandingInstructions" {
+		return b.getStandingInstructions(stub, args)
+	} else if function == "executeDueInstructions" {
+		return b.executeDueInstructions(stub, args)
+	} else if function == "submitBatchTransfer" {
+		return b.submitBatchTransfer(stub, args)
+	} else if function == "updateBatchTransferStatus" {
+		return b.updateBatchTransferStatus(stub, args)
+	} else if function == "getBatchTransfer" {
+		return b.getBatchTransfer(stub, args)
+	} else if function == "getTransactionByIdempotencyKey" {
+		return b.getTransactionByIdempotencyKey(stub, args)
+	} else if function == "setTransferTTL" {
+		return b.setTransferTTL(stub, args)
+	} else if function == "getTransferTTLs" {
+		return b.getTransferTTLs(stub, args)
+	} else if function == "expireStaleTransfers" {
+		return b.expireStaleTransfers(stub, args)
+	} else if function == "requestTransferReversal" {
+		return b.requestTransferReversal(stub, args)
+	} else if function == "processTransferReversal" {
+		return b.processTransferReversal(stub, args)
+	} else if function == "getTransferReversal" {
+		return b.getTransferReversal(stub, args)
+	} else if function == "raiseDispute" {
+		return b.raiseDispute(stub, args)
+	} else if function == "addDisputeEvidence" {
+		return b.addDisputeEvidence(stub, args)
+	} else if function == "addDisputeMessage" {
+		return b.addDisputeMessage(stub, args)
+	} else if function == "closeDispute" {
+		return b.closeDispute(stub, args)
+	} else if function == "getDispute" {
+		return b.getDispute(stub, args)
+	} else if function == "getOpenDisputes" {
+		return b.getOpenDisputes(stub, args)
+	} else if function == "setDisputeResponseDays" {
+		return b.setDisputeResponseDays(stub, args)
+	} else if function == "confirmPayee" {
+		return b.confirmPayee(stub, args)
+	} else if function == "setPayeeCheckMode" {
+		return b.setPayeeCheckMode(stub, args)
+	} else if function == "indexAccountTransactions" {
+		return b.indexAccountTransactions(stub, args)
 	} else {
 		return shim.Error(`{"status": 500 , "message": "Not smart contract function ......."}`)
 	}
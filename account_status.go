@@ -0,0 +1,362 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//===============================================================
+// AccountStatus : lifecycle status of account , empty is active
+//===============================================================
+type AccountStatus string
+
+const (
+	ACTIVE  AccountStatus = "active"
+	FROZEN  AccountStatus = "frozen"
+	DORMANT AccountStatus = "dormant"
+	CLOSED  AccountStatus = "closed"
+)
+
+const defaultDormancyDays = 365
+
+//=============================================================================
+// accountStatusTransitions : statuses an account can move to from each status.
+// Frozen account has to be unfrozen before it can be closed
+//=============================================================================
+var accountStatusTransitions = map[AccountStatus][]AccountStatus{
+	ACTIVE:  {FROZEN, DORMANT, CLOSED},
+	FROZEN:  {ACTIVE},
+	DORMANT: {ACTIVE, FROZEN, CLOSED},
+	CLOSED:  {ACTIVE},
+}
+
+//=====================================================================
+// accountStatusReasons : reason codes accepted for each target status
+//=====================================================================
+var accountStatusReasons = map[AccountStatus][]string{
+	FROZEN:  {"legal-hold", "court-order", "fraud-suspected", "regulatory", "other"},
+	DORMANT: {"inactivity", "other"},
+	CLOSED:  {"customer-request", "deceased", "bank-decision", "regulatory", "other"},
+	ACTIVE:  {"hold-released", "customer-request", "reactivated", "other"},
+}
+
+//=================================================================
+//AccountStatusChange : one change of account status with actor
+//=================================================================
+type AccountStatusChange struct {
+	From      AccountStatus `json:"from"`
+	To        AccountStatus `json:"to"`
+	Reason    string        `json:"reason"`
+	Comment   string        `json:"comment"`
+	ChangedBy string        `json:"changed_by"`
+	UserType  string        `json:"user_type"`
+	ChangedAt string        `json:"changed_at"`
+}
+
+//=================================================================
+//status: this func will return status of account , active when empty
+//=================================================================
+func (a Account) status() AccountStatus {
+	if a.Status == "" {
+		return ACTIVE
+	}
+	return a.Status
+}
+
+//==========================================================================
+//isAccountBlocked: frozen and closed accounts can not send or receive money
+//==========================================================================
+func (a Account) isAccountBlocked() bool {
+	return a.status() == FROZEN || a.status() == CLOSED
+}
+
+//===================================================================================
+//changeAccountStatus: this func will move account to status after checking transition
+//and reason code , actor and time are kept in status history of account. Only bank of
+//account can change it and when from statuses are given account must currently be in
+//one of them
+//args[0]: custID
+//args[1]: account number
+//args[2]: reason code
+//args[3]: (optional) comment
+//===================================================================================
+func changeAccountStatus(stub shim.ChaincodeStubInterface, args []string, to AccountStatus, fromStatuses ...AccountStatus) pb.Response {
+	if len(args) != 3 && len(args) != 4 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide CustID , Account Number , Reason and optional Comment"}`)
+	}
+	userType, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok {
+		return shim.Error(`{"status": 403 , "message":"Client has no attribute UserType"}`)
+	}
+	customerAsBytes, _ := getKycState(stub, args[0])
+	kyc := Kyc{}
+	json.Unmarshal(customerAsBytes, &kyc)
+	if kyc.CustID == "" {
+		return shim.Error(`{"status": 404 , "message": "Customer id Not Found"}`)
+	}
+	account, err := resolveAccount(stub, kyc.Accounts[args[1]])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if account.AccountNumber == "" {
+		return shim.Error(`{"status": 404 , "message": "Account Number does not belong to this Customer"}`)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if bankOfBranch(account.BranchCode) != mspID {
+		return shim.Error(`{"status": 403 , "message": "Account does not belong to ` + mspID + `"}`)
+	}
+	from := account.status()
+	allowed := len(fromStatuses) == 0
+	for _, status := range fromStatuses {
+		if status == from {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return shim.Error(`{"status": 403 , "message": "Account is ` + string(from) + `"}`)
+	}
+	allowed = false
+	for _, status := range accountStatusTransitions[from] {
+		if status == to {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return shim.Error(`{"status": 403 , "message": "Account can not move from ` + string(from) + ` to ` + string(to) + `"}`)
+	}
+	reason := strings.ToLower(args[2])
+	validReason := false
+	for _, code := range accountStatusReasons[to] {
+		if code == reason {
+			validReason = true
+		}
+	}
+	if !validReason {
+		return shim.Error(`{"status": 500 , "message": "Please Provide valid Reason (` + strings.Join(accountStatusReasons[to], " , ") + `)"}`)
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	actor, err := cid.GetID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	change := AccountStatusChange{
+		From:      from,
+		To:        to,
+		Reason:    reason,
+		ChangedBy: actor,
+		UserType:  userType,
+		ChangedAt: now.Format(time.RFC3339),
+	}
+	if len(args) == 4 {
+		change.Comment = args[3]
+	}
+	account.Status = to
+	account.StatusHistory = append(account.StatusHistory, change)
+	if err := putAccount(stub, &kyc, account); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	err = putKyc(stub, kyc)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	asBytes, _ := json.Marshal(change)
+	stub.SetEvent("evtaccountstatus", asBytes)
+	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
+}
+
+//=============================================================
+//freezeAccount: this func will freeze account e.g. legal hold
+//=============================================================
+func (b *Bank) freezeAccount(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	return changeAccountStatus(stub, args, FROZEN)
+}
+
+//=============================================================
+//unfreezeAccount: this func will release freeze on account
+//=============================================================
+func (b *Bank) unfreezeAccount(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	return changeAccountStatus(stub, args, ACTIVE, FROZEN)
+}
+
+//=============================================================
+//markAccountDormant: this func will mark inactive account dormant
+//=============================================================
+func (b *Bank) markAccountDormant(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	return changeAccountStatus(stub, args, DORMANT)
+}
+
+//=============================================================
+//closeAccount: this func will close account
+//=============================================================
+func (b *Bank) closeAccount(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	return changeAccountStatus(stub, args, CLOSED)
+}
+
+//=============================================================
+//reopenAccount: this func will reopen closed or dormant account
+//=============================================================
+func (b *Bank) reopenAccount(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	return changeAccountStatus(stub, args, ACTIVE, CLOSED, DORMANT)
+}
+
+//=================================================================
+//DormancyCandidate : active account without recent transactions
+//=================================================================
+type DormancyCandidate struct {
+	AccountNumber   string `json:"account_number"`
+	BranchCode      string `json:"branch"`
+	OwnerName       string `json:"owner_name"`
+	LastTransaction string `json:"last_transaction"`
+}
+
+//=========================================================================
+//recordTime: this func will parse time stamped on record , record written
+//before it was stamped gets ledger time of its first write on key
+//=========================================================================
+func recordTime(stub shim.ChaincodeStubInterface, stamp string, key string) (time.Time, error) {
+	if at, err := time.Parse(time.RFC3339, stamp); err == nil {
+		return at, nil
+	}
+	return historyStageStart(stub, key, func([]byte) bool { return true })
+}
+
+//==========================================================================
+//lastAccountActivity: this func will return time account was opened and
+//time of its last transaction as sender or receiver , zero when it never
+//transacted
+//==========================================================================
+func lastAccountActivity(stub shim.ChaincodeStubInterface, account Account) (time.Time, time.Time, error) {
+	indexKey, err := stub.CreateCompositeKey(accountIndexKeyType, []string{account.AccountNumber})
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	opened, err := recordTime(stub, account.CreatedAt, indexKey)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	ids, err := getAccountTransactionIDs(stub, account.AccountNumber)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	last := time.Time{}
+	for _, txID := range ids {
+		asBytes, err := getTransactionState(stub, txID)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		transaction := Transaction{}
+		json.Unmarshal(asBytes, &transaction)
+		if transaction.TransactionID == "" {
+			continue
+		}
+		key, err := transactionKey(stub, txID)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		created, err := recordTime(stub, transaction.CreatedAt, key)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		if created.After(last) {
+			last = created
+		}
+	}
+	return opened, last, nil
+}
+
+//========================================================================================
+//getDormancyCandidates: this func will return active accounts of caller bank whose last
+//transaction , as sender or receiver , is older than given days. Opening of account counts
+//as activity and accounts which never transacted are returned with empty last transaction.
+//Regulator gets accounts of every bank
+//args[0]: (optional) days of inactivity , default 365
+//========================================================================================
+func (b *Bank) getDormancyCandidates(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) > 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide optional days of inactivity"}`)
+	}
+	days := defaultDormancyDays
+	if len(args) == 1 {
+		var err error
+		days, err = strconv.Atoi(args[0])
+		if err != nil || days <= 0 {
+			return shim.Error(`{"status": 500 , "message": "Can Not Convert Days to Number"}`)
+		}
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	regulator := isRegulator(stub)
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	cutoff := now.AddDate(0, 0, -days)
+
+	bank := []string{mspID}
+	if regulator {
+		bank = []string{}
+	}
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(kycKeyType, bank)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	defer resultsIterator.Close()
+
+	seen := make(map[string]bool)
+	result := []DormancyCandidate{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		kyc := Kyc{}
+		json.Unmarshal(queryResponse.Value, &kyc)
+		for _, account := range kyc.Accounts {
+			account, _ = resolveAccount(stub, account)
+			if account.AccountNumber == "" || account.status() != ACTIVE || seen[account.AccountNumber] {
+				continue
+			}
+			seen[account.AccountNumber] = true
+			if !regulator && bankOfBranch(account.BranchCode) != mspID {
+				continue
+			}
+			opened, last, err := lastAccountActivity(stub, account)
+			if err != nil {
+				return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+			}
+			if !opened.Before(cutoff) || !last.Before(cutoff) {
+				continue
+			}
+			candidate := DormancyCandidate{
+				AccountNumber: account.AccountNumber,
+				BranchCode:    account.BranchCode,
+				OwnerName:     account.OwnerName,
+			}
+			if !last.IsZero() {
+				candidate.LastTransaction = last.Format(time.RFC3339)
+			}
+			result = append(result, candidate)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].AccountNumber < result[j].AccountNumber })
+	asBytes, _ := json.Marshal(result)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
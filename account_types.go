@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//===========================================================================
+// Account types are stored under accountTypeKeyType composite key by lower
+// case name so they can not collide with customer ids. Every update keeps the
+// previous record under accountTypeVersionKeyType
+//===========================================================================
+const (
+	accountTypeKeyType        = "accountType"
+	accountTypeVersionKeyType = "accountTypeVersion"
+)
+
+//=====================================================================
+// AccountCategory : how account of a type is opened and owned
+//=====================================================================
+type AccountCategory string
+
+const (
+	INDIVIDUALCATEGORY AccountCategory = "individual"
+	JOINTCATEGORY      AccountCategory = "joint"
+	BUSINESSCATEGORY   AccountCategory = "business"
+)
+
+//==================================================================
+//accountTypeKey: this func will return namespaced key of account type
+//==================================================================
+func accountTypeKey(stub shim.ChaincodeStubInterface, name string) (string, error) {
+	return stub.CreateCompositeKey(accountTypeKeyType, []string{strings.ToLower(strings.TrimSpace(name))})
+}
+
+//==========================================================================
+//category: this func will return category of account type , types written
+//before categories existed are recognised by their name
+//==========================================================================
+func (t AccountType) category() AccountCategory {
+	if t.Category != "" {
+		return t.Category
+	}
+	switch strings.ToLower(t.AccountTypeName) {
+	case "business":
+		return BUSINESSCATEGORY
+	case "joint":
+		return JOINTCATEGORY
+	}
+	return INDIVIDUALCATEGORY
+}
+
+//================================================================
+//isShared: joint and business accounts have owners and a mandate
+//================================================================
+func (t AccountType) isShared() bool {
+	return t.category() == JOINTCATEGORY || t.category() == BUSINESSCATEGORY
+}
+
+//===================================================================
+//allowsCurrency: this func will check currency is allowed for type
+//===================================================================
+func (t AccountType) allowsCurrency(currency string) bool {
+	if len(t.AllowedCurrencies) == 0 {
+		return true
+	}
+	for _, allowed := range t.AllowedCurrencies {
+		if strings.EqualFold(allowed, currency) {
+			return true
+		}
+	}
+	return false
+}
+
+//==================================================================
+//getAccountType: this func will read account type by name
+//==================================================================
+func getAccountType(stub shim.ChaincodeStubInterface, name string) (AccountType, error) {
+	accountType := AccountType{}
+	key, err := accountTypeKey(stub, name)
+	if err != nil {
+		return accountType, err
+	}
+	asBytes, err := stub.GetState(key)
+	if err != nil {
+		return accountType, err
+	}
+	json.Unmarshal(asBytes, &accountType)
+	return accountType, nil
+}
+
+//=========================================================================
+//lookupAccountType: this func will return active account type or error
+//=========================================================================
+func lookupAccountType(stub shim.ChaincodeStubInterface, name string) (AccountType, error) {
+	accountType, err := getAccountType(stub, name)
+	if err != nil {
+		return accountType, err
+	}
+	if accountType.AccountTypeName == "" {
+		return accountType, errors.New("Account Type Not Found " + name)
+	}
+	if accountType.Deleted {
+		return accountType, errors.New("Account Type " + accountType.AccountTypeName + " is no longer offered")
+	}
+	return accountType, nil
+}
+
+//==================================================================================
+//putAccountType: this func will write account type and keep previous version
+//==================================================================================
+func putAccountType(stub shim.ChaincodeStubInterface, accountType AccountType, previous AccountType) error {
+	if previous.AccountTypeName != "" {
+		versionKey, err := stub.CreateCompositeKey(accountTypeVersionKeyType, []string{strings.ToLower(accountType.AccountTypeName), fmt.Sprintf("%06d", previous.Version)})
+		if err != nil {
+			return err
+		}
+		asBytes, _ := json.Marshal(previous)
+		if err := stub.PutState(versionKey, asBytes); err != nil {
+			return err
+		}
+	}
+	key, err := accountTypeKey(stub, accountType.AccountTypeName)
+	if err != nil {
+		return err
+	}
+	asBytes, _ := json.Marshal(accountType)
+	return stub.PutState(key, asBytes)
+}
+
+//====================================================================
+//validateAccountType: this func will check attributes of account type
+//====================================================================
+func validateAccountType(accountType *AccountType) error {
+	accountType.AccountTypeName = strings.TrimSpace(accountType.AccountTypeName)
+	if accountType.AccountTypeName == "" {
+		return errors.New("Please Provide AccountTypeName")
+	}
+	switch AccountCategory(strings.ToLower(string(accountType.Category))) {
+	case INDIVIDUALCATEGORY, JOINTCATEGORY, BUSINESSCATEGORY:
+		accountType.Category = AccountCategory(strings.ToLower(string(accountType.Category)))
+	default:
+		return errors.New("Please Provide category (individual , joint , business)")
+	}
+	if accountType.Limit < 0 {
+		return errors.New("Limit can not be negative")
+	}
+	if accountType.MaxOwners < 0 {
+		return errors.New("Max Owners can not be negative")
+	}
+	if accountType.Category == INDIVIDUALCATEGORY {
+		accountType.MaxOwners = 1
+	}
+	for i := range accountType.AllowedCurrencies {
+		accountType.AllowedCurrencies[i] = strings.ToUpper(accountType.AllowedCurrencies[i])
+	}
+	return nil
+}
+
+//===============================================================================
+//saveAccountType: this func will validate and store new version of account type ,
+//registry is shared by every bank so only admin user can change it
+//===============================================================================
+func saveAccountType(stub shim.ChaincodeStubInterface, accountType AccountType, create bool) pb.Response {
+	userType, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok || strings.ToLower(userType) != "admin" {
+		return shim.Error(`{"status": 403 , "message": "Only admin user can change account types"}`)
+	}
+	if err := validateAccountType(&accountType); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	previous, err := getAccountType(stub, accountType.AccountTypeName)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if create && previous.AccountTypeName != "" && !previous.Deleted {
+		return shim.Error(`{"status": 403 , "message": "Account Type already Exist ` + accountType.AccountTypeName + `"}`)
+	}
+	if !create && (previous.AccountTypeName == "" || previous.Deleted) {
+		return shim.Error(`{"status": 404 , "message": "Account Type Not Found"}`)
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	id, _ := cid.GetID(stub)
+	accountType.Version = previous.Version + 1
+	accountType.UpdatedBy = id
+	accountType.UpdatedAt = now.Format(time.RFC3339)
+	if err := putAccountType(stub, accountType, previous); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
+}
+
+//==================================================================================
+//createAccountType: this func will add account type to registry
+//args[0]: {"AccountTypeName": "Savings", "category": "individual", "limit": 20000,
+//
+//	"max_owners": 1, "required_documents": ["CNIC"], "allowed_currencies": ["PKR"]}
+//
+//==================================================================================
+func (b *Bank) createAccountType(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 1 Argument"}`)
+	}
+	accountType := AccountType{}
+	if err := json.Unmarshal([]byte(args[0]), &accountType); err != nil {
+		return shim.Error(`{"status": 500 , "message": "Please check Account Type ` + string(err.Error()) + `"}`)
+	}
+	accountType.Deleted = false
+	return saveAccountType(stub, accountType, true)
+}
+
+//==================================================================================
+//updateAccountType: this func will replace attributes of account type , accounts
+//already opened keep the version they were opened with
+//args[0]: same payload as createAccountType
+//==================================================================================
+func (b *Bank) updateAccountType(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 1 Argument"}`)
+	}
+	accountType := AccountType{}
+	if err := json.Unmarshal([]byte(args[0]), &accountType); err != nil {
+		return shim.Error(`{"status": 500 , "message": "Please check Account Type ` + string(err.Error()) + `"}`)
+	}
+	accountType.Deleted = false
+	return saveAccountType(stub, accountType, false)
+}
+
+//==================================================================================
+//deleteAccountType: this func will stop offering account type. Record is kept as a
+//new version marked deleted because opened accounts still refer to it
+//args[0]: account type name
+//==================================================================================
+func (b *Bank) deleteAccountType(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 1 Argument"}`)
+	}
+	accountType, err := lookupAccountType(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 404 , "message": "` + string(err.Error()) + `"}`)
+	}
+	accountType.Category = accountType.category()
+	accountType.Deleted = true
+	return saveAccountType(stub, accountType, false)
+}
+
+//==================================================================
+//getAccountType: this func will return account type from registry
+//args[0]: account type name
+//==================================================================
+func (b *Bank) getAccountType(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 1 Argument"}`)
+	}
+	accountType, err := getAccountType(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if accountType.AccountTypeName == "" {
+		return shim.Error(`{"status": 404 , "message": "Account Type Not Found"}`)
+	}
+	asBytes, _ := json.Marshal(accountType)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
+
+//==================================================================
+//getAccountTypes: this func will return every account type
+//==================================================================
+func (b *Bank) getAccountTypes(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(accountTypeKeyType, []string{})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	defer resultsIterator.Close()
+
+	result := []AccountType{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		accountType := AccountType{}
+		json.Unmarshal(queryResponse.Value, &accountType)
+		result = append(result, accountType)
+	}
+	asBytes, _ := json.Marshal(result)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
+
+//=====================================================================
+//getAccountTypeHistory: this func will return every version of type ,
+//oldest first
+//args[0]: account type name
+//=====================================================================
+func (b *Bank) getAccountTypeHistory(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 1 Argument"}`)
+	}
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(accountTypeVersionKeyType, []string{strings.ToLower(args[0])})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	defer resultsIterator.Close()
+
+	result := []AccountType{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		accountType := AccountType{}
+		json.Unmarshal(queryResponse.Value, &accountType)
+		result = append(result, accountType)
+	}
+	current, err := getAccountType(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if current.AccountTypeName != "" {
+		result = append(result, current)
+	}
+	asBytes, _ := json.Marshal(result)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestAccountTypeChangesAdminOnly(t *testing.T) {
+	l := newTestLedger(t)
+	l.mustBeAdminOnly(l.bank.createAccountType, `{"AccountTypeName":"Savings","category":"individual","limit":15000,"max_owners":1}`)
+	l.mustBeAdminOnly(l.bank.updateAccountType, `{"AccountTypeName":"Savings","category":"individual","limit":25000,"max_owners":1}`)
+	accountType, err := lookupAccountType(l.stub, "Savings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accountType.Limit != 25000 {
+		t.Fatalf("limit of Savings is %v , want 25000", accountType.Limit)
+	}
+	l.mustBeAdminOnly(l.bank.deleteAccountType, "Savings")
+	if _, err := lookupAccountType(l.stub, "Savings"); err == nil {
+		t.Fatal("deleted account type is still offered")
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//=============================================================================
+// Joint and business accounts are stored once under accountKeyType composite
+// key. Kyc of every owner only keeps an accountRef entry for such account
+//=============================================================================
+const (
+	accountKeyType    = "account"
+	sharedAccountType = "sharedAccount"
+	accountRefType    = "accountRef"
+)
+
+//==============================================================
+// OwnerRole : role of a customer on joint or business account
+//==============================================================
+type OwnerRole string
+
+const (
+	DIRECTOR        OwnerRole = "director"
+	SIGNATORY       OwnerRole = "signatory"
+	BENEFICIALOWNER OwnerRole = "beneficial owner"
+)
+
+//==============================================================
+// SigningMandate : how many signatories have to sign a transfer
+//==============================================================
+type SigningMandate string
+
+const (
+	ANYONE SigningMandate = "any-one"
+	ANYTWO SigningMandate = "any-two"
+	ALL    SigningMandate = "all"
+)
+
+//==================================================================
+//AccountOwner : one owner of joint or business account
+//==================================================================
+type AccountOwner struct {
+	CustID      string    `json:"CustID"`
+	AccountName string    `json:"AccountName"`
+	Role        OwnerRole `json:"Role"`
+	Ownership   float64   `json:"Ownership"`
+}
+
+//=========================================================================
+//SharedAccountRequest : payload of joint / business account owners (args[8])
+//=========================================================================
+type SharedAccountRequest struct {
+	Customers []AccountOwner `json:"Customers"`
+	Mandate   string         `json:"Mandate"`
+}
+
+//==============================================================
+//parseOwnerRole: this func will validate owner role
+//==============================================================
+func parseOwnerRole(role string) (OwnerRole, bool) {
+	switch strings.ToLower(strings.TrimSpace(role)) {
+	case "", "signatory":
+		return SIGNATORY, true
+	case "director":
+		return DIRECTOR, true
+	case "beneficial owner", "beneficial_owner", "ubo":
+		return BENEFICIALOWNER, true
+	}
+	return "", false
+}
+
+//==============================================================
+//parseMandate: this func will validate signing mandate
+//==============================================================
+func parseMandate(mandate string) (SigningMandate, bool) {
+	switch strings.ToLower(strings.TrimSpace(mandate)) {
+	case "", "any-one", "anyone", "any one":
+		return ANYONE, true
+	case "any-two", "anytwo", "any two":
+		return ANYTWO, true
+	case "all":
+		return ALL, true
+	}
+	return "", false
+}
+
+//================================================================
+//canSign: directors and signatories can sign for the account
+//================================================================
+func (o AccountOwner) canSign() bool {
+	return o.Role == DIRECTOR || o.Role == SIGNATORY
+}
+
+//==================================================================================
+//validateOwners: this func will check owner roles , ownership percentages and that
+//enough signatories exist for the mandate
+//==================================================================================
+func validateOwners(owners []AccountOwner, mandate SigningMandate) ([]AccountOwner, error) {
+	if len(owners) == 0 {
+		return nil, errors.New("Please Provide Customers of account")
+	}
+	seen := make(map[string]bool)
+	var total float64
+	signatories := 0
+	for i := range owners {
+		if owners[i].CustID == "" {
+			return nil, errors.New("Please Provide CustID of every Customer")
+		}
+		if seen[owners[i].CustID] {
+			return nil, errors.New("Customer " + owners[i].CustID + " is listed more than once")
+		}
+		seen[owners[i].CustID] = true
+		role, ok := parseOwnerRole(string(owners[i].Role))
+		if !ok {
+			return nil, errors.New("Please Provide correct Role (director , signatory , beneficial owner) of " + owners[i].CustID)
+		}
+		owners[i].Role = role
+		if owners[i].Ownership < 0 || owners[i].Ownership > 100 {
+			return nil, errors.New("Ownership of " + owners[i].CustID + " must be between 0 and 100")
+		}
+		total += owners[i].Ownership
+		if owners[i].canSign() {
+			signatories++
+		}
+	}
+	if total > 100 {
+		return nil, errors.New("Total Ownership can not be more than 100")
+	}
+	if signatories == 0 {
+		return nil, errors.New("Account must have at least one signatory")
+	}
+	if mandate == ANYTWO && signatories < 2 {
+		return nil, errors.New("Mandate any-two needs at least two signatories")
+	}
+	return owners, nil
+}
+
+//=========================================================================
+//accountReference: this func will return the entry kept in owner Kyc
+//=========================================================================
+func accountReference(account Account, ownerName string) Account {
+	return Account{
+		ObjectType:    accountRefType,
+		AccountNumber: account.AccountNumber,
+		BranchCode:    account.BranchCode,
+		OwnerName:     ownerName,
+	}
+}
+
+//=========================================================================
+//getSharedAccount: this func will read joint / business account by number
+//=========================================================================
+func getSharedAccount(stub shim.ChaincodeStubInterface, accNo string) (Account, error) {
+	account := Account{}
+	key, err := stub.CreateCompositeKey(accountKeyType, []string{accNo})
+	if err != nil {
+		return account, err
+	}
+	asBytes, err := stub.GetState(key)
+	if err != nil {
+		return account, err
+	}
+	json.Unmarshal(asBytes, &account)
+	return account, nil
+}
+
+//=========================================================================
+//resolveAccount: this func will return full account for entry of Kyc map
+//=========================================================================
+func resolveAccount(stub shim.ChaincodeStubInterface, account Account) (Account, error) {
+	if account.ObjectType != accountRefType {
+		return account, nil
+	}
+	return getSharedAccount(stub, account.AccountNumber)
+}
+
+//=====================================================================
+//category: this func will return category of account from its type ,
+//shared account of individual type is treated as joint
+//=====================================================================
+func (a Account) category() AccountCategory {
+	category := a.AccountType.category()
+	if a.ObjectType == sharedAccountType && category == INDIVIDUALCATEGORY {
+		return JOINTCATEGORY
+	}
+	return category
+}
+
+//=================================================================================
+//putAccount: this func will store account stamped with ledger time and client ,
+//joint / business accounts are written to their own key and other accounts are
+//updated on kyc which caller has to write
+//=================================================================================
+func putAccount(stub shim.ChaincodeStubInterface, kyc *Kyc, account Account) error {
+	var err error
+	if account.UpdatedAt, account.UpdatedBy, err = auditStamp(stub); err != nil {
+		return err
+	}
+	if account.ObjectType != sharedAccountType {
+		kyc.Accounts[account.AccountNumber] = account
+		return nil
+	}
+	key, err := stub.CreateCompositeKey(accountKeyType, []string{account.AccountNumber})
+	if err != nil {
+		return err
+	}
+	asBytes, _ := json.Marshal(account)
+	return stub.PutState(key, asBytes)
+}
+
+//==========================================================================
+//getAccountOwners: this func will return owners and mandate of joint or
+//business account
+//args[0]: account number
+//==========================================================================
+func (b *Bank) getAccountOwners(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 1 Argument"}`)
+	}
+	account, err := getSharedAccount(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if account.AccountNumber == "" {
+		return shim.Error(`{"status": 404 , "message": "Joint or Business Account Not Found"}`)
+	}
+	asBytes, _ := json.Marshal(account)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//=============================================================================
+// Test ledger : MockStub that keeps history of every key , clients with
+// userType and branchCode attributes in their certificate and a clock that
+// tests move forward. Handlers are called directly so each call is one
+// transaction stamped with the clock
+//=============================================================================
+
+// attribute extension read by cid from client certificate
+var attrsOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+type handler func(shim.ChaincodeStubInterface, []string) pb.Response
+
+//=====================================================================
+//historyStub : MockStub answering GetHistoryForKey from writes it saw
+//=====================================================================
+type historyStub struct {
+	*shimtest.MockStub
+	history map[string][]*queryresult.KeyModification
+}
+
+func (s *historyStub) PutState(key string, value []byte) error {
+	if err := s.MockStub.PutState(key, value); err != nil {
+		return err
+	}
+	s.history[key] = append(s.history[key], &queryresult.KeyModification{TxId: s.TxID, Value: value, Timestamp: s.TxTimestamp, IsDelete: len(value) == 0})
+	return nil
+}
+
+func (s *historyStub) DelState(key string) error {
+	if err := s.MockStub.DelState(key); err != nil {
+		return err
+	}
+	s.history[key] = append(s.history[key], &queryresult.KeyModification{TxId: s.TxID, Timestamp: s.TxTimestamp, IsDelete: true})
+	return nil
+}
+
+// history is returned newest first as the peer does
+func (s *historyStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	modifications := []*queryresult.KeyModification{}
+	for i := len(s.history[key]) - 1; i >= 0; i-- {
+		modifications = append(modifications, s.history[key][i])
+	}
+	return &historyIterator{modifications: modifications}, nil
+}
+
+type historyIterator struct {
+	modifications []*queryresult.KeyModification
+}
+
+func (it *historyIterator) HasNext() bool {
+	return len(it.modifications) > 0
+}
+
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	modification := it.modifications[0]
+	it.modifications = it.modifications[1:]
+	return modification, nil
+}
+
+func (it *historyIterator) Close() error {
+	return nil
+}
+
+//=====================================================================
+//testLedger : chaincode under test with caller , clock and transient
+//=====================================================================
+type testLedger struct {
+	t         *testing.T
+	bank      *Bank
+	stub      *historyStub
+	now       time.Time
+	txNo      int
+	lastTxID  string
+	creators  map[string][]byte
+	transient map[string][]byte
+}
+
+func newTestLedger(t *testing.T) *testLedger {
+	l := &testLedger{
+		t:        t,
+		bank:     new(Bank),
+		now:      time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC),
+		creators: make(map[string][]byte),
+	}
+	l.stub = &historyStub{
+		MockStub: shimtest.NewMockStub("bifs", l.bank),
+		history:  make(map[string][]*queryresult.KeyModification),
+	}
+	l.as("HBLPK", "admin", "001")
+	l.mustCall(func(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+		return l.bank.Init(stub)
+	})
+	return l
+}
+
+//=====================================================================
+//as: this func will make next calls come from client of bank with
+//given userType and branchCode attributes
+//=====================================================================
+func (l *testLedger) as(mspID string, userType string, branchCode string) {
+	l.t.Helper()
+	name := userType + "." + branchCode + "@" + mspID
+	if creator, found := l.creators[name]; found {
+		l.stub.Creator = creator
+		return
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		l.t.Fatal(err)
+	}
+	attrs, _ := json.Marshal(map[string]interface{}{
+		"attrs": map[string]string{"userType": userType, "branchCode": branchCode},
+	})
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(int64(len(l.creators) + 1)),
+		Subject:         pkix.Name{CommonName: name, Organization: []string{mspID}},
+		NotBefore:       l.now.AddDate(-1, 0, 0),
+		NotAfter:        l.now.AddDate(10, 0, 0),
+		ExtraExtensions: []pkix.Extension{{Id: attrsOID, Value: attrs}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		l.t.Fatal(err)
+	}
+	creator, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspID,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+	if err != nil {
+		l.t.Fatal(err)
+	}
+	l.creators[name] = creator
+	l.stub.Creator = creator
+}
+
+//=====================================================================
+//withTransient: this func will pass field to next call only
+//=====================================================================
+func (l *testLedger) withTransient(field string, value string) {
+	if l.transient == nil {
+		l.transient = make(map[string][]byte)
+	}
+	l.transient[field] = []byte(value)
+}
+
+//=====================================================================
+//call: this func will run handler as one transaction at ledger clock
+//=====================================================================
+func (l *testLedger) call(fn handler, args ...string) pb.Response {
+	l.txNo++
+	l.lastTxID = fmt.Sprintf("tx%04d", l.txNo)
+	l.stub.MockTransactionStart(l.lastTxID)
+	l.stub.TxTimestamp = timestamppb.New(l.now)
+	l.stub.TransientMap = l.transient
+	response := fn(l.stub, args)
+	l.stub.MockTransactionEnd(l.lastTxID)
+	l.stub.TransientMap = nil
+	l.transient = nil
+	// events are not checked , channel is drained so SetEvent never blocks
+	for len(l.stub.ChaincodeEventsChannel) > 0 {
+		<-l.stub.ChaincodeEventsChannel
+	}
+	return response
+}
+
+//=====================================================================
+//mustCall: this func will run handler and fail test when it is refused
+//=====================================================================
+func (l *testLedger) mustCall(fn handler, args ...string) pb.Response {
+	l.t.Helper()
+	response := l.call(fn, args...)
+	if response.Status != shim.OK {
+		l.t.Fatalf("call %v refused: %s", args, response.Message)
+	}
+	return response
+}
+
+//=====================================================================
+//mustRefuse: this func will run handler and check it is refused with
+//message containing want
+//=====================================================================
+func (l *testLedger) mustRefuse(want string, fn handler, args ...string) {
+	l.t.Helper()
+	response := l.call(fn, args...)
+	if response.Status == shim.OK {
+		l.t.Fatalf("call %v succeeded , want refusal %q", args, want)
+	}
+	if !strings.Contains(response.Message, want) {
+		l.t.Fatalf("call %v refused with %s , want %q", args, response.Message, want)
+	}
+}
+
+//=====================================================================
+//mustBeAdminOnly: this func will check setter is refused for a user of
+//bank and accepted from its admin user
+//=====================================================================
+func (l *testLedger) mustBeAdminOnly(fn handler, args ...string) {
+	l.t.Helper()
+	l.as("HBLPK", "maker", "001")
+	l.mustRefuse(`"status": 403`, fn, args...)
+	l.as("HBLPK", "Admin", "001")
+	l.mustCall(fn, args...)
+}
+
+//=====================================================================
+//addBranch: this func will add branch of bank
+//=====================================================================
+func (l *testLedger) addBranch(mspID string, code string) {
+	l.t.Helper()
+	l.as(mspID, "admin", code)
+	l.mustCall(l.bank.addBranch, code, "Branch "+code, "Street "+code)
+}
+
+//=====================================================================
+//onboard: this func will add customer with approved individual account
+//at branch of bank , identity is passed when not empty
+//=====================================================================
+func (l *testLedger) onboard(mspID string, branch string, custID string, accNo string, name string, identity string) {
+	l.t.Helper()
+	l.as(mspID, "maker", branch)
+	if identity != "" {
+		l.withTransient(identityTransientKey, identity)
+	}
+	l.mustCall(l.bank.addKyc, custID, personalHash(custID), "false", "Individual", accNo, name, branch, "", "")
+	l.mustCall(l.bank.updatedPersonalKycStatus, custID, "approved", "low")
+	l.mustCall(l.bank.updateAccountKycStatus, custID, accNo, "approved")
+}
+
+func personalHash(custID string) string {
+	return "hash-" + custID
+}
+
+// verification payload of transferInitiate for one customer
+func verification(custID string) string {
+	return `{"Customers":[{"CustID":"` + custID + `","Hash":"` + personalHash(custID) + `"}]}`
+}
+
+//=====================================================================
+//transferArgs: this func will return arguments of transferInitiate ,
+//extra is currency , charge bearer , idempotency key and receiver name
+//=====================================================================
+func (l *testLedger) transferArgs(senderAcc string, senderCust string, receiverAcc string, amount string, extra ...string) []string {
+	args := []string{senderAcc, receiverAcc, amount, "family support", verification(senderCust), "invoice", "", l.now.Format(time.RFC3339), "low", "", ""}
+	return append(args, extra...)
+}
+
+//=====================================================================
+//transfer: this func will initiate transfer and return its id
+//=====================================================================
+func (l *testLedger) transfer(senderAcc string, senderCust string, receiverAcc string, amount string, extra ...string) string {
+	l.t.Helper()
+	l.mustCall(l.bank.transferInitiate, l.transferArgs(senderAcc, senderCust, receiverAcc, amount, extra...)...)
+	return l.lastTxID
+}
+
+//=====================================================================
+//transaction: this func will read transaction from ledger
+//=====================================================================
+func (l *testLedger) transaction(txID string) Transaction {
+	l.t.Helper()
+	asBytes, err := getTransactionState(l.stub, txID)
+	if err != nil {
+		l.t.Fatal(err)
+	}
+	transaction := Transaction{}
+	json.Unmarshal(asBytes, &transaction)
+	return transaction
+}
+
+//=====================================================================
+//kyc: this func will read customer of bank from ledger
+//=====================================================================
+func (l *testLedger) kyc(mspID string, custID string) Kyc {
+	l.t.Helper()
+	asBytes, err := getKycStateOf(l.stub, mspID, custID)
+	if err != nil {
+		l.t.Fatal(err)
+	}
+	kyc := Kyc{}
+	json.Unmarshal(asBytes, &kyc)
+	return kyc
+}
+
+//=====================================================================
+//newTransferLedger: this func will return ledger with customer C1 of
+//HBLPK (account PK-1) and R1 of HBLTR (account TR-1) , calls come from
+//maker of HBLPK branch 001
+//=====================================================================
+func newTransferLedger(t *testing.T) *testLedger {
+	l := newTestLedger(t)
+	l.addBranch("HBLPK", "001")
+	l.addBranch("HBLTR", "001")
+	l.onboard("HBLPK", "001", "C1", "PK-1", "Jane Smith", "")
+	l.onboard("HBLTR", "001", "R1", "TR-1", "John Doe", "")
+	l.as("HBLPK", "maker", "001")
+	return l
+}
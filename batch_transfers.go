@@ -0,0 +1,363 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//=============================================================================
+// Batch transfers : many payments from one sender account submitted at once.
+// Batch header is kept under (batchTransfer , batchID) and every line that
+// passes transferInitiate is written as child transaction batchID-lineNo
+//=============================================================================
+const (
+	batchTransferKeyType = "batchTransfer"
+	maxBatchLines        = 500
+)
+
+//===============================================================
+// BatchStatus : decision of sender bank on batch
+//===============================================================
+type BatchStatus string
+
+const (
+	BATCHSUBMITTED BatchStatus = "submitted"
+	BATCHAPPROVED  BatchStatus = "approved"
+	BATCHREJECTED  BatchStatus = "rejected"
+)
+
+//==============================================================
+//BatchItem : one beneficiary of batch payload
+//==============================================================
+type BatchItem struct {
+	ReceiverAccount string  `json:"receiver_account"`
+	ReceiverName    string  `json:"receiver_name,omitempty"`
+	Amount          float64 `json:"amount"`
+	Reference       string  `json:"reference"`
+	Purpose         string  `json:"purpose"`
+}
+
+//==============================================================
+//BatchPayload : file like payload of batch transfer
+//==============================================================
+type BatchPayload struct {
+	Reference    string      `json:"reference"`
+	Purpose      string      `json:"purpose"`
+	Currency     string      `json:"currency"`
+	ChargeBearer string      `json:"charge_bearer"`
+	Items        []BatchItem `json:"items"`
+}
+
+//=====================================================================
+//BatchLine : outcome of one line , Error is set when line failed
+//=====================================================================
+type BatchLine struct {
+	Line            int     `json:"line"`
+	ReceiverAccount string  `json:"receiver_account"`
+	Amount          float64 `json:"amount"`
+	TransactionID   string  `json:"transaction_id,omitempty"`
+	Error           string  `json:"error,omitempty"`
+}
+
+//==============================================================
+//BatchTransfer : header of batch with outcome of every line
+//==============================================================
+type BatchTransfer struct {
+	ObjectType    string       `json:"doc_type"`
+	BatchID       string       `json:"batch_id"`
+	MSPID         string       `json:"msp_id"`
+	SenderAccount string       `json:"sender_account"`
+	SenderBranch  string       `json:"sender_branch"`
+	Reference     string       `json:"reference"`
+	Currency      string       `json:"currency,omitempty"`
+	ChargeBearer  ChargeBearer `json:"charge_bearer"`
+	Status        BatchStatus  `json:"status"`
+	Lines         []BatchLine  `json:"lines"`
+	Accepted      int          `json:"accepted"`
+	Failed        int          `json:"failed"`
+	TotalAmount   float64      `json:"total_amount"`
+	CreatedBy     string       `json:"created_by"`
+	CreatedAt     string       `json:"created_at"`
+	DecidedBy     string       `json:"decided_by,omitempty"`
+	DecidedAt     string       `json:"decided_at,omitempty"`
+	Comment       string       `json:"comment,omitempty"`
+}
+
+//==========================================================
+//getBatchTransferState: this func will read batch header
+//==========================================================
+func getBatchTransferState(stub shim.ChaincodeStubInterface, batchID string) (BatchTransfer, error) {
+	batch := BatchTransfer{}
+	key, err := stub.CreateCompositeKey(batchTransferKeyType, []string{batchID})
+	if err != nil {
+		return batch, err
+	}
+	asBytes, err := stub.GetState(key)
+	if err != nil {
+		return batch, err
+	}
+	json.Unmarshal(asBytes, &batch)
+	return batch, nil
+}
+
+//==========================================================
+//putBatchTransfer: this func will write batch header
+//==========================================================
+func putBatchTransfer(stub shim.ChaincodeStubInterface, batch BatchTransfer) error {
+	key, err := stub.CreateCompositeKey(batchTransferKeyType, []string{batch.BatchID})
+	if err != nil {
+		return err
+	}
+	asBytes, _ := json.Marshal(batch)
+	return stub.PutState(key, asBytes)
+}
+
+//===================================================================
+//validateBatchItem: this func will check line before it is submitted
+//===================================================================
+func (b *Bank) validateBatchItem(stub shim.ChaincodeStubInterface, item BatchItem, senderAccount string, seen map[string]bool) string {
+	if item.ReceiverAccount == "" {
+		return "Receiver Account is missing"
+	}
+	if item.ReceiverAccount == senderAccount {
+		return "Receiver Account is same as Sender Account"
+	}
+	if item.Amount <= 0 {
+		return "Amount must be more than 0"
+	}
+	if isExist, _ := b.IsAccountExists(stub, item.ReceiverAccount); !isExist {
+		return "Receiver Account not found"
+	}
+	line := item.ReceiverAccount + "|" + item.Reference
+	if seen[line] {
+		return "Duplicate Receiver Account and Reference in batch"
+	}
+	seen[line] = true
+	return ""
+}
+
+//=====================================================================================
+//submitBatchTransfer: this func will validate every line of batch and initiate valid
+//lines through initiateTransfer. Lines are written as initiated and wait for decision on
+//batch , failed lines leave nothing on ledger and are reported on batch header
+//args[0]: sender account number
+//args[1]: verification json , same as transferInitiate
+//args[2]: batch payload json {"reference":"" , "purpose":"" , "currency":"" ,
+//"charge_bearer":"" , "items":[{"receiver_account":"" , "amount":0 , "reference":"" ,
+//"purpose":""}]}
+//=====================================================================================
+func (b *Bank) submitBatchTransfer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Sender Account , Verification and Batch"}`)
+	}
+	_, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok {
+		return shim.Error(`{"status": 403 , "message":"Client has no attribute UserType"}`)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	found, kyc := getKycOfBankAccount(stub, args[0])
+	if !found {
+		return shim.Error(`{"status": 404 , "message": "Sender Account not found"}`)
+	}
+	senderBranch := kyc.Accounts[args[0]].BranchCode
+	if bankOfBranch(senderBranch) != mspID {
+		return shim.Error(`{"status": 403 , "message": "Sender Account does not belong to ` + mspID + `"}`)
+	}
+	payload := BatchPayload{}
+	if err := json.Unmarshal([]byte(args[2]), &payload); err != nil {
+		return shim.Error(`{"status": 500 , "message": "Please check Batch ` + string(err.Error()) + `"}`)
+	}
+	if len(payload.Items) == 0 || len(payload.Items) > maxBatchLines {
+		return shim.Error(`{"status": 500 , "message": "Batch must have 1 to ` + strconv.Itoa(maxBatchLines) + ` items"}`)
+	}
+	bearer, err := parseChargeBearer(payload.ChargeBearer)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	id, _ := cid.GetID(stub)
+	batch := BatchTransfer{
+		ObjectType:    batchTransferKeyType,
+		BatchID:       stub.GetTxID(),
+		MSPID:         mspID,
+		SenderAccount: args[0],
+		SenderBranch:  senderBranch,
+		Reference:     payload.Reference,
+		Currency:      strings.ToUpper(payload.Currency),
+		ChargeBearer:  bearer,
+		Status:        BATCHSUBMITTED,
+		Lines:         []BatchLine{},
+		CreatedBy:     mspID + "_" + id,
+		CreatedAt:     now.Format(time.RFC3339),
+	}
+	seen := make(map[string]bool)
+	ctr := newCtrBatch()
+	for i, item := range payload.Items {
+		line := BatchLine{Line: i + 1, ReceiverAccount: item.ReceiverAccount, Amount: item.Amount}
+		line.Error = b.validateBatchItem(stub, item, args[0], seen)
+		if line.Error == "" {
+			purpose := item.Purpose
+			if purpose == "" {
+				purpose = payload.Purpose
+			}
+			reference := item.Reference
+			if reference == "" {
+				reference = payload.Reference
+			}
+			// line number keeps child transaction id same on every endorsing peer
+			childID := fmt.Sprintf("%s-%04d", batch.BatchID, line.Line)
+			response, _, err := b.initiateTransfer(stub, []string{
+				args[0], item.ReceiverAccount, strconv.FormatFloat(item.Amount, 'f', -1, 64), purpose, args[1], reference,
+				"", now.Format(time.RFC3339), "", "", "", batch.Currency, string(bearer), "", item.ReceiverName,
+			}, transferOptions{TxID: childID, BatchID: batch.BatchID, Ctr: ctr})
+			if err != nil {
+				return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+			}
+			// refused line has written nothing
+			if response.Status == shim.OK {
+				line.TransactionID = childID
+			} else {
+				line.Error = response.Message
+			}
+		}
+		if line.TransactionID == "" {
+			batch.Failed++
+		} else {
+			batch.Accepted++
+			batch.TotalAmount += item.Amount
+		}
+		batch.Lines = append(batch.Lines, line)
+	}
+	if batch.Accepted == 0 {
+		asBytes, _ := json.Marshal(batch.Lines)
+		return shim.Error(`{"status": 500 , "message": "No line of batch is valid" , "data": ` + string(asBytes) + `}`)
+	}
+	batch.TotalAmount = roundAmount(batch.TotalAmount)
+	if err := putBatchTransfer(stub, batch); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if err := ctr.raiseEvent(stub); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	asBytes, _ := json.Marshal(batch)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
+
+//=====================================================================================
+//updateBatchTransferStatus: this func will approve or reject every transaction of
+//batch waiting at sender bank , transactions already processed are left as they are
+//args[0]: batch id
+//args[1]: approved or rejected
+//args[2]: comment
+//=====================================================================================
+func (b *Bank) updateBatchTransferStatus(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 {
+		return shim.Error(`{"status": 500 , "message":"Please Provide 3 arguments"}`)
+	}
+	val, ok, err := cid.GetAttributeValue(stub, "branchCode")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok {
+		return shim.Error(`{"status": 500 , "message":"Client has no Attribute branchCode"}`)
+	}
+	_, ok, err = cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok {
+		return shim.Error(`{"status": 500 , "message":"Client has no Attribute UserType"}`)
+	}
+	batch, err := getBatchTransferState(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if batch.BatchID == "" {
+		return shim.Error(`{"status": 404 , "message": "Batch Not Found"}`)
+	}
+	mspId, _ := cid.GetMSPID(stub)
+	if mspId+"_"+val != batch.SenderBranch {
+		return shim.Error(`{"status": 403 , "message":"You are not allowed to Change Batch Status" }`)
+	}
+	if batch.Status != BATCHSUBMITTED {
+		return shim.Error(`{"status": 403 , "message": "Batch is ` + string(batch.Status) + `"}`)
+	}
+	var status TransactionStatus
+	switch strings.ToLower(args[1]) {
+	case "approved":
+		batch.Status = BATCHAPPROVED
+		status = ACCEPTEDSENDERBANK
+	case "rejected":
+		batch.Status = BATCHREJECTED
+		status = REJECTEDSENDERBANK
+	default:
+		return shim.Error(`{"status": 500 , "message": "Please Provide Approved or Rejected Status"}`)
+	}
+	for _, line := range batch.Lines {
+		if line.TransactionID == "" {
+			continue
+		}
+		asBytes, err := getTransactionState(stub, line.TransactionID)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		transaction := Transaction{}
+		json.Unmarshal(asBytes, &transaction)
+		if transaction.TransactionStatus != INITIATED && transaction.TransactionStatus != PENDINGTRANSACTION {
+			continue
+		}
+		transaction.TransactionStatus = status
+		transaction.Comment = args[2]
+		if err := putTransaction(stub, transaction); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	id, _ := cid.GetID(stub)
+	batch.DecidedBy = mspId + "_" + id
+	batch.DecidedAt = now.Format(time.RFC3339)
+	batch.Comment = args[2]
+	if err := putBatchTransfer(stub, batch); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	stub.SetEvent("evtsender", []byte(batch.BatchID))
+	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
+}
+
+//==========================================================
+//getBatchTransfer: this func will return batch header
+//args[0]: batch id
+//==========================================================
+func (b *Bank) getBatchTransfer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Batch ID"}`)
+	}
+	batch, err := getBatchTransferState(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if batch.BatchID == "" {
+		return shim.Error(`{"status": 404 , "message": "Batch Not Found"}`)
+	}
+	asBytes, _ := json.Marshal(batch)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBatchLinesAreIsolated(t *testing.T) {
+	l := newTransferLedger(t)
+	l.onboard("HBLTR", "001", "R2", "TR-2", "Ali Khan", "")
+	l.onboard("HBLTR", "001", "R3", "TR-3", "Sara Ahmed", "")
+	l.as("HBLTR", "compliance", "001")
+	l.mustCall(l.bank.addToBlackList, "R2")
+
+	l.as("HBLPK", "maker", "001")
+	l.mustCall(l.bank.submitBatchTransfer, "PK-1", verification("C1"), `{"reference":"payroll","items":[
+		{"receiver_account":"TR-1","amount":500,"reference":"a"},
+		{"receiver_account":"NOPE","amount":100},
+		{"receiver_account":"TR-2","amount":100},
+		{"receiver_account":"TR-1","amount":0},
+		{"receiver_account":"TR-1","amount":200,"reference":"a"},
+		{"receiver_account":"TR-3","amount":300,"reference":"b"}]}`)
+	batchID := l.lastTxID
+	batch, err := getBatchTransferState(l.stub, batchID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.Accepted != 2 || batch.Failed != 4 || batch.TotalAmount != 800 {
+		t.Fatalf("accepted %d failed %d total %v , want 2 4 800", batch.Accepted, batch.Failed, batch.TotalAmount)
+	}
+	wantErrors := []string{"", "Receiver Account not found", "Receiver is BlackList", "Amount must be more than 0", "Duplicate Receiver Account and Reference in batch", ""}
+	for i, line := range batch.Lines {
+		childID := fmt.Sprintf("%s-%04d", batchID, line.Line)
+		transaction := l.transaction(childID)
+		if wantErrors[i] == "" {
+			if line.TransactionID != childID || transaction.TransactionStatus != INITIATED || transaction.BatchID != batchID {
+				t.Fatalf("line %d: transaction %q status %v batch %q , want initiated line of batch", line.Line, line.TransactionID, transaction.TransactionStatus, transaction.BatchID)
+			}
+			continue
+		}
+		if line.TransactionID != "" || transaction.TransactionID != "" {
+			t.Fatalf("line %d failed but wrote transaction %q", line.Line, childID)
+		}
+		if !strings.Contains(line.Error, wantErrors[i]) {
+			t.Fatalf("line %d error is %q , want %q", line.Line, line.Error, wantErrors[i])
+		}
+	}
+	ids, err := getAccountTransactionIDs(l.stub, "TR-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 0 {
+		t.Fatalf("refused line left %v in account index of TR-2", ids)
+	}
+}
+
+func TestBatchWithoutValidLineIsRefused(t *testing.T) {
+	l := newTransferLedger(t)
+	l.mustRefuse("No line of batch is valid", l.bank.submitBatchTransfer, "PK-1", verification("C1"), `{"items":[{"receiver_account":"NOPE","amount":100}]}`)
+	batch, err := getBatchTransferState(l.stub, l.lastTxID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.BatchID != "" {
+		t.Fatal("refused batch wrote its header")
+	}
+}
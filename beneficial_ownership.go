@@ -0,0 +1,430 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+const (
+	entityKeyType = "entity"
+	// uboThreshold : effective ownership (percent) from which a person is
+	// treated as ultimate beneficial owner
+	uboThreshold = 25.0
+)
+
+//===========================================================
+// HolderType : shareholder of entity is a person or entity
+//===========================================================
+type HolderType string
+
+const (
+	PERSONHOLDER HolderType = "person"
+	ENTITYHOLDER HolderType = "entity"
+)
+
+//=====================================================================
+//Shareholder : one shareholder of corporate entity , person holder is
+//kept as MSPID:custID so it resolves same for every bank
+//=====================================================================
+type Shareholder struct {
+	HolderType HolderType `json:"HolderType"`
+	HolderID   string     `json:"HolderID"`
+	Percentage float64    `json:"Percentage"`
+}
+
+//=====================================================================
+//CorporateEntity : company behind business account and its shareholders
+//=====================================================================
+type CorporateEntity struct {
+	ObjectType     string        `json:"doc_type"`
+	EntityID       string        `json:"entity_id"`
+	Name           string        `json:"name"`
+	RegistrationNo string        `json:"registration_no"`
+	Country        string        `json:"country"`
+	MSPID          string        `json:"msp_id"`
+	Shareholders   []Shareholder `json:"shareholders"`
+}
+
+//=====================================================================
+//BeneficialOwner : person owning entity directly or through a chain ,
+//CustID is reference MSPID:custID of person
+//=====================================================================
+type BeneficialOwner struct {
+	CustID       string     `json:"cust_id"`
+	Ownership    float64    `json:"ownership"`
+	KycStatus    KycStatus  `json:"kyc_status"`
+	ControlChain [][]string `json:"control_chain"`
+}
+
+//================================================================
+//getCorporateEntity: this func will read corporate entity by id
+//================================================================
+func getCorporateEntity(stub shim.ChaincodeStubInterface, entityID string) (CorporateEntity, error) {
+	entity := CorporateEntity{}
+	key, err := stub.CreateCompositeKey(entityKeyType, []string{entityID})
+	if err != nil {
+		return entity, err
+	}
+	asBytes, err := stub.GetState(key)
+	if err != nil {
+		return entity, err
+	}
+	json.Unmarshal(asBytes, &entity)
+	return entity, nil
+}
+
+//================================================================
+//putCorporateEntity: this func will write corporate entity
+//================================================================
+func putCorporateEntity(stub shim.ChaincodeStubInterface, entity CorporateEntity) error {
+	key, err := stub.CreateCompositeKey(entityKeyType, []string{entity.EntityID})
+	if err != nil {
+		return err
+	}
+	entity.ObjectType = entityKeyType
+	asBytes, _ := json.Marshal(entity)
+	return stub.PutState(key, asBytes)
+}
+
+//=====================================================================
+//holderRef: this func will return home bank and custID of person holder ,
+//holder recorded before references carry bank belongs to bank of entity
+//=====================================================================
+func holderRef(entity CorporateEntity, holderID string) (string, string) {
+	if parts := strings.SplitN(holderID, customerRefSeparator, 2); len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return bankOfBranch(entity.MSPID), holderID
+}
+
+//=====================================================================================
+//computeBeneficialOwners: this func will walk shareholders of entity and every entity
+//above it , multiply percentages along each chain and return persons whose effective
+//ownership reaches threshold. Circular holdings are cut where chain meets itself
+//=====================================================================================
+func computeBeneficialOwners(stub shim.ChaincodeStubInterface, entityID string, threshold float64) ([]BeneficialOwner, error) {
+	owners := make(map[string]*BeneficialOwner)
+	var walk func(id string, factor float64, path []string) error
+	walk = func(id string, factor float64, path []string) error {
+		entity, err := getCorporateEntity(stub, id)
+		if err != nil {
+			return err
+		}
+		if entity.EntityID == "" {
+			return errors.New("Entity " + id + " Not Found")
+		}
+		path = append(path, id)
+		for _, holder := range entity.Shareholders {
+			share := factor * holder.Percentage / 100
+			if holder.HolderType == ENTITYHOLDER {
+				circular := false
+				for _, visited := range path {
+					if visited == holder.HolderID {
+						circular = true
+					}
+				}
+				if circular {
+					continue
+				}
+				if err := walk(holder.HolderID, share, path); err != nil {
+					return err
+				}
+				continue
+			}
+			mspID, custID := holderRef(entity, holder.HolderID)
+			ref := mspID + customerRefSeparator + custID
+			owner, found := owners[ref]
+			if !found {
+				owner = &BeneficialOwner{CustID: ref}
+				owners[ref] = owner
+			}
+			owner.Ownership += share * 100
+			chain := append(append([]string{}, path...), ref)
+			owner.ControlChain = append(owner.ControlChain, chain)
+		}
+		return nil
+	}
+	if err := walk(entityID, 1, nil); err != nil {
+		return nil, err
+	}
+
+	var result []BeneficialOwner
+	for _, owner := range owners {
+		if owner.Ownership+1e-9 < threshold {
+			continue
+		}
+		mspID, custID, _ := customerRef(stub, owner.CustID)
+		kycAsBytes, err := getKycStateOf(stub, mspID, custID)
+		if err != nil {
+			return nil, err
+		}
+		kyc := Kyc{}
+		json.Unmarshal(kycAsBytes, &kyc)
+		owner.KycStatus = kyc.PersonalKycStatus
+		result = append(result, *owner)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Ownership > result[j].Ownership })
+	return result, nil
+}
+
+//====================================================================================
+//checkBeneficialOwnersKyc: this func will make sure business account has beneficial
+//ownership recorded and every ultimate beneficial owner has approved personal kyc
+//====================================================================================
+func checkBeneficialOwnersKyc(stub shim.ChaincodeStubInterface, account Account) error {
+	if account.EntityID == "" {
+		return errors.New("Business Account has no Beneficial Ownership recorded")
+	}
+	owners, err := computeBeneficialOwners(stub, account.EntityID, uboThreshold)
+	if err != nil {
+		return err
+	}
+	var pending []string
+	for _, owner := range owners {
+		if owner.KycStatus != APPROVED {
+			pending = append(pending, owner.CustID)
+		}
+	}
+	if len(pending) > 0 {
+		return errors.New("Beneficial Owner KYC not Approved : " + strings.Join(pending, " , "))
+	}
+	return nil
+}
+
+//=================================================================
+//addCorporateEntity: this func will register corporate entity
+//args[0]: entity id
+//args[1]: name
+//args[2]: registration number
+//args[3]: country
+//=================================================================
+func (b *Bank) addCorporateEntity(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 4 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 4 arguments"}`)
+	}
+	_, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok {
+		return shim.Error(`{"status": 403 , "message":"Client has no attribute UserType"}`)
+	}
+	entity, err := getCorporateEntity(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if entity.EntityID != "" {
+		return shim.Error(`{"status": 403 , "message": "Entity with same ID Exist ` + args[0] + `"}`)
+	}
+	mspID, _ := cid.GetMSPID(stub)
+	entity = CorporateEntity{
+		EntityID:       args[0],
+		Name:           args[1],
+		RegistrationNo: args[2],
+		Country:        strings.ToUpper(args[3]),
+		MSPID:          mspID,
+	}
+	if err := putCorporateEntity(stub, entity); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
+}
+
+//==================================================================================
+//setEntityShareholders: this func will replace shareholders of corporate entity ,
+//only bank that registered entity can change them
+//args[0]: entity id
+//args[1]: [{"HolderType": "person|entity", "HolderID": "...", "Percentage": 30}]
+//person HolderID is custID of caller bank or MSPID:custID of customer of another bank
+//==================================================================================
+func (b *Bank) setEntityShareholders(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 2 arguments Entity ID and Shareholders"}`)
+	}
+	_, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok {
+		return shim.Error(`{"status": 403 , "message":"Client has no attribute UserType"}`)
+	}
+	entity, err := getCorporateEntity(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if entity.EntityID == "" {
+		return shim.Error(`{"status": 404 , "message": "Entity Not Found"}`)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if entity.MSPID != mspID {
+		return shim.Error(`{"status": 403 , "message": "Entity is registered by ` + entity.MSPID + `"}`)
+	}
+	var shareholders []Shareholder
+	if err := json.Unmarshal([]byte(args[1]), &shareholders); err != nil {
+		return shim.Error(`{"status": 500 , "message": "Please check Shareholders ` + string(err.Error()) + `"}`)
+	}
+	var total float64
+	seen := make(map[string]bool)
+	for i, holder := range shareholders {
+		holder.HolderType = HolderType(strings.ToLower(string(holder.HolderType)))
+		shareholders[i].HolderType = holder.HolderType
+		if holder.Percentage <= 0 || holder.Percentage > 100 {
+			return shim.Error(`{"status": 500 , "message": "Percentage of ` + holder.HolderID + ` must be between 0 and 100"}`)
+		}
+		total += holder.Percentage
+		if holder.HolderType == PERSONHOLDER {
+			kycAsBytes, err := getKycState(stub, holder.HolderID)
+			if err != nil {
+				return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+			}
+			kyc := Kyc{}
+			json.Unmarshal(kycAsBytes, &kyc)
+			if kyc.CustID == "" {
+				return shim.Error(`{"status": 404 , "message": "CustID Does not have Personal KYC ` + holder.HolderID + `"}`)
+			}
+			// person is stored with its home bank so other banks resolve same customer
+			shareholders[i].HolderID = customerRefOf(kyc)
+		} else if holder.HolderType == ENTITYHOLDER {
+			if holder.HolderID == entity.EntityID {
+				return shim.Error(`{"status": 500 , "message": "Entity can not hold its own shares"}`)
+			}
+			parent, err := getCorporateEntity(stub, holder.HolderID)
+			if err != nil {
+				return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+			}
+			if parent.EntityID == "" {
+				return shim.Error(`{"status": 404 , "message": "Entity Not Found ` + holder.HolderID + `"}`)
+			}
+		} else {
+			return shim.Error(`{"status": 500 , "message": "Please Provide HolderType person or entity"}`)
+		}
+		if seen[string(holder.HolderType)+shareholders[i].HolderID] {
+			return shim.Error(`{"status": 500 , "message": "Shareholder ` + holder.HolderID + ` is listed more than once"}`)
+		}
+		seen[string(holder.HolderType)+shareholders[i].HolderID] = true
+	}
+	if total > 100+1e-9 {
+		return shim.Error(`{"status": 500 , "message": "Total Percentage can not be more than 100"}`)
+	}
+	entity.Shareholders = shareholders
+	if err := putCorporateEntity(stub, entity); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
+}
+
+//===================================================================================
+//linkBusinessAccountEntity: this func will link business account to corporate entity
+//registered by same bank
+//args[0]: custID
+//args[1]: account number
+//args[2]: entity id
+//===================================================================================
+func (b *Bank) linkBusinessAccountEntity(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 3 arguments CustID , Account Number and Entity ID"}`)
+	}
+	_, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok {
+		return shim.Error(`{"status": 403 , "message":"Client has no attribute UserType"}`)
+	}
+	customerAsBytes, _ := getKycState(stub, args[0])
+	kyc := Kyc{}
+	json.Unmarshal(customerAsBytes, &kyc)
+	if kyc.CustID == "" {
+		return shim.Error(`{"status": 404 , "message": "Customer id Not Found"}`)
+	}
+	account, err := resolveAccount(stub, kyc.Accounts[args[1]])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if account.AccountNumber == "" {
+		return shim.Error(`{"status": 404 , "message": "Account Number does not belong to this Customer"}`)
+	}
+	if account.AccountType.category() != BUSINESSCATEGORY {
+		return shim.Error(`{"status": 403 , "message": "Only Business Account can be linked to Entity"}`)
+	}
+	entity, err := getCorporateEntity(stub, args[2])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if entity.EntityID == "" {
+		return shim.Error(`{"status": 404 , "message": "Entity Not Found"}`)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if entity.MSPID != mspID {
+		return shim.Error(`{"status": 403 , "message": "Entity is registered by ` + entity.MSPID + `"}`)
+	}
+	account.EntityID = entity.EntityID
+	if err := putAccount(stub, &kyc, account); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	err = putKyc(stub, kyc)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
+}
+
+//====================================================================================
+//getUltimateBeneficialOwners: this func will return ultimate beneficial owners of entity
+//args[0]: entity id
+//args[1]: (optional) ownership threshold percent , default 25
+//====================================================================================
+func (b *Bank) getUltimateBeneficialOwners(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 && len(args) != 2 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Entity ID and optional Threshold"}`)
+	}
+	threshold := uboThreshold
+	if len(args) == 2 {
+		var err error
+		threshold, err = strconv.ParseFloat(args[1], 64)
+		if err != nil || threshold < 0 || threshold > 100 {
+			return shim.Error(`{"status": 500 , "message": "Can Not Convert Threshold to Number"}`)
+		}
+	}
+	owners, err := computeBeneficialOwners(stub, args[0], threshold)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if owners == nil {
+		owners = []BeneficialOwner{}
+	}
+	asBytes, _ := json.Marshal(owners)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
+
+//==================================================================
+//getCorporateEntity: this func will return corporate entity record
+//args[0]: entity id
+//==================================================================
+func (b *Bank) getCorporateEntity(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 1 Argument"}`)
+	}
+	entity, err := getCorporateEntity(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if entity.EntityID == "" {
+		return shim.Error(`{"status": 404 , "message": "Entity Not Found"}`)
+	}
+	asBytes, _ := json.Marshal(entity)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//=============================================================
+// BranchStatus : lifecycle status of branch , empty is active
+//=============================================================
+type BranchStatus string
+
+const (
+	BRANCHACTIVE    BranchStatus = "active"
+	BRANCHSUSPENDED BranchStatus = "suspended"
+	BRANCHCLOSED    BranchStatus = "closed"
+)
+
+//=============================================================================
+// branchStatusTransitions : statuses a branch can move to from each status.
+// Closed branch is final , accounts of it stay readable but can not transact
+//=============================================================================
+var branchStatusTransitions = map[BranchStatus][]BranchStatus{
+	BRANCHACTIVE:    {BRANCHSUSPENDED, BRANCHCLOSED},
+	BRANCHSUSPENDED: {BRANCHACTIVE, BRANCHCLOSED},
+	BRANCHCLOSED:    {},
+}
+
+//=================================================================
+//status: this func will return status of branch , active when empty
+//=================================================================
+func (br Branch) status() BranchStatus {
+	if br.Status == "" {
+		return BRANCHACTIVE
+	}
+	return br.Status
+}
+
+//=====================================================================
+//code: this func will return branch code without MSPID prefix , older
+//branches only have prefixed BranchCode
+//=====================================================================
+func (br Branch) code() string {
+	if br.Code != "" {
+		return br.Code
+	}
+	parts := strings.SplitN(br.BranchCode, "_", 2)
+	return parts[len(parts)-1]
+}
+
+//===================================================================
+//lookupBranch: this func will read branch of bank by its own code
+//===================================================================
+func lookupBranch(stub shim.ChaincodeStubInterface, mspID string, code string) (Branch, error) {
+	branch := Branch{}
+	asBytes, err := getBranchState(stub, mspID+"_"+code)
+	if err != nil {
+		return branch, err
+	}
+	json.Unmarshal(asBytes, &branch)
+	if branch.BranchCode == "" {
+		return branch, errors.New("Branch " + code + " Not Found")
+	}
+	return branch, nil
+}
+
+//=======================================================================
+//checkBranchOpen: this func will refuse branch code stored on account
+//when branch is closed , branches missing from ledger are not refused
+//=======================================================================
+func checkBranchOpen(stub shim.ChaincodeStubInterface, branchCode string) error {
+	asBytes, err := getBranchState(stub, branchCode)
+	if err != nil {
+		return err
+	}
+	branch := Branch{}
+	json.Unmarshal(asBytes, &branch)
+	if branch.status() == BRANCHCLOSED {
+		return errors.New("Branch " + branchCode + " is closed")
+	}
+	return nil
+}
+
+//=====================================================================================
+//checkBranchParent: this func will check parent branch exists in same bank , is not
+//closed and does not have branch as its ancestor
+//=====================================================================================
+func checkBranchParent(stub shim.ChaincodeStubInterface, mspID string, code string, parentCode string) error {
+	seen := map[string]bool{code: true}
+	for parentCode != "" {
+		if seen[parentCode] {
+			return errors.New("Branch " + code + " can not be under its own sub branch " + parentCode)
+		}
+		seen[parentCode] = true
+		parent, err := lookupBranch(stub, mspID, parentCode)
+		if err != nil {
+			return err
+		}
+		if parent.status() == BRANCHCLOSED {
+			return errors.New("Parent Branch " + parentCode + " is closed")
+		}
+		parentCode = parent.ParentCode
+	}
+	return nil
+}
+
+//====================================================================
+//getBankBranches: this func will return every branch of bank by code
+//====================================================================
+func getBankBranches(stub shim.ChaincodeStubInterface, mspID string) ([]Branch, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(branchKeyType, []string{mspID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	branches := []Branch{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		branch := Branch{}
+		json.Unmarshal(queryResponse.Value, &branch)
+		if branch.BranchCode == "" {
+			continue
+		}
+		branches = append(branches, branch)
+	}
+	sort.Slice(branches, func(i, j int) bool { return branches[i].code() < branches[j].code() })
+	return branches, nil
+}
+
+//=======================================================================================
+//setBranchStatus: this func will suspend , reactivate or close branch of caller bank. A
+//branch with open sub branches can not be closed
+//args[0]: branch code
+//args[1]: status (active , suspended , closed)
+//args[2]: reason
+//=======================================================================================
+func (b *Bank) setBranchStatus(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Branch Code , Status and Reason"}`)
+	}
+	_, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok {
+		return shim.Error(`{"status": 403 , "message":"Client has no attribute UserType"}`)
+	}
+	if strings.TrimSpace(args[2]) == "" {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Reason"}`)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	branch, err := lookupBranch(stub, mspID, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 404 , "message": "` + string(err.Error()) + `"}`)
+	}
+	to := BranchStatus(strings.ToLower(args[1]))
+	allowed := false
+	for _, status := range branchStatusTransitions[branch.status()] {
+		if status == to {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return shim.Error(`{"status": 403 , "message": "Branch can not move from ` + string(branch.status()) + ` to ` + args[1] + `"}`)
+	}
+	if to == BRANCHCLOSED {
+		branches, err := getBankBranches(stub, mspID)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		for _, child := range branches {
+			if child.ParentCode == branch.code() && child.status() != BRANCHCLOSED {
+				return shim.Error(`{"status": 403 , "message": "Branch has open sub branch ` + child.code() + `"}`)
+			}
+		}
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	branch.Code = branch.code()
+	branch.Status = to
+	branch.StatusReason = args[2]
+	branch.StatusChangedAt = now.Format(time.RFC3339)
+	if err := putBranch(stub, branch); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status": 200 , "message": "Data Updated"}`))
+}
+
+//=====================================================================================
+//updateBranchHierarchy: this func will move branch under parent branch and set region
+//args[0]: branch code
+//args[1]: parent branch code , empty for top level branch
+//args[2]: (optional) region
+//=====================================================================================
+func (b *Bank) updateBranchHierarchy(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 && len(args) != 3 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Branch Code , Parent Branch Code and optional Region"}`)
+	}
+	_, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok {
+		return shim.Error(`{"status": 403 , "message":"Client has no attribute UserType"}`)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	branch, err := lookupBranch(stub, mspID, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 404 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if branch.status() == BRANCHCLOSED {
+		return shim.Error(`{"status": 403 , "message": "Branch is closed"}`)
+	}
+	if err := checkBranchParent(stub, mspID, branch.code(), args[1]); err != nil {
+		return shim.Error(`{"status": 403 , "message": "` + string(err.Error()) + `"}`)
+	}
+	branch.Code = branch.code()
+	branch.ParentCode = args[1]
+	if len(args) == 3 {
+		branch.Region = args[2]
+	}
+	if err := putBranch(stub, branch); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status": 200 , "message": "Data Updated"}`))
+}
+
+//==================================================================================
+//getBranches: this func will list branches of bank , caller bank when not given
+//args[0]: (optional) MSPID of bank
+//args[1]: (optional) status (active , suspended , closed)
+//args[2]: (optional) region
+//==================================================================================
+func (b *Bank) getBranches(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) > 3 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide optional MSPID , Status and Region"}`)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if len(args) > 0 && args[0] != "" {
+		mspID = args[0]
+	}
+	branches, err := getBankBranches(stub, mspID)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	result := []Branch{}
+	for _, branch := range branches {
+		if len(args) > 1 && args[1] != "" && string(branch.status()) != strings.ToLower(args[1]) {
+			continue
+		}
+		if len(args) > 2 && args[2] != "" && branch.Region != args[2] {
+			continue
+		}
+		result = append(result, branch)
+	}
+	asBytes, _ := json.Marshal(result)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
+
+//==============================================================
+//BranchNode : branch with its sub branches in bank hierarchy
+//==============================================================
+type BranchNode struct {
+	Branch
+	SubBranches []BranchNode `json:"sub_branches"`
+}
+
+//===================================================================
+//buildBranchTree: this func will return sub branches of parent code
+//===================================================================
+func buildBranchTree(children map[string][]Branch, parentCode string) []BranchNode {
+	nodes := []BranchNode{}
+	for _, branch := range children[parentCode] {
+		nodes = append(nodes, BranchNode{Branch: branch, SubBranches: buildBranchTree(children, branch.code())})
+	}
+	return nodes
+}
+
+//=================================================================================
+//getBranchHierarchy: this func will return branches of bank as tree of sub branches
+//args[0]: (optional) MSPID of bank
+//=================================================================================
+func (b *Bank) getBranchHierarchy(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) > 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide optional MSPID"}`)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if len(args) == 1 && args[0] != "" {
+		mspID = args[0]
+	}
+	branches, err := getBankBranches(stub, mspID)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	known := make(map[string]bool)
+	for _, branch := range branches {
+		known[branch.code()] = true
+	}
+	// parent missing from ledger puts branch on top level
+	children := make(map[string][]Branch)
+	for _, branch := range branches {
+		parent := branch.ParentCode
+		if !known[parent] {
+			parent = ""
+		}
+		children[parent] = append(children[parent], branch)
+	}
+	asBytes, _ := json.Marshal(buildBranchTree(children, ""))
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
@@ -0,0 +1,222 @@
+package main
+
+// goaml converts a Suspicious Transaction Report returned by the chaincode
+// function getSuspiciousTransactionReport into a goAML style XML document
+// which can be uploaded to the regulator.
+//
+//	goaml -in str.json -entity 1234 -currency PKR > str.xml
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+//=====================================================================
+//strTransaction : transaction snapshot as stored in STR on the ledger
+//=====================================================================
+type strTransaction struct {
+	TransactionID      string  `json:"TransactionID"`
+	SenderAccount      string  `json:"SenderAccount"`
+	SenderName         string  `json:"SenderName"`
+	SenderBranchName   string  `json:"SenderBranchName"`
+	ReceiverAccount    string  `json:"ReceiverAccount"`
+	ReceiverName       string  `json:"ReceiverName"`
+	ReceiverBranchName string  `json:"ReceiverBranchName"`
+	Created            string  `json:"Created"`
+	Amount             float64 `json:"Amount"`
+	Reference          string  `json:"reference"`
+	Purpose            string  `json:"Purpose"`
+	SenderCustID       string  `json:"sender_cust_id"`
+	ReceiverCustID     string  `json:"receiver_cust_id"`
+}
+
+//===================================================
+//strReport : STR as returned by the chaincode query
+//===================================================
+type strReport struct {
+	ReportID     string           `json:"report_id"`
+	ReportingMSP string           `json:"reporting_msp"`
+	ReportedBy   string           `json:"reported_by"`
+	Created      string           `json:"created"`
+	Narrative    string           `json:"narrative"`
+	Indicators   []string         `json:"indicators"`
+	Customers    []string         `json:"customers"`
+	Accounts     []string         `json:"accounts"`
+	Transactions []strTransaction `json:"transactions"`
+}
+
+//=====================================================
+//goAML document : subset of goAML report schema
+//=====================================================
+type goAMLAccount struct {
+	InstitutionName string `xml:"institution_name"`
+	Branch          string `xml:"branch"`
+	Account         string `xml:"account"`
+	AccountName     string `xml:"account_name"`
+	ClientNumber    string `xml:"client_number,omitempty"`
+}
+
+type goAMLFrom struct {
+	FundsCode   string       `xml:"from_funds_code"`
+	FromAccount goAMLAccount `xml:"from_account"`
+	Country     string       `xml:"from_country,omitempty"`
+}
+
+type goAMLTo struct {
+	FundsCode string       `xml:"to_funds_code"`
+	ToAccount goAMLAccount `xml:"to_account"`
+	Country   string       `xml:"to_country,omitempty"`
+}
+
+type goAMLTransaction struct {
+	TransactionNumber      string    `xml:"transactionnumber"`
+	InternalRefNumber      string    `xml:"internal_ref_number,omitempty"`
+	TransactionDescription string    `xml:"transaction_description,omitempty"`
+	DateTransaction        string    `xml:"date_transaction"`
+	AmountLocal            string    `xml:"amount_local"`
+	From                   goAMLFrom `xml:"t_from"`
+	To                     goAMLTo   `xml:"t_to"`
+}
+
+type goAMLReport struct {
+	XMLName           xml.Name           `xml:"report"`
+	RentityID         string             `xml:"rentity_id"`
+	SubmissionCode    string             `xml:"submission_code"`
+	ReportCode        string             `xml:"report_code"`
+	EntityReference   string             `xml:"entity_reference"`
+	SubmissionDate    string             `xml:"submission_date"`
+	CurrencyCodeLocal string             `xml:"currency_code_local"`
+	Reason            string             `xml:"reason"`
+	Action            string             `xml:"action"`
+	Transactions      []goAMLTransaction `xml:"transaction"`
+	Indicators        []string           `xml:"report_indicators>indicator"`
+}
+
+//=========================================================================
+//readReport: this func will read STR from chaincode response or raw STR
+//=========================================================================
+func readReport(data []byte) (strReport, error) {
+	report := strReport{}
+	var response struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &response); err == nil && len(response.Data) > 0 {
+		data = response.Data
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return report, err
+	}
+	if report.ReportID == "" {
+		return report, fmt.Errorf("input is not a suspicious transaction report")
+	}
+	return report, nil
+}
+
+//=======================================================================
+//goAMLDate: this func will convert ledger time to goAML date format
+//=======================================================================
+func goAMLDate(value string) string {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return value
+	}
+	return t.Format("2006-01-02T15:04:05")
+}
+
+//====================================================================
+//branchParts: branch code on ledger is stored as MSPID_BranchCode
+//====================================================================
+func branchParts(branchCode string) (string, string) {
+	parts := strings.SplitN(branchCode, "_", 2)
+	if len(parts) != 2 {
+		return "", branchCode
+	}
+	return parts[0], parts[1]
+}
+
+//===============================================================
+//toGoAML: this func will map STR to goAML report
+//===============================================================
+func toGoAML(report strReport, entityID string, currency string) goAMLReport {
+	doc := goAMLReport{
+		RentityID:         entityID,
+		SubmissionCode:    "E",
+		ReportCode:        "STR",
+		EntityReference:   report.ReportID,
+		SubmissionDate:    goAMLDate(report.Created),
+		CurrencyCodeLocal: currency,
+		Reason:            report.Narrative,
+		Action:            "Reported by " + report.ReportingMSP,
+		Indicators:        report.Indicators,
+	}
+	for _, transaction := range report.Transactions {
+		senderBank, senderBranch := branchParts(transaction.SenderBranchName)
+		receiverBank, receiverBranch := branchParts(transaction.ReceiverBranchName)
+		doc.Transactions = append(doc.Transactions, goAMLTransaction{
+			TransactionNumber:      transaction.TransactionID,
+			InternalRefNumber:      transaction.Reference,
+			TransactionDescription: transaction.Purpose,
+			DateTransaction:        goAMLDate(transaction.Created),
+			AmountLocal:            fmt.Sprintf("%.2f", transaction.Amount),
+			From: goAMLFrom{
+				FundsCode: "K",
+				FromAccount: goAMLAccount{
+					InstitutionName: senderBank,
+					Branch:          senderBranch,
+					Account:         transaction.SenderAccount,
+					AccountName:     transaction.SenderName,
+					ClientNumber:    transaction.SenderCustID,
+				},
+			},
+			To: goAMLTo{
+				FundsCode: "K",
+				ToAccount: goAMLAccount{
+					InstitutionName: receiverBank,
+					Branch:          receiverBranch,
+					Account:         transaction.ReceiverAccount,
+					AccountName:     transaction.ReceiverName,
+					ClientNumber:    transaction.ReceiverCustID,
+				},
+			},
+		})
+	}
+	return doc
+}
+
+func main() {
+	in := flag.String("in", "", "STR json file , reads stdin when empty")
+	entityID := flag.String("entity", "", "reporting entity id assigned by the FIU")
+	currency := flag.String("currency", "PKR", "local currency code")
+	flag.Parse()
+
+	var data []byte
+	var err error
+	if *in == "" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(*in)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "can not read STR:", err)
+		os.Exit(1)
+	}
+	report, err := readReport(data)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "can not parse STR:", err)
+		os.Exit(1)
+	}
+	out, err := xml.MarshalIndent(toGoAML(report, *entityID, *currency), "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "can not build goAML document:", err)
+		os.Exit(1)
+	}
+	os.Stdout.WriteString(xml.Header)
+	os.Stdout.Write(out)
+	os.Stdout.WriteString("\n")
+}
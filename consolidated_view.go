@@ -0,0 +1,416 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//==========================================================================
+// Consent of customer that another bank may see accounts held elsewhere is
+// stored under consentKeyType (home bank MSPID , custID , bank MSPID)
+//==========================================================================
+const (
+	consentKeyType         = "consent"
+	defaultViewWindowDays  = 30
+	maskedAccountNumberLen = 4
+)
+
+//==============================================================
+//CustomerConsent : customer allows bank to see all accounts
+//==============================================================
+type CustomerConsent struct {
+	ObjectType string `json:"doc_type"`
+	CustID     string `json:"cust_id"`
+	GrantedTo  string `json:"granted_to"`
+	GrantedBy  string `json:"granted_by"`
+	GrantedAt  string `json:"granted_at"`
+	ExpiresAt  string `json:"expires_at"`
+	Revoked    bool   `json:"revoked"`
+	RevokedBy  string `json:"revoked_by,omitempty"`
+	RevokedAt  string `json:"revoked_at,omitempty"`
+}
+
+//================================================================
+//TransactionTotals : count and amount of transfers in one currency
+//================================================================
+type TransactionTotals struct {
+	SentCount      int     `json:"sent_count"`
+	SentAmount     float64 `json:"sent_amount"`
+	ReceivedCount  int     `json:"received_count"`
+	ReceivedAmount float64 `json:"received_amount"`
+}
+
+//=====================================================================
+//ConsolidatedAccount : one account of customer as seen by caller bank
+//=====================================================================
+type ConsolidatedAccount struct {
+	AccountNumber string                        `json:"account_number"`
+	MSPID         string                        `json:"msp_id"`
+	BranchCode    string                        `json:"branch,omitempty"`
+	AccountType   string                        `json:"account_type,omitempty"`
+	KycStatus     KycStatus                     `json:"kyc_status,omitempty"`
+	AccountStatus AccountStatus                 `json:"account_status,omitempty"`
+	Totals        map[string]*TransactionTotals `json:"totals,omitempty"`
+	Hidden        bool                          `json:"hidden"`
+}
+
+//==================================================================
+//ConsolidatedCustomer : every account of customer across banks
+//==================================================================
+type ConsolidatedCustomer struct {
+	CustID            string                `json:"cust_id"`
+	HomeMSPID         string                `json:"home_msp_id"`
+	PersonalKycStatus KycStatus             `json:"personal_kyc_status"`
+	IsBlackList       bool                  `json:"is_black_list"`
+	ConsentGiven      bool                  `json:"consent_given"`
+	WindowDays        int                   `json:"window_days"`
+	Accounts          []ConsolidatedAccount `json:"accounts"`
+}
+
+//====================================================================
+//maskAccountNumber: this func will keep only last digits of account
+//====================================================================
+func maskAccountNumber(accNo string) string {
+	if len(accNo) <= maskedAccountNumberLen {
+		return strings.Repeat("*", len(accNo))
+	}
+	return strings.Repeat("*", len(accNo)-maskedAccountNumberLen) + accNo[len(accNo)-maskedAccountNumberLen:]
+}
+
+//=====================================================================
+//hasCustomerConsent: this func will check bank has active consent
+//=====================================================================
+func hasCustomerConsent(stub shim.ChaincodeStubInterface, kyc Kyc, mspID string) (bool, error) {
+	key, err := stub.CreateCompositeKey(consentKeyType, []string{bankOfBranch(kyc.MSPID), kyc.CustID, mspID})
+	if err != nil {
+		return false, err
+	}
+	asBytes, err := stub.GetState(key)
+	if err != nil {
+		return false, err
+	}
+	consent := CustomerConsent{}
+	json.Unmarshal(asBytes, &consent)
+	if consent.CustID == "" || consent.Revoked {
+		return false, nil
+	}
+	if consent.ExpiresAt == "" {
+		return true, nil
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return false, err
+	}
+	expires, err := time.Parse(time.RFC3339, consent.ExpiresAt)
+	return err == nil && now.Before(expires), nil
+}
+
+//=========================================================================
+//canSeeAccounts: this func will check caller bank sees accounts customer
+//holds at other banks , regulator and banks given consent see them
+//=========================================================================
+func canSeeAccounts(stub shim.ChaincodeStubInterface, kyc Kyc, mspID string) (bool, error) {
+	if isRegulator(stub) {
+		return true, nil
+	}
+	return hasCustomerConsent(stub, kyc, mspID)
+}
+
+//=====================================================================
+//hiddenAccount: this func will return account of other bank as caller
+//without consent sees it , owning bank and masked number only
+//=====================================================================
+func hiddenAccount(account Account) Account {
+	return Account{
+		AccountNumber: maskAccountNumber(account.AccountNumber),
+		BranchCode:    bankOfBranch(account.BranchCode),
+	}
+}
+
+//=================================================================================
+//grantCustomerConsent: this func will record consent of customer that bank can see
+//accounts customer holds at other banks. Consent is recorded only by home bank of
+//customer which has verified customer in person , personal hash has to match kyc
+//args[0]: custID
+//args[1]: personal hash
+//args[2]: MSPID of bank given consent
+//args[3]: (optional) expiry (RFC3339)
+//=================================================================================
+func (b *Bank) grantCustomerConsent(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 && len(args) != 4 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide CustID , Hash , Bank MSPID and optional Expiry"}`)
+	}
+	_, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok {
+		return shim.Error(`{"status": 403 , "message":"Client has no attribute UserType"}`)
+	}
+	kycAsBytes, _ := getKycState(stub, args[0])
+	kyc := Kyc{}
+	json.Unmarshal(kycAsBytes, &kyc)
+	if kyc.CustID == "" {
+		return shim.Error(`{"status": 404 , "message": "Customer id Not Found"}`)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if bankOfBranch(kyc.MSPID) != mspID {
+		return shim.Error(`{"status": 403 , "message": "Only home bank of customer can record consent"}`)
+	}
+	if kyc.PersonalHash != args[1] {
+		return shim.Error(`{"status": 403 , "message": "Personal Hash not Match"}`)
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	id, _ := cid.GetID(stub)
+	consent := CustomerConsent{
+		ObjectType: consentKeyType,
+		CustID:     kyc.CustID,
+		GrantedTo:  args[2],
+		GrantedBy:  id,
+		GrantedAt:  now.Format(time.RFC3339),
+	}
+	if len(args) == 4 && args[3] != "" {
+		expires, err := time.Parse(time.RFC3339, args[3])
+		if err != nil || !expires.After(now) {
+			return shim.Error(`{"status": 500 , "message": "Please Provide Expiry in future as RFC3339"}`)
+		}
+		consent.ExpiresAt = expires.UTC().Format(time.RFC3339)
+	}
+	key, err := stub.CreateCompositeKey(consentKeyType, []string{bankOfBranch(kyc.MSPID), consent.CustID, consent.GrantedTo})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	asBytes, _ := json.Marshal(consent)
+	err = stub.PutState(key, asBytes)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
+}
+
+//=================================================================
+//revokeCustomerConsent: this func will withdraw consent given to bank ,
+//home bank of customer or bank given consent can withdraw it
+//args[0]: custID
+//args[1]: MSPID of bank
+//=================================================================
+func (b *Bank) revokeCustomerConsent(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 2 arguments CustID and Bank MSPID"}`)
+	}
+	_, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok {
+		return shim.Error(`{"status": 403 , "message":"Client has no attribute UserType"}`)
+	}
+	mspID, custID, err := customerRef(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	callerMSP, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if callerMSP != mspID && callerMSP != args[1] {
+		return shim.Error(`{"status": 403 , "message": "Only home bank of customer or bank given consent can revoke it"}`)
+	}
+	key, err := stub.CreateCompositeKey(consentKeyType, []string{mspID, custID, args[1]})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	asBytes, err := stub.GetState(key)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	consent := CustomerConsent{}
+	json.Unmarshal(asBytes, &consent)
+	if consent.CustID == "" || consent.Revoked {
+		return shim.Error(`{"status": 404 , "message": "Consent Not Found"}`)
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	id, _ := cid.GetID(stub)
+	consent.Revoked = true
+	consent.RevokedBy = id
+	consent.RevokedAt = now.Format(time.RFC3339)
+	asBytes, _ = json.Marshal(consent)
+	err = stub.PutState(key, asBytes)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
+}
+
+//=========================================================================================
+//getConsolidatedCustomerView: this func will return every account of customer and of its
+//records at other banks linked by identity , with bank , branch , kyc and account status
+//and transfer totals of recent days read from account transaction index. Accounts of other
+//banks only show owning bank and masked number unless customer has given consent to
+//caller bank. Regulator sees every account
+//args[0]: custID
+//args[1]: (optional) days of transfer totals , default 30
+//=========================================================================================
+func (b *Bank) getConsolidatedCustomerView(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 && len(args) != 2 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide CustID and optional Days"}`)
+	}
+	days := defaultViewWindowDays
+	if len(args) == 2 {
+		var err error
+		days, err = strconv.Atoi(args[1])
+		if err != nil || days <= 0 {
+			return shim.Error(`{"status": 500 , "message": "Can Not Convert Days to Number"}`)
+		}
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	kycAsBytes, _ := getKycState(stub, args[0])
+	kyc := Kyc{}
+	json.Unmarshal(kycAsBytes, &kyc)
+	if kyc.CustID == "" {
+		return shim.Error(`{"status": 404 , "message": "Customer id Not Found"}`)
+	}
+	consent, err := hasCustomerConsent(stub, kyc, mspID)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	records := []Kyc{kyc}
+	// identity index is private to banks , regulator reads each record by its reference
+	if !isRegulator(stub) {
+		linked, err := getLinkedKycs(stub, kyc)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		records = append(records, linked...)
+	}
+
+	view := ConsolidatedCustomer{
+		CustID:            kyc.CustID,
+		HomeMSPID:         bankOfBranch(kyc.MSPID),
+		PersonalKycStatus: kyc.PersonalKycStatus,
+		IsBlackList:       kyc.IsBlackList,
+		ConsentGiven:      consent,
+		WindowDays:        days,
+		Accounts:          []ConsolidatedAccount{},
+	}
+	listed := make(map[string]bool)
+	for _, record := range records {
+		seeAll, err := canSeeAccounts(stub, record, mspID)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		for _, account := range record.Accounts {
+			if listed[account.AccountNumber] {
+				continue
+			}
+			listed[account.AccountNumber] = true
+			account, err = resolveAccount(stub, account)
+			if err != nil {
+				return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+			}
+			entry := ConsolidatedAccount{
+				AccountNumber: account.AccountNumber,
+				MSPID:         bankOfBranch(account.BranchCode),
+			}
+			if !seeAll && entry.MSPID != mspID {
+				entry.AccountNumber = maskAccountNumber(account.AccountNumber)
+				entry.Hidden = true
+			} else {
+				entry.BranchCode = account.BranchCode
+				entry.AccountType = account.AccountType.AccountTypeName
+				entry.KycStatus = account.AccountKycStatus
+				entry.AccountStatus = account.status()
+				entry.Totals = make(map[string]*TransactionTotals)
+			}
+			view.Accounts = append(view.Accounts, entry)
+		}
+	}
+	sort.Slice(view.Accounts, func(i, j int) bool {
+		if view.Accounts[i].MSPID != view.Accounts[j].MSPID {
+			return view.Accounts[i].MSPID < view.Accounts[j].MSPID
+		}
+		return view.Accounts[i].AccountNumber < view.Accounts[j].AccountNumber
+	})
+	visible := make(map[string]*ConsolidatedAccount)
+	for i := range view.Accounts {
+		if !view.Accounts[i].Hidden {
+			visible[view.Accounts[i].AccountNumber] = &view.Accounts[i]
+		}
+	}
+
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	since := now.AddDate(0, 0, -days)
+	counted := make(map[string]bool)
+	for accNo := range visible {
+		ids, err := getAccountTransactionIDs(stub, accNo)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		for _, id := range ids {
+			// transfer between two accounts of customer is read once
+			if counted[id] {
+				continue
+			}
+			counted[id] = true
+			asBytes, err := getTransactionState(stub, id)
+			if err != nil {
+				return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+			}
+			transaction := Transaction{}
+			json.Unmarshal(asBytes, &transaction)
+			if transaction.TransactionStatus == REJECTEDSENDERBANK || transaction.TransactionStatus == REJECTEDRECEIVERBANK || transaction.TransactionStatus == CANCELLED || transaction.TransactionStatus == EXPIRED {
+				continue
+			}
+			created, err := time.Parse(time.RFC3339, transaction.CreatedAt)
+			if err != nil || created.Before(since) {
+				continue
+			}
+			if entry, found := visible[transaction.SenderAccount]; found {
+				totals := entry.totalsOf(transaction.Currency)
+				totals.SentCount++
+				totals.SentAmount += transaction.Amount
+			}
+			if entry, found := visible[transaction.ReceiverAccount]; found {
+				totals := entry.totalsOf(transaction.Currency)
+				totals.ReceivedCount++
+				totals.ReceivedAmount += transaction.Amount
+			}
+		}
+	}
+	asBytes, _ := json.Marshal(view)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
+
+//===================================================================
+//totalsOf: this func will return totals of account for currency
+//===================================================================
+func (a *ConsolidatedAccount) totalsOf(currency string) *TransactionTotals {
+	totals, found := a.Totals[currency]
+	if !found {
+		totals = &TransactionTotals{}
+		a.Totals[currency] = totals
+	}
+	return totals
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//======================================================================
+// bankCurrency : home currency of each bank , used when transfer does
+// not name a currency
+//======================================================================
+var bankCurrency = map[string]string{
+	"HBLPK": "PKR",
+	"HBLTR": "TRY",
+}
+
+const (
+	defaultCurrency     = "PKR"
+	ctrThresholdKeyType = "ctrThreshold"
+	ctrKeyType          = "ctr"
+	ctrTotalKeyType     = "ctrTotal"
+	businessDayFormat   = "2006-01-02"
+)
+
+//====================================================================
+//CtrThreshold : cash transaction reporting limit for one currency
+//====================================================================
+type CtrThreshold struct {
+	ObjectType string  `json:"doc_type"`
+	Currency   string  `json:"currency"`
+	Amount     float64 `json:"amount"`
+	UpdatedBy  string  `json:"updated_by"`
+}
+
+//=========================================================================
+//CurrencyTransactionReport : customer total over threshold in business day
+//=========================================================================
+type CurrencyTransactionReport struct {
+	ObjectType     string   `json:"doc_type"`
+	BusinessDay    string   `json:"business_day"`
+	CustID         string   `json:"cust_id"`
+	MSPID          string   `json:"msp_id"`
+	Currency       string   `json:"currency"`
+	Threshold      float64  `json:"threshold"`
+	Total          float64  `json:"total"`
+	Accounts       []string `json:"accounts"`
+	TransactionIDs []string `json:"transaction_ids"`
+	Created        string   `json:"created"`
+}
+
+//====================================================================
+//currencyOfBranch: this func will return home currency of branch bank
+//====================================================================
+func currencyOfBranch(branchCode string) string {
+	if currency, found := bankCurrency[bankOfBranch(branchCode)]; found {
+		return currency
+	}
+	return defaultCurrency
+}
+
+//=======================================================================
+//getCtrThreshold: this func will return threshold configured for currency
+//=======================================================================
+func getCtrThreshold(stub shim.ChaincodeStubInterface, currency string) (CtrThreshold, error) {
+	threshold := CtrThreshold{}
+	key, err := stub.CreateCompositeKey(ctrThresholdKeyType, []string{currency})
+	if err != nil {
+		return threshold, err
+	}
+	asBytes, err := stub.GetState(key)
+	if err != nil {
+		return threshold, err
+	}
+	json.Unmarshal(asBytes, &threshold)
+	return threshold, nil
+}
+
+//==========================================================================
+//CtrDailyTotal : running total of customer transfers in one currency on one
+//business day , Reported is set once total goes over threshold
+//==========================================================================
+type CtrDailyTotal struct {
+	ObjectType     string   `json:"doc_type"`
+	Total          float64  `json:"total"`
+	Accounts       []string `json:"accounts"`
+	TransactionIDs []string `json:"transaction_ids"`
+	Reported       string   `json:"reported,omitempty"`
+}
+
+//=========================================================================
+//ctrBatch : running totals and CTRs gone over threshold of transfers created
+//by one invocation. State written earlier in same invocation can not be read
+//back and only last event of a transaction is kept , so batch and standing
+//instruction runs carry both from one transfer to the next
+//=========================================================================
+type ctrBatch struct {
+	totals  map[string]CtrDailyTotal
+	crossed []CurrencyTransactionReport
+}
+
+//=============================================================
+//newCtrBatch: this func will return empty CTR batch
+//=============================================================
+func newCtrBatch() *ctrBatch {
+	return &ctrBatch{totals: map[string]CtrDailyTotal{}}
+}
+
+//=====================================================================
+//raiseEvent: this func will raise one evtctr event with every CTR that
+//went over threshold in invocation
+//=====================================================================
+func (c *ctrBatch) raiseEvent(stub shim.ChaincodeStubInterface) error {
+	if len(c.crossed) == 0 {
+		return nil
+	}
+	asBytes, _ := json.Marshal(c.crossed)
+	return stub.SetEvent("evtctr", asBytes)
+}
+
+//=====================================================================================
+//checkCurrencyTransactionReport: this func will add amount of current transfer to the
+//customer running total of business day over all customer accounts. When total goes
+//over the currency threshold a CTR is created and event is raised , later transfers of
+//the same day are added to that CTR. Transfers of a batch pass ctr batch and their
+//CTRs are raised by caller in one event
+//=====================================================================================
+func checkCurrencyTransactionReport(stub shim.ChaincodeStubInterface, kyc Kyc, accNo string, amount float64, currency string, txID string, batch *ctrBatch) error {
+	threshold, err := getCtrThreshold(stub, currency)
+	if err != nil {
+		return err
+	}
+	if threshold.Amount <= 0 {
+		return nil
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return err
+	}
+	businessDay := now.Format(businessDayFormat)
+	attributes := []string{businessDay, bankOfBranch(kyc.MSPID), kyc.CustID, currency}
+
+	totalKey, err := stub.CreateCompositeKey(ctrTotalKeyType, attributes)
+	if err != nil {
+		return err
+	}
+	total := CtrDailyTotal{}
+	found := false
+	if batch != nil {
+		total, found = batch.totals[totalKey]
+	}
+	if !found {
+		asBytes, err := stub.GetState(totalKey)
+		if err != nil {
+			return err
+		}
+		json.Unmarshal(asBytes, &total)
+	}
+	total.ObjectType = ctrTotalKeyType
+	total.Total = roundAmount(total.Total + amount)
+	total.Accounts = appendUnique(total.Accounts, accNo)
+	total.TransactionIDs = append(total.TransactionIDs, txID)
+	crossed := total.Reported == "" && total.Total > threshold.Amount
+	if crossed {
+		total.Reported = now.Format(time.RFC3339)
+	}
+	if batch != nil {
+		batch.totals[totalKey] = total
+	}
+	asBytes, _ := json.Marshal(total)
+	if err := stub.PutState(totalKey, asBytes); err != nil {
+		return err
+	}
+	if total.Reported == "" {
+		return nil
+	}
+
+	ctrKey, err := stub.CreateCompositeKey(ctrKeyType, attributes)
+	if err != nil {
+		return err
+	}
+	ctr := CurrencyTransactionReport{
+		ObjectType:     ctrKeyType,
+		BusinessDay:    businessDay,
+		CustID:         kyc.CustID,
+		MSPID:          bankOfBranch(kyc.MSPID),
+		Currency:       currency,
+		Threshold:      threshold.Amount,
+		Total:          total.Total,
+		Accounts:       total.Accounts,
+		TransactionIDs: total.TransactionIDs,
+		Created:        total.Reported,
+	}
+	asBytes, _ = json.Marshal(ctr)
+	if err := stub.PutState(ctrKey, asBytes); err != nil {
+		return err
+	}
+	if !crossed {
+		return nil
+	}
+	if batch != nil {
+		batch.crossed = append(batch.crossed, ctr)
+		return nil
+	}
+	return stub.SetEvent("evtctr", asBytes)
+}
+
+//=====================================================================================
+//releaseCurrencyTransactionReport: this func will take expired transfer out of running
+//total of business day it was counted on. CTR already raised for the day is kept as
+//filed. Expiry sweep passes ctr batch so transfers of same customer add up
+//=====================================================================================
+func releaseCurrencyTransactionReport(stub shim.ChaincodeStubInterface, transaction Transaction, batch *ctrBatch) error {
+	created, err := time.Parse(time.RFC3339, transaction.CreatedAt)
+	if err != nil {
+		// written before ledger time was kept , never counted
+		return nil
+	}
+	entry, err := getAccountIndex(stub, transaction.SenderAccount)
+	if err != nil {
+		return err
+	}
+	if entry.CustID == "" {
+		return nil
+	}
+	totalKey, err := stub.CreateCompositeKey(ctrTotalKeyType, []string{created.Format(businessDayFormat), entry.MSPID, entry.CustID, transaction.Currency})
+	if err != nil {
+		return err
+	}
+	total := CtrDailyTotal{}
+	found := false
+	if batch != nil {
+		total, found = batch.totals[totalKey]
+	}
+	if !found {
+		asBytes, err := stub.GetState(totalKey)
+		if err != nil {
+			return err
+		}
+		json.Unmarshal(asBytes, &total)
+	}
+	ids := []string{}
+	for _, txID := range total.TransactionIDs {
+		if txID != transaction.TransactionID {
+			ids = append(ids, txID)
+		}
+	}
+	if len(ids) == len(total.TransactionIDs) {
+		return nil
+	}
+	total.TransactionIDs = ids
+	total.Total = roundAmount(total.Total - transaction.Amount)
+	if batch != nil {
+		batch.totals[totalKey] = total
+	}
+	asBytes, _ := json.Marshal(total)
+	return stub.PutState(totalKey, asBytes)
+}
+
+//===================================================================
+//setCtrThreshold: this func will configure network wide CTR threshold
+//of currency , admin user only
+//args[0]: currency
+//args[1]: threshold amount , 0 disables CTR for the currency
+//===================================================================
+func (b *Bank) setCtrThreshold(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 2 arguments Currency and Threshold"}`)
+	}
+	userType, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok || strings.ToLower(userType) != "admin" {
+		return shim.Error(`{"status": 403 , "message": "Only admin user can set CTR threshold"}`)
+	}
+	amount, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || amount < 0 {
+		return shim.Error(`{"status": 500 , "message": "Can Not Convert Threshold to Number"}`)
+	}
+	id, _ := cid.GetID(stub)
+	threshold := CtrThreshold{
+		ObjectType: ctrThresholdKeyType,
+		Currency:   strings.ToUpper(args[0]),
+		Amount:     amount,
+		UpdatedBy:  id,
+	}
+	key, err := stub.CreateCompositeKey(ctrThresholdKeyType, []string{threshold.Currency})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	asBytes, _ := json.Marshal(threshold)
+	err = stub.PutState(key, asBytes)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
+}
+
+//=============================================================
+//getCtrThresholds: this func will return all CTR thresholds
+//=============================================================
+func (b *Bank) getCtrThresholds(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(ctrThresholdKeyType, []string{})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	defer resultsIterator.Close()
+
+	result := []CtrThreshold{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		threshold := CtrThreshold{}
+		json.Unmarshal(queryResponse.Value, &threshold)
+		result = append(result, threshold)
+	}
+	asBytes, _ := json.Marshal(result)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
+
+//==================================================================================
+//getCurrencyTransactionReports: this func will return CTRs between two business days
+//args[0]: from date (YYYY-MM-DD)
+//args[1]: to date (YYYY-MM-DD) , inclusive
+//==================================================================================
+func (b *Bank) getCurrencyTransactionReports(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 2 arguments From and To date"}`)
+	}
+	if !isRegulator(stub) {
+		return shim.Error(`{"status": 403 , "message": "Only regulator can query CTR"}`)
+	}
+	from, err := time.Parse(businessDayFormat, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "Please Provide From date as YYYY-MM-DD"}`)
+	}
+	to, err := time.Parse(businessDayFormat, args[1])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "Please Provide To date as YYYY-MM-DD"}`)
+	}
+	if to.Before(from) {
+		return shim.Error(`{"status": 500 , "message": "To date is before From date"}`)
+	}
+
+	result := []CurrencyTransactionReport{}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		resultsIterator, err := stub.GetStateByPartialCompositeKey(ctrKeyType, []string{day.Format(businessDayFormat)})
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		for resultsIterator.HasNext() {
+			queryResponse, err := resultsIterator.Next()
+			if err != nil {
+				resultsIterator.Close()
+				return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+			}
+			ctr := CurrencyTransactionReport{}
+			json.Unmarshal(queryResponse.Value, &ctr)
+			result = append(result, ctr)
+		}
+		resultsIterator.Close()
+	}
+	asBytes, _ := json.Marshal(result)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
@@ -0,0 +1,15 @@
+package main
+
+import "testing"
+
+func TestSetCtrThresholdAdminOnly(t *testing.T) {
+	l := newTestLedger(t)
+	l.mustBeAdminOnly(l.bank.setCtrThreshold, "PKR", "2000000")
+	threshold, err := getCtrThreshold(l.stub, "PKR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if threshold.Amount != 2000000 {
+		t.Fatalf("threshold is %v , want 2000000", threshold.Amount)
+	}
+}
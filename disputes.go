@@ -0,0 +1,591 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//=============================================================================
+// Disputes : sender or receiver branch of a transfer raises a dispute case on
+// it , both branches add evidence hashes and messages until the case is closed
+// with an outcome. Counterparty has to respond before the response deadline ,
+// a dispute closed as reversed returns disputed amount to sender
+//=============================================================================
+const (
+	disputeKeyType             = "dispute"
+	disputeResponseConfig      = "disputeResponseDays"
+	defaultDisputeResponseDays = 10
+)
+
+//===============================================================
+// DisputeStatus : state of dispute case
+//===============================================================
+type DisputeStatus string
+
+const (
+	DISPUTEOPEN   DisputeStatus = "open"
+	DISPUTECLOSED DisputeStatus = "closed"
+)
+
+//===============================================================
+// DisputeOutcome : how dispute case was closed
+//===============================================================
+type DisputeOutcome string
+
+const (
+	OUTCOMEREVERSED  DisputeOutcome = "reversed"
+	OUTCOMERESOLVED  DisputeOutcome = "resolved"
+	OUTCOMEDECLINED  DisputeOutcome = "declined"
+	OUTCOMEWITHDRAWN DisputeOutcome = "withdrawn"
+)
+
+//=====================================================================
+// disputeReasons : reason codes a branch can give for dispute
+//=====================================================================
+var disputeReasons = map[string]string{
+	"NREC": "Funds not received",
+	"DUPL": "Duplicate transfer",
+	"AMNT": "Incorrect amount",
+	"WACC": "Wrong beneficiary account",
+	"UNAU": "Unauthorised transfer",
+	"FRAD": "Fraud",
+}
+
+//===============================================================
+//DisputeEvidence : hash of document added to dispute by a branch
+//===============================================================
+type DisputeEvidence struct {
+	DocHash     string `json:"doc_hash"`
+	Description string `json:"description,omitempty"`
+	Branch      string `json:"branch"`
+	AddedBy     string `json:"added_by"`
+	AddedAt     string `json:"added_at"`
+}
+
+//===============================================================
+//DisputeMessage : message of dispute thread
+//===============================================================
+type DisputeMessage struct {
+	Branch string `json:"branch"`
+	SentBy string `json:"sent_by"`
+	Text   string `json:"text"`
+	SentAt string `json:"sent_at"`
+}
+
+//=======================================================================
+//Dispute : case raised on transfer by RaisedByBranch , CounterpartyBranch
+//is the other branch of the transfer
+//=======================================================================
+type Dispute struct {
+	ObjectType         string            `json:"doc_type"`
+	DisputeID          string            `json:"dispute_id"`
+	TransactionID      string            `json:"transaction_id"`
+	ReasonCode         string            `json:"reason_code"`
+	Description        string            `json:"description"`
+	Amount             float64           `json:"amount"`
+	Status             DisputeStatus     `json:"status"`
+	RaisedByBranch     string            `json:"raised_by_branch"`
+	CounterpartyBranch string            `json:"counterparty_branch"`
+	RaisedBy           string            `json:"raised_by"`
+	RaisedAt           string            `json:"raised_at"`
+	ResponseDeadline   string            `json:"response_deadline"`
+	Evidence           []DisputeEvidence `json:"evidence,omitempty"`
+	Messages           []DisputeMessage  `json:"messages,omitempty"`
+	Outcome            DisputeOutcome    `json:"outcome,omitempty"`
+	OutcomeComment     string            `json:"outcome_comment,omitempty"`
+	ClosedBy           string            `json:"closed_by,omitempty"`
+	ClosedAt           string            `json:"closed_at,omitempty"`
+	ReversalID         string            `json:"reversal_id,omitempty"`
+}
+
+//======================================================================
+//getDisputeState: this func will read dispute by id
+//======================================================================
+func getDisputeState(stub shim.ChaincodeStubInterface, disputeID string) (Dispute, error) {
+	dispute := Dispute{}
+	key, err := stub.CreateCompositeKey(disputeKeyType, []string{disputeID})
+	if err != nil {
+		return dispute, err
+	}
+	asBytes, err := stub.GetState(key)
+	if err != nil {
+		return dispute, err
+	}
+	json.Unmarshal(asBytes, &dispute)
+	return dispute, nil
+}
+
+//======================================================================
+//putDispute: this func will write dispute
+//======================================================================
+func putDispute(stub shim.ChaincodeStubInterface, dispute Dispute) error {
+	key, err := stub.CreateCompositeKey(disputeKeyType, []string{dispute.DisputeID})
+	if err != nil {
+		return err
+	}
+	dispute.ObjectType = disputeKeyType
+	asBytes, _ := json.Marshal(dispute)
+	return stub.PutState(key, asBytes)
+}
+
+//=====================================================================
+//callerBranch: this func will return branch of client as MSPID_code
+//=====================================================================
+func callerBranch(stub shim.ChaincodeStubInterface) (string, error) {
+	val, ok, err := cid.GetAttributeValue(stub, "branchCode")
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", errors.New("Client has no Attribute branchCode")
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return "", err
+	}
+	return mspID + "_" + val, nil
+}
+
+//=====================================================================
+//getDisputeResponseTime: this func will return time counterparty has
+//to respond to dispute
+//=====================================================================
+func getDisputeResponseTime(stub shim.ChaincodeStubInterface) (time.Duration, error) {
+	key, err := stub.CreateCompositeKey(configKeyType, []string{disputeResponseConfig})
+	if err != nil {
+		return 0, err
+	}
+	asBytes, err := stub.GetState(key)
+	if err != nil {
+		return 0, err
+	}
+	days := defaultDisputeResponseDays
+	if asBytes != nil {
+		if days, err = strconv.Atoi(string(asBytes)); err != nil {
+			return 0, err
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+//=========================================================================
+//updateDispute: this func will read open dispute and return branch of
+//client , which has to be one of the branches of disputed transfer
+//=========================================================================
+func updateDispute(stub shim.ChaincodeStubInterface, disputeID string) (Dispute, string, error) {
+	dispute, err := getDisputeState(stub, disputeID)
+	if err != nil {
+		return dispute, "", err
+	}
+	if dispute.DisputeID == "" {
+		return dispute, "", errors.New("Dispute not found")
+	}
+	if dispute.Status != DISPUTEOPEN {
+		return dispute, "", errors.New("Dispute is " + string(dispute.Status))
+	}
+	branch, err := callerBranch(stub)
+	if err != nil {
+		return dispute, "", err
+	}
+	if branch != dispute.RaisedByBranch && branch != dispute.CounterpartyBranch {
+		return dispute, "", errors.New("You are not party of this dispute")
+	}
+	return dispute, branch, nil
+}
+
+//====================================================================================
+//raiseDispute: this func will open dispute case on transfer by its sender or receiver
+//branch , a transfer has at most one open dispute
+//args[0]: transaction id
+//args[1]: reason code (NREC , DUPL , AMNT , WACC , UNAU , FRAD)
+//args[2]: description
+//args[3]: (optional) disputed amount , full amount when empty
+//args[4]: (optional) hash of evidence document
+//====================================================================================
+func (b *Bank) raiseDispute(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) < 3 || len(args) > 5 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Transaction ID , Reason Code , Description , optional Amount and Evidence Hash"}`)
+	}
+	branch, err := callerBranch(stub)
+	if err != nil {
+		return shim.Error(`{"status": 403 , "message": "` + string(err.Error()) + `"}`)
+	}
+	transaction := Transaction{}
+	asBytes, err := getTransactionState(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	json.Unmarshal(asBytes, &transaction)
+	if transaction.TransactionID == "" {
+		return shim.Error(`{"status": 404 , "message": "Transaction not found"}`)
+	}
+	var counterparty string
+	switch branch {
+	case transaction.SenderBranchName:
+		counterparty = transaction.ReceiverBranchName
+	case transaction.ReceiverBranchName:
+		counterparty = transaction.SenderBranchName
+	default:
+		return shim.Error(`{"status": 403 , "message": "Only sender or receiver branch can dispute transaction"}`)
+	}
+	if transaction.TransactionStatus == REJECTEDSENDERBANK || transaction.TransactionStatus == REJECTEDRECEIVERBANK || transaction.TransactionStatus == CANCELLED || transaction.TransactionStatus == EXPIRED {
+		return shim.Error(`{"status": 403 , "message": "Transaction is ` + string(transaction.TransactionStatus) + `"}`)
+	}
+	if transaction.DisputeID != "" {
+		open, err := getDisputeState(stub, transaction.DisputeID)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		if open.Status == DISPUTEOPEN {
+			return shim.Error(`{"status": 409 , "message": "Dispute ` + open.DisputeID + ` is already open on transaction"}`)
+		}
+	}
+	reasonCode := strings.ToUpper(strings.TrimSpace(args[1]))
+	if _, found := disputeReasons[reasonCode]; !found {
+		codes := []string{}
+		for code := range disputeReasons {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		return shim.Error(`{"status": 500 , "message": "Please Provide Reason Code (` + strings.Join(codes, " , ") + `)"}`)
+	}
+	refundable := transaction.remainingRefund()
+	amount := refundable
+	if len(args) > 3 && args[3] != "" {
+		amount, err = strconv.ParseFloat(args[3], 64)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "Can Not Convert Amount to Number"}`)
+		}
+		amount = roundAmount(amount)
+	}
+	if amount <= 0 || amount > refundable {
+		return shim.Error(`{"status": 403 , "message": "Disputed amount must be more than 0 and at most ` + strconv.FormatFloat(refundable, 'f', -1, 64) + `"}`)
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	responseTime, err := getDisputeResponseTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	_, actor, err := auditStamp(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	dispute := Dispute{
+		DisputeID:          stub.GetTxID(),
+		TransactionID:      transaction.TransactionID,
+		ReasonCode:         reasonCode,
+		Description:        args[2],
+		Amount:             amount,
+		Status:             DISPUTEOPEN,
+		RaisedByBranch:     branch,
+		CounterpartyBranch: counterparty,
+		RaisedBy:           actor,
+		RaisedAt:           now.Format(time.RFC3339),
+		ResponseDeadline:   now.Add(responseTime).Format(time.RFC3339),
+	}
+	if len(args) > 4 && args[4] != "" {
+		dispute.Evidence = append(dispute.Evidence, DisputeEvidence{
+			DocHash: args[4],
+			Branch:  branch,
+			AddedBy: actor,
+			AddedAt: dispute.RaisedAt,
+		})
+	}
+	if err := putDispute(stub, dispute); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	transaction.DisputeID = dispute.DisputeID
+	if err := putTransaction(stub, transaction); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	stub.SetEvent("evtdispute", []byte(dispute.DisputeID))
+	return shim.Success([]byte(`{"status": 200 , "message": "Data Updated" , "dispute_id": "` + dispute.DisputeID + `"}`))
+}
+
+//=====================================================================
+//addDisputeEvidence: this func will add hash of evidence document to
+//open dispute
+//args[0]: dispute id
+//args[1]: hash of document
+//args[2]: (optional) description
+//=====================================================================
+func (b *Bank) addDisputeEvidence(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 && len(args) != 3 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Dispute ID , Document Hash and optional Description"}`)
+	}
+	if strings.TrimSpace(args[1]) == "" {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Document Hash"}`)
+	}
+	dispute, branch, err := updateDispute(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 403 , "message": "` + string(err.Error()) + `"}`)
+	}
+	now, actor, err := auditStamp(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	evidence := DisputeEvidence{
+		DocHash: args[1],
+		Branch:  branch,
+		AddedBy: actor,
+		AddedAt: now,
+	}
+	if len(args) == 3 {
+		evidence.Description = args[2]
+	}
+	dispute.Evidence = append(dispute.Evidence, evidence)
+	if err := putDispute(stub, dispute); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	stub.SetEvent("evtdispute", []byte(dispute.DisputeID))
+	return shim.Success([]byte(`{"status": 200 , "message": "Data Updated"}`))
+}
+
+//=====================================================================
+//addDisputeMessage: this func will add message to thread of dispute
+//args[0]: dispute id
+//args[1]: message
+//=====================================================================
+func (b *Bank) addDisputeMessage(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Dispute ID and Message"}`)
+	}
+	if strings.TrimSpace(args[1]) == "" {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Message"}`)
+	}
+	dispute, branch, err := updateDispute(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 403 , "message": "` + string(err.Error()) + `"}`)
+	}
+	now, actor, err := auditStamp(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	dispute.Messages = append(dispute.Messages, DisputeMessage{
+		Branch: branch,
+		SentBy: actor,
+		Text:   args[1],
+		SentAt: now,
+	})
+	if err := putDispute(stub, dispute); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	stub.SetEvent("evtdispute", []byte(dispute.DisputeID))
+	return shim.Success([]byte(`{"status": 200 , "message": "Data Updated"}`))
+}
+
+//=========================================================================================
+//closeDispute: this func will close dispute with an outcome. Raising branch can withdraw
+//it , counterparty can decline it , either can mark it resolved and receiver branch of
+//transfer can close it as reversed which returns disputed amount to sender
+//args[0]: dispute id
+//args[1]: outcome (reversed , resolved , declined , withdrawn)
+//args[2]: (optional) comment
+//=========================================================================================
+func (b *Bank) closeDispute(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 && len(args) != 3 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Dispute ID , Outcome and optional Comment"}`)
+	}
+	dispute, branch, err := updateDispute(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 403 , "message": "` + string(err.Error()) + `"}`)
+	}
+	now, actor, err := auditStamp(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	outcome := DisputeOutcome(strings.ToLower(args[1]))
+	switch outcome {
+	case OUTCOMEWITHDRAWN:
+		if branch != dispute.RaisedByBranch {
+			return shim.Error(`{"status": 403 , "message": "Only ` + dispute.RaisedByBranch + ` can withdraw this dispute"}`)
+		}
+	case OUTCOMEDECLINED:
+		if branch != dispute.CounterpartyBranch {
+			return shim.Error(`{"status": 403 , "message": "Only ` + dispute.CounterpartyBranch + ` can decline this dispute"}`)
+		}
+	case OUTCOMERESOLVED:
+	case OUTCOMEREVERSED:
+		transaction := Transaction{}
+		asBytes, err := getTransactionState(stub, dispute.TransactionID)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		json.Unmarshal(asBytes, &transaction)
+		if branch != transaction.ReceiverBranchName {
+			return shim.Error(`{"status": 403 , "message": "Only ` + transaction.ReceiverBranchName + ` can return funds of this transfer"}`)
+		}
+		if transaction.ReversalOf != "" || transaction.TransactionStatus != ACCEPTEDRECEIVERBANK {
+			return shim.Error(`{"status": 403 , "message": "Transaction can not be reversed"}`)
+		}
+		if transaction.openReversal() != nil {
+			return shim.Error(`{"status": 409 , "message": "Reversal of transaction is already requested"}`)
+		}
+		transaction.Reversals = append(transaction.Reversals, TransferReversal{
+			Amount:      dispute.Amount,
+			ReasonCode:  "DISP",
+			Comment:     "Dispute " + dispute.DisputeID,
+			RequestedBy: dispute.RaisedBy,
+			RequestedAt: dispute.RaisedAt,
+			ProcessedBy: actor,
+			ProcessedAt: now,
+		})
+		reversal := &transaction.Reversals[len(transaction.Reversals)-1]
+		if len(args) == 3 {
+			reversal.ProcessComment = args[2]
+		}
+		if err := acceptReversal(stub, &transaction, reversal, now, actor); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		if err := putTransaction(stub, transaction); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		dispute.ReversalID = reversal.ReversalID
+	default:
+		return shim.Error(`{"status": 500 , "message": "Please Provide Outcome (reversed , resolved , declined , withdrawn)"}`)
+	}
+	dispute.Status = DISPUTECLOSED
+	dispute.Outcome = outcome
+	dispute.ClosedBy = actor
+	dispute.ClosedAt = now
+	if len(args) == 3 {
+		dispute.OutcomeComment = args[2]
+	}
+	if err := putDispute(stub, dispute); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	stub.SetEvent("evtdispute", []byte(dispute.DisputeID))
+	return shim.Success([]byte(`{"status": 200 , "message": "Data Updated"}`))
+}
+
+//=====================================================================
+//getDispute: this func will return dispute with evidence and messages
+//args[0]: dispute id
+//=====================================================================
+func (b *Bank) getDispute(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Dispute ID"}`)
+	}
+	dispute, err := getDisputeState(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if dispute.DisputeID == "" {
+		return shim.Error(`{"status": 404 , "message": "Dispute not found"}`)
+	}
+	if !isRegulator(stub) {
+		branch, err := callerBranch(stub)
+		if err != nil {
+			return shim.Error(`{"status": 403 , "message": "` + string(err.Error()) + `"}`)
+		}
+		if bankOfBranch(branch) != bankOfBranch(dispute.RaisedByBranch) && bankOfBranch(branch) != bankOfBranch(dispute.CounterpartyBranch) {
+			return shim.Error(`{"status": 403 , "message": "You are not party of this dispute"}`)
+		}
+	}
+	asBytes, _ := json.Marshal(dispute)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
+
+//==========================================================================================
+//getOpenDisputes: this func will return open disputes of branch , oldest deadline first.
+//Branch of client is used when no branch is given , regulator has to give MSPID_code
+//args[0]: (optional) branch code
+//==========================================================================================
+func (b *Bank) getOpenDisputes(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) > 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide optional Branch Code"}`)
+	}
+	var branch string
+	if isRegulator(stub) {
+		if len(args) != 1 || !strings.Contains(args[0], "_") {
+			return shim.Error(`{"status": 500 , "message": "Please Provide Branch Code as MSPID_code"}`)
+		}
+		branch = args[0]
+	} else if len(args) == 1 {
+		mspID, err := cid.GetMSPID(stub)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		branch = mspID + "_" + args[0]
+	} else {
+		var err error
+		if branch, err = callerBranch(stub); err != nil {
+			return shim.Error(`{"status": 403 , "message": "` + string(err.Error()) + `"}`)
+		}
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(disputeKeyType, []string{})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	defer resultsIterator.Close()
+
+	type OpenDispute struct {
+		Dispute
+		Overdue bool `json:"overdue"`
+	}
+	disputes := []OpenDispute{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		dispute := Dispute{}
+		json.Unmarshal(queryResponse.Value, &dispute)
+		if dispute.Status != DISPUTEOPEN || (dispute.RaisedByBranch != branch && dispute.CounterpartyBranch != branch) {
+			continue
+		}
+		deadline, err := time.Parse(time.RFC3339, dispute.ResponseDeadline)
+		disputes = append(disputes, OpenDispute{Dispute: dispute, Overdue: err == nil && now.After(deadline)})
+	}
+	sort.Slice(disputes, func(i, j int) bool {
+		return disputes[i].ResponseDeadline < disputes[j].ResponseDeadline
+	})
+	asBytes, _ := json.Marshal(disputes)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
+
+//=====================================================================
+//setDisputeResponseDays: this func will configure network wide days
+//counterparty has to respond to a dispute , admin user only
+//args[0]: days
+//=====================================================================
+func (b *Bank) setDisputeResponseDays(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 1 Argument"}`)
+	}
+	userType, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok || strings.ToLower(userType) != "admin" {
+		return shim.Error(`{"status": 403 , "message": "Only admin user can set dispute response days"}`)
+	}
+	days, err := strconv.Atoi(args[0])
+	if err != nil || days <= 0 {
+		return shim.Error(`{"status": 500 , "message": "Can Not Convert Days to Number"}`)
+	}
+	key, err := stub.CreateCompositeKey(configKeyType, []string{disputeResponseConfig})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	err = stub.PutState(key, []byte(strconv.Itoa(days)))
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
+}
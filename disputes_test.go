@@ -0,0 +1,18 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetDisputeResponseDaysAdminOnly(t *testing.T) {
+	l := newTestLedger(t)
+	l.mustBeAdminOnly(l.bank.setDisputeResponseDays, "5")
+	responseTime, err := getDisputeResponseTime(l.stub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if responseTime != 5*24*time.Hour {
+		t.Fatalf("dispute response time is %v , want 5 days", responseTime)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//=============================================================================
+// Transfer expiry : each waiting stage has a time to live in hours kept under
+// (config , transferTTLHours , stage). Age in stage is taken from ledger time
+// status was last changed , Created given by client is never used. Expired
+// transfers are taken out of CTR day total of sender , no funds are held on
+// ledger
+//=============================================================================
+const (
+	transferTTLConfig  = "transferTTLHours"
+	defaultExpiryBatch = 100
+)
+
+//=====================================================================
+// defaultTransferTTLHours : time to live of stage when not configured
+//=====================================================================
+var defaultTransferTTLHours = map[TransactionStatus]int{
+	INITIATED:          72,
+	PENDINGTRANSACTION: 168,
+	ACCEPTEDSENDERBANK: 120,
+}
+
+//=======================================================================
+//getTransferTTL: this func will return time to live of stage , zero when
+//transfers in stage never expire
+//=======================================================================
+func getTransferTTL(stub shim.ChaincodeStubInterface, stage TransactionStatus) (time.Duration, error) {
+	key, err := stub.CreateCompositeKey(configKeyType, []string{transferTTLConfig, string(stage)})
+	if err != nil {
+		return 0, err
+	}
+	asBytes, err := stub.GetState(key)
+	if err != nil {
+		return 0, err
+	}
+	hours := defaultTransferTTLHours[stage]
+	if asBytes != nil {
+		if hours, err = strconv.Atoi(string(asBytes)); err != nil {
+			return 0, err
+		}
+	}
+	return time.Duration(hours) * time.Hour, nil
+}
+
+//=====================================================================
+//stageEnteredAt: this func will return ledger time transfer entered its
+//current status , transfers written before status time was kept take
+//it from history of their key
+//=====================================================================
+func stageEnteredAt(stub shim.ChaincodeStubInterface, t Transaction) (time.Time, error) {
+	if t.StageStatus == t.TransactionStatus && t.StatusChangedAt != "" {
+		return time.Parse(time.RFC3339, t.StatusChangedAt)
+	}
+	key, err := transactionKey(stub, t.TransactionID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	entered, err := historyStageStart(stub, key, func(value []byte) bool {
+		old := Transaction{}
+		json.Unmarshal(value, &old)
+		return old.TransactionStatus == t.TransactionStatus
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if entered.IsZero() {
+		return time.Time{}, errors.New("Stage time of transaction " + t.TransactionID + " is not known")
+	}
+	return entered, nil
+}
+
+//=====================================================================
+//legsMoved: this func will check a leg of routed transfer has already
+//been settled or answered by its bank
+//=====================================================================
+func (t Transaction) legsMoved() bool {
+	for _, leg := range t.Legs {
+		if leg.Status != LEGPENDING {
+			return true
+		}
+	}
+	return false
+}
+
+//=====================================================================
+//setTransferTTL: this func will configure network wide time to live of
+//a stage , only admin user can change it
+//args[0]: stage (Initiate , Pending , Sender Bank Approved)
+//args[1]: hours , 0 to never expire
+//=====================================================================
+func (b *Bank) setTransferTTL(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Stage and Hours"}`)
+	}
+	userType, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok || strings.ToLower(userType) != "admin" {
+		return shim.Error(`{"status": 403 , "message": "Only admin user can set transfer time to live"}`)
+	}
+	stage := TransactionStatus(args[0])
+	if _, found := defaultTransferTTLHours[stage]; !found {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Stage (` + string(INITIATED) + ` , ` + string(PENDINGTRANSACTION) + ` , ` + string(ACCEPTEDSENDERBANK) + `)"}`)
+	}
+	hours, err := strconv.Atoi(args[1])
+	if err != nil || hours < 0 {
+		return shim.Error(`{"status": 500 , "message": "Can Not Convert Hours to Number"}`)
+	}
+	key, err := stub.CreateCompositeKey(configKeyType, []string{transferTTLConfig, args[0]})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	err = stub.PutState(key, []byte(strconv.Itoa(hours)))
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
+}
+
+//=====================================================================
+//getTransferTTLs: this func will return time to live of every stage
+//=====================================================================
+func (b *Bank) getTransferTTLs(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	result := make(map[TransactionStatus]float64)
+	for stage := range defaultTransferTTLHours {
+		ttl, err := getTransferTTL(stub, stage)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		result[stage] = ttl.Hours()
+	}
+	asBytes, _ := json.Marshal(result)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
+
+//=====================================================================================
+//expireStaleTransfers: this func will move transfers of caller bank that stayed in a
+//stage longer than its time to live , or missed signature deadline , to Expired. Routed
+//transfer with a leg already moved and refunds of reversals , already counted on the
+//reversed transfer , are left for their banks to finish. Transfers whose stage time
+//can not be read are returned as unknown. One event lists every transfer expired by
+//the sweep
+//args[0]: (optional) max transfers to expire , default 100
+//=====================================================================================
+func (b *Bank) expireStaleTransfers(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) > 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide optional Batch Size"}`)
+	}
+	userType, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok || strings.ToLower(userType) != schedulerUserType {
+		return shim.Error(`{"status": 403 , "message": "Only scheduler can expire transfers"}`)
+	}
+	batch := defaultExpiryBatch
+	if len(args) == 1 {
+		if batch, err = strconv.Atoi(args[0]); err != nil || batch <= 0 {
+			return shim.Error(`{"status": 500 , "message": "Please Provide valid Batch Size"}`)
+		}
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	ttls := make(map[TransactionStatus]time.Duration)
+	for stage := range defaultTransferTTLHours {
+		if ttls[stage], err = getTransferTTL(stub, stage); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+	}
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(transactionKeyType, []string{})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	stale := []Transaction{}
+	unknown := []string{}
+	for resultsIterator.HasNext() && len(stale) < batch {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			resultsIterator.Close()
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		transaction := Transaction{}
+		json.Unmarshal(queryResponse.Value, &transaction)
+		if bankOfBranch(transaction.SenderBranchName) != mspID && bankOfBranch(transaction.ReceiverBranchName) != mspID {
+			continue
+		}
+		if transaction.ReversalOf != "" {
+			continue
+		}
+		if transaction.TransactionStatus == AWAITINGSIGNATURES {
+			deadline, err := time.Parse(time.RFC3339, transaction.SignatureDeadline)
+			if err == nil && now.After(deadline) {
+				transaction.Comment = "Signatures not collected before " + transaction.SignatureDeadline
+				stale = append(stale, transaction)
+			}
+			continue
+		}
+		ttl := ttls[transaction.TransactionStatus]
+		if ttl <= 0 {
+			continue
+		}
+		if transaction.TransactionStatus == ACCEPTEDSENDERBANK && transaction.legsMoved() {
+			continue
+		}
+		entered, err := stageEnteredAt(stub, transaction)
+		if err != nil {
+			unknown = append(unknown, transaction.TransactionID)
+			continue
+		}
+		if now.Sub(entered) < ttl {
+			continue
+		}
+		transaction.Comment = "Expired in stage " + string(transaction.TransactionStatus) + " after " + strconv.FormatFloat(ttl.Hours(), 'f', -1, 64) + " hours"
+		stale = append(stale, transaction)
+	}
+	resultsIterator.Close()
+
+	expired := []string{}
+	ctr := newCtrBatch()
+	for _, transaction := range stale {
+		transaction.ExpiredFrom = transaction.TransactionStatus
+		transaction.TransactionStatus = EXPIRED
+		if err := putTransaction(stub, transaction); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		if err := releaseCurrencyTransactionReport(stub, transaction, ctr); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		expired = append(expired, transaction.TransactionID)
+	}
+	asBytes, _ := json.Marshal(expired)
+	if len(expired) > 0 {
+		stub.SetEvent("evttransferexpired", asBytes)
+	}
+	unknownAsBytes, _ := json.Marshal(unknown)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + ` , "unknown": ` + string(unknownAsBytes) + `}`))
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+func TestSetTransferTTLAdminOnly(t *testing.T) {
+	l := newTestLedger(t)
+	l.mustBeAdminOnly(l.bank.setTransferTTL, string(INITIATED), "24")
+	ttl, err := getTransferTTL(l.stub, INITIATED)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl != 24*time.Hour {
+		t.Fatalf("ttl of %v is %v , want 24h", INITIATED, ttl)
+	}
+}
+
+// sweep : run expiry sweep as scheduler of bank and return expired ids
+func sweep(l *testLedger, mspID string) []string {
+	l.t.Helper()
+	l.as(mspID, "Scheduler", "001")
+	response := l.mustCall(l.bank.expireStaleTransfers)
+	result := struct{ Data []string }{}
+	if err := json.Unmarshal(response.Payload, &result); err != nil {
+		l.t.Fatalf("sweep returned %s: %v", response.Payload, err)
+	}
+	return result.Data
+}
+
+func TestExpireStaleTransfersAfterTTL(t *testing.T) {
+	l := newTransferLedger(t)
+	l.as("HBLPK", "admin", "001")
+	l.mustCall(l.bank.setTransferTTL, string(INITIATED), "24")
+	l.as("HBLPK", "maker", "001")
+	// over limit of Individual account , waits for sender bank
+	initiated := l.transfer("PK-1", "C1", "TR-1", "25000")
+	approved := l.transfer("PK-1", "C1", "TR-1", "1000")
+	if status := l.transaction(approved).TransactionStatus; status != ACCEPTEDSENDERBANK {
+		t.Fatalf("transfer within limit is %v , want %v", status, ACCEPTEDSENDERBANK)
+	}
+
+	l.mustRefuse(`"status": 403`, l.bank.expireStaleTransfers)
+
+	start := l.now
+	l.now = start.Add(23 * time.Hour)
+	if expired := sweep(l, "HBLPK"); len(expired) != 0 {
+		t.Fatalf("expired %v before time to live", expired)
+	}
+	l.now = start.Add(25 * time.Hour)
+	l.addBranch("MCBPK", "001")
+	if expired := sweep(l, "MCBPK"); len(expired) != 0 {
+		t.Fatalf("other bank expired %v", expired)
+	}
+	if expired := sweep(l, "HBLPK"); len(expired) != 1 || expired[0] != initiated {
+		t.Fatalf("expired %v , want %s", expired, initiated)
+	}
+	transaction := l.transaction(initiated)
+	if transaction.TransactionStatus != EXPIRED || transaction.ExpiredFrom != INITIATED {
+		t.Fatalf("transfer is %v from %v , want %v from %v", transaction.TransactionStatus, transaction.ExpiredFrom, EXPIRED, INITIATED)
+	}
+
+	// sender bank approved stage keeps default of 120 hours
+	l.now = start.Add(119 * time.Hour)
+	if expired := sweep(l, "HBLPK"); len(expired) != 0 {
+		t.Fatalf("expired %v before default time to live", expired)
+	}
+	l.now = start.Add(121 * time.Hour)
+	if expired := sweep(l, "HBLPK"); len(expired) != 1 || expired[0] != approved {
+		t.Fatalf("expired %v , want %s", expired, approved)
+	}
+}
+
+func TestExpireLegacyTransferFromKeyHistory(t *testing.T) {
+	l := newTransferLedger(t)
+	txID := l.transfer("PK-1", "C1", "TR-1", "25000")
+	start := l.now
+
+	// transfer rewritten a day later without stage time , as before it was kept
+	l.now = start.Add(24 * time.Hour)
+	l.call(func(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+		transaction := l.transaction(txID)
+		transaction.StageStatus = ""
+		transaction.StatusChangedAt = ""
+		key, _ := transactionKey(stub, txID)
+		asBytes, _ := json.Marshal(transaction)
+		if err := stub.PutState(key, asBytes); err != nil {
+			t.Fatal(err)
+		}
+		return shim.Success(nil)
+	})
+
+	l.now = start.Add(73 * time.Hour)
+	if expired := sweep(l, "HBLPK"); len(expired) != 1 || expired[0] != txID {
+		t.Fatalf("expired %v , want %s counted from first write", expired, txID)
+	}
+}
+
+func TestExpiredTransferLeavesCtrTotal(t *testing.T) {
+	l := newTransferLedger(t)
+	l.as("HBLPK", "admin", "001")
+	l.mustCall(l.bank.setCtrThreshold, "PKR", "30000")
+	l.mustCall(l.bank.setTransferTTL, string(INITIATED), "1")
+	l.as("HBLPK", "maker", "001")
+	l.transfer("PK-1", "C1", "TR-1", "25000")
+
+	l.now = l.now.Add(2 * time.Hour)
+	if expired := sweep(l, "HBLPK"); len(expired) != 1 {
+		t.Fatalf("expired %v , want transfer over limit", expired)
+	}
+	l.as("HBLPK", "maker", "001")
+	l.transfer("PK-1", "C1", "TR-1", "10000")
+
+	key, _ := l.stub.CreateCompositeKey(ctrKeyType, []string{l.now.Format(businessDayFormat), "HBLPK", "C1", "PKR"})
+	if asBytes, _ := l.stub.GetState(key); asBytes != nil {
+		t.Fatalf("CTR raised with expired transfer counted: %s", asBytes)
+	}
+}
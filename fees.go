@@ -0,0 +1,465 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//=============================================================================
+// Fee schedules are kept by each bank under (feeSchedule , MSPID , scheduleID).
+// Sender bank charges on its outgoing corridors and receiver bank on incoming
+// ones , most specific schedule matching corridor , account type and amount band
+// is applied. Charge bearer decides who pays :
+//
+//	OUR : sender pays every fee , receiver gets full amount
+//	SHA : sender pays sender bank fee , other fees come out of amount
+//	BEN : every fee comes out of amount
+//
+//=============================================================================
+const feeScheduleKeyType = "feeSchedule"
+
+//===============================================================
+// ChargeBearer : party of transfer that pays fees
+//===============================================================
+type ChargeBearer string
+
+const (
+	OUR ChargeBearer = "OUR"
+	SHA ChargeBearer = "SHA"
+	BEN ChargeBearer = "BEN"
+)
+
+//==============================================================================
+//FeeSchedule : fee bank charges on transfers , empty SenderMSP , ReceiverMSP and
+//AccountType match any and MaxAmount 0 means no upper bound of amount band.
+//Fee and amount band are in Currency , home currency of bank when empty , and
+//schedule only applies to transfers in that currency
+//==============================================================================
+type FeeSchedule struct {
+	ObjectType  string  `json:"doc_type"`
+	ScheduleID  string  `json:"schedule_id"`
+	MSPID       string  `json:"msp_id"`
+	SenderMSP   string  `json:"sender_msp"`
+	ReceiverMSP string  `json:"receiver_msp"`
+	AccountType string  `json:"account_type"`
+	Currency    string  `json:"currency"`
+	MinAmount   float64 `json:"min_amount"`
+	MaxAmount   float64 `json:"max_amount"`
+	FeeFixed    float64 `json:"fee_fixed"`
+	FeePercent  float64 `json:"fee_percent"`
+	UpdatedBy   string  `json:"updated_by"`
+	UpdatedAt   string  `json:"updated_at"`
+}
+
+//==========================================================
+//TransferFee : one fee charged on transfer and its payer
+//==========================================================
+type TransferFee struct {
+	MSPID      string       `json:"msp_id"`
+	Type       string       `json:"type"`
+	ScheduleID string       `json:"schedule_id,omitempty"`
+	Amount     float64      `json:"amount"`
+	PaidBy     ChargeBearer `json:"paid_by"`
+}
+
+//=====================================================================
+//TransferCharges : fees of transfer computed before it is written
+//=====================================================================
+type TransferCharges struct {
+	Bearer      ChargeBearer
+	SenderFee   float64
+	ReceiverFee float64
+	Fees        []TransferFee
+}
+
+//================================================
+//roundAmount: this func will round to 2 decimals
+//================================================
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+//==========================================================================
+//parseChargeBearer: this func will return charge bearer , SHA when empty
+//==========================================================================
+func parseChargeBearer(value string) (ChargeBearer, error) {
+	switch bearer := ChargeBearer(strings.ToUpper(strings.TrimSpace(value))); bearer {
+	case "":
+		return SHA, nil
+	case OUR, SHA, BEN:
+		return bearer, nil
+	}
+	return "", errors.New("Please Provide Charge Bearer (OUR , SHA , BEN)")
+}
+
+//=========================================================================
+//matches: this func will check schedule applies to corridor , account type ,
+//currency and amount , score is higher for more specific schedule
+//=========================================================================
+func (f FeeSchedule) matches(senderMSP string, receiverMSP string, accountType string, currency string, amount float64) (bool, int) {
+	scheduleCurrency := f.Currency
+	if scheduleCurrency == "" {
+		scheduleCurrency = currencyOfBranch(f.MSPID)
+	}
+	if scheduleCurrency != currency {
+		return false, 0
+	}
+	score := 0
+	if f.SenderMSP != "" {
+		if f.SenderMSP != senderMSP {
+			return false, 0
+		}
+		score++
+	}
+	if f.ReceiverMSP != "" {
+		if f.ReceiverMSP != receiverMSP {
+			return false, 0
+		}
+		score++
+	}
+	if f.AccountType != "" {
+		if !strings.EqualFold(f.AccountType, accountType) {
+			return false, 0
+		}
+		score++
+	}
+	if amount < f.MinAmount || (f.MaxAmount > 0 && amount > f.MaxAmount) {
+		return false, 0
+	}
+	return true, score
+}
+
+//==================================================================
+//getBankFeeSchedules: this func will return fee schedules of bank
+//==================================================================
+func getBankFeeSchedules(stub shim.ChaincodeStubInterface, mspID string) ([]FeeSchedule, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(feeScheduleKeyType, []string{mspID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	schedules := []FeeSchedule{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		schedule := FeeSchedule{}
+		json.Unmarshal(queryResponse.Value, &schedule)
+		schedules = append(schedules, schedule)
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].ScheduleID < schedules[j].ScheduleID })
+	return schedules, nil
+}
+
+//=====================================================================================
+//bankFee: this func will return fee bank charges on transfer from its most specific
+//matching schedule , zero when bank has no schedule in currency of transfer
+//=====================================================================================
+func bankFee(stub shim.ChaincodeStubInterface, mspID string, senderMSP string, receiverMSP string, accountType string, currency string, amount float64) (float64, string, error) {
+	schedules, err := getBankFeeSchedules(stub, mspID)
+	if err != nil {
+		return 0, "", err
+	}
+	best := -1
+	var selected FeeSchedule
+	for _, schedule := range schedules {
+		if ok, score := schedule.matches(senderMSP, receiverMSP, accountType, currency, amount); ok && score > best {
+			best = score
+			selected = schedule
+		}
+	}
+	if best < 0 {
+		return 0, "", nil
+	}
+	return roundAmount(selected.FeeFixed + amount*selected.FeePercent/100), selected.ScheduleID, nil
+}
+
+//=====================================================================================
+//computeTransferCharges: this func will compute sender and receiver bank fees of
+//transfer in its currency , fees of correspondent banks are added when transfer is routed
+//=====================================================================================
+func computeTransferCharges(stub shim.ChaincodeStubInterface, senderAccount Account, receiverAccount Account, amount float64, currency string, bearer ChargeBearer) (*TransferCharges, error) {
+	senderMSP := bankOfBranch(senderAccount.BranchCode)
+	receiverMSP := bankOfBranch(receiverAccount.BranchCode)
+	charges := &TransferCharges{Bearer: bearer, Fees: []TransferFee{}}
+	fee, scheduleID, err := bankFee(stub, senderMSP, senderMSP, receiverMSP, senderAccount.AccountType.AccountTypeName, currency, amount)
+	if err != nil {
+		return nil, err
+	}
+	if fee > 0 {
+		charges.SenderFee = fee
+		// sender bank fee is on top of amount unless beneficiary pays everything
+		payer := OUR
+		if bearer == BEN {
+			payer = BEN
+		}
+		charges.Fees = append(charges.Fees, TransferFee{MSPID: senderMSP, Type: "sender", ScheduleID: scheduleID, Amount: fee, PaidBy: payer})
+	}
+	if receiverMSP != senderMSP {
+		fee, scheduleID, err = bankFee(stub, receiverMSP, senderMSP, receiverMSP, receiverAccount.AccountType.AccountTypeName, currency, amount)
+		if err != nil {
+			return nil, err
+		}
+		if fee > 0 {
+			charges.ReceiverFee = fee
+			charges.Fees = append(charges.Fees, TransferFee{MSPID: receiverMSP, Type: "receiver", ScheduleID: scheduleID, Amount: fee, PaidBy: charges.payerOfOtherFees()})
+		}
+	}
+	return charges, nil
+}
+
+//====================================================================
+//payerOfOtherFees: this func will return who pays fees other than
+//sender bank fee , only OUR makes sender pay them
+//====================================================================
+func (c *TransferCharges) payerOfOtherFees() ChargeBearer {
+	if c.Bearer == OUR {
+		return OUR
+	}
+	return BEN
+}
+
+//=====================================================================================
+//applyTransferCharges: this func will store fees on transaction , work out amount
+//debited from sender and credited to receiver and move amounts of routed legs so that
+//first leg carries what sender bank pays out
+//=====================================================================================
+func applyTransferCharges(transaction *Transaction, charges *TransferCharges) error {
+	fees := append([]TransferFee{}, charges.Fees...)
+	for _, leg := range transaction.Legs {
+		if leg.Fee > 0 {
+			fees = append(fees, TransferFee{MSPID: leg.ToMSP, Type: "correspondent", Amount: leg.Fee, PaidBy: charges.payerOfOtherFees()})
+		}
+	}
+	amount := transaction.Amount
+	otherFees := roundAmount(transaction.RoutingFees + charges.ReceiverFee)
+	var debit, received float64
+	switch charges.Bearer {
+	case OUR:
+		debit = amount + charges.SenderFee + otherFees
+		received = amount
+	case SHA:
+		debit = amount + charges.SenderFee
+		received = amount - otherFees
+	default:
+		debit = amount
+		received = amount - charges.SenderFee - otherFees
+	}
+	if received <= 0 {
+		return errors.New("Fees of transfer are more than amount")
+	}
+	total := 0.0
+	for _, fee := range fees {
+		total += fee.Amount
+	}
+	transaction.ChargeBearer = charges.Bearer
+	transaction.Fees = fees
+	transaction.TotalFees = roundAmount(total)
+	transaction.TotalDebit = roundAmount(debit)
+	transaction.ReceiverAmount = roundAmount(received)
+	for i := range transaction.Legs {
+		if i == 0 {
+			transaction.Legs[i].Amount = roundAmount(debit - charges.SenderFee)
+			continue
+		}
+		transaction.Legs[i].Amount = roundAmount(transaction.Legs[i-1].Amount - transaction.Legs[i-1].Fee)
+	}
+	return nil
+}
+
+//=====================================================================================
+//setFeeSchedule: this func will add or replace fee schedule of caller bank. Schedule
+//must be on corridor of caller bank , as sender or as receiver
+//args[0]: schedule id
+//args[1]: schedule json {"sender_msp":"" , "receiver_msp":"" , "account_type":"" , "currency":"" ,
+//"min_amount":0 , "max_amount":0 , "fee_fixed":0 , "fee_percent":0}
+//=====================================================================================
+func (b *Bank) setFeeSchedule(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Schedule ID and Schedule"}`)
+	}
+	_, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok {
+		return shim.Error(`{"status": 403 , "message":"Client has no attribute UserType"}`)
+	}
+	if strings.TrimSpace(args[0]) == "" {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Schedule ID"}`)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	schedule := FeeSchedule{}
+	if err := json.Unmarshal([]byte(args[1]), &schedule); err != nil {
+		return shim.Error(`{"status": 500 , "message": "Please check Schedule ` + string(err.Error()) + `"}`)
+	}
+	if schedule.SenderMSP != mspID && schedule.ReceiverMSP != mspID {
+		return shim.Error(`{"status": 403 , "message": "Schedule must have ` + mspID + ` as sender or receiver bank"}`)
+	}
+	if schedule.FeeFixed < 0 || schedule.FeePercent < 0 || schedule.FeePercent >= 100 {
+		return shim.Error(`{"status": 500 , "message": "Fee of schedule is not valid"}`)
+	}
+	if schedule.MinAmount < 0 || (schedule.MaxAmount > 0 && schedule.MaxAmount < schedule.MinAmount) {
+		return shim.Error(`{"status": 500 , "message": "Amount band of schedule is not valid"}`)
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	id, _ := cid.GetID(stub)
+	schedule.ObjectType = feeScheduleKeyType
+	schedule.ScheduleID = args[0]
+	schedule.MSPID = mspID
+	schedule.Currency = strings.ToUpper(strings.TrimSpace(schedule.Currency))
+	if schedule.Currency == "" {
+		schedule.Currency = currencyOfBranch(mspID)
+	}
+	schedule.UpdatedBy = mspID + "_" + id
+	schedule.UpdatedAt = now.Format(time.RFC3339)
+	key, err := stub.CreateCompositeKey(feeScheduleKeyType, []string{mspID, args[0]})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	asBytes, _ := json.Marshal(schedule)
+	if err := stub.PutState(key, asBytes); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status": 200 , "message": "Data Updated"}`))
+}
+
+//=========================================================
+//removeFeeSchedule: this func will remove fee schedule of
+//caller bank
+//args[0]: schedule id
+//=========================================================
+func (b *Bank) removeFeeSchedule(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Schedule ID"}`)
+	}
+	_, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok {
+		return shim.Error(`{"status": 403 , "message":"Client has no attribute UserType"}`)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	key, err := stub.CreateCompositeKey(feeScheduleKeyType, []string{mspID, args[0]})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	asBytes, err := stub.GetState(key)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if asBytes == nil {
+		return shim.Error(`{"status": 404 , "message": "Fee Schedule Not Found"}`)
+	}
+	if err := stub.DelState(key); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status": 200 , "message": "Data Updated"}`))
+}
+
+//==================================================================
+//getFeeSchedules: this func will return fee schedules of bank
+//args[0]: (optional) MSPID of bank , caller bank when not given
+//==================================================================
+func (b *Bank) getFeeSchedules(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) > 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide optional MSPID"}`)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if len(args) == 1 && args[0] != "" {
+		mspID = args[0]
+	}
+	schedules, err := getBankFeeSchedules(stub, mspID)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	asBytes, _ := json.Marshal(schedules)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
+
+//=====================================================================================
+//quoteTransferFees: this func will return fees , sender debit and receiver amount of
+//transfer without writing it
+//args[0]: sender account number
+//args[1]: receiver account number
+//args[2]: amount
+//args[3]: (optional) charge bearer (OUR , SHA , BEN)
+//args[4]: (optional) currency , home currency of sender branch when not given
+//=====================================================================================
+func (b *Bank) quoteTransferFees(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) < 3 || len(args) > 5 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Sender Account , Receiver Account , Amount , optional Charge Bearer and Currency"}`)
+	}
+	amount, err := strconv.ParseFloat(args[2], 64)
+	if err != nil || amount <= 0 {
+		return shim.Error(`{"status": 500 , "message": "Can Not Convert Amount to Number"}`)
+	}
+	bearer := SHA
+	if len(args) >= 4 {
+		if bearer, err = parseChargeBearer(args[3]); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+	}
+	accounts := []Account{}
+	for _, accNo := range args[:2] {
+		found, kyc := getKycOfBankAccount(stub, accNo)
+		if !found {
+			return shim.Error(`{"status": 404 , "message": "Account ` + accNo + ` not found"}`)
+		}
+		account, err := resolveAccount(stub, kyc.Accounts[accNo])
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		accounts = append(accounts, account)
+	}
+	currency := currencyOfBranch(accounts[0].BranchCode)
+	if len(args) == 5 && args[4] != "" {
+		currency = strings.ToUpper(args[4])
+	}
+	charges, err := computeTransferCharges(stub, accounts[0], accounts[1], amount, currency, bearer)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	transaction := Transaction{SenderBranchName: accounts[0].BranchCode, ReceiverBranchName: accounts[1].BranchCode, Amount: amount}
+	if err := routeTransaction(stub, &transaction); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if err := applyTransferCharges(&transaction, charges); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	quote := map[string]interface{}{
+		"currency":        currency,
+		"charge_bearer":   transaction.ChargeBearer,
+		"fees":            transaction.Fees,
+		"total_fees":      transaction.TotalFees,
+		"total_debit":     transaction.TotalDebit,
+		"receiver_amount": transaction.ReceiverAmount,
+		"legs":            transaction.Legs,
+	}
+	asBytes, _ := json.Marshal(quote)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestTransferChargesByBearer(t *testing.T) {
+	l := newTransferLedger(t)
+	l.mustCall(l.bank.setFeeSchedule, "outgoing", `{"sender_msp":"HBLPK","fee_fixed":100}`)
+	l.as("HBLTR", "maker", "001")
+	l.mustCall(l.bank.setFeeSchedule, "incoming", `{"receiver_msp":"HBLTR","currency":"PKR","fee_percent":1}`)
+	l.as("HBLPK", "maker", "001")
+
+	tests := []struct {
+		bearer   ChargeBearer
+		debit    float64
+		received float64
+		paidBy   [2]ChargeBearer
+	}{
+		{OUR, 10200, 10000, [2]ChargeBearer{OUR, OUR}},
+		{SHA, 10100, 9900, [2]ChargeBearer{OUR, BEN}},
+		{BEN, 10000, 9800, [2]ChargeBearer{BEN, BEN}},
+	}
+	for _, test := range tests {
+		transaction := l.transaction(l.transfer("PK-1", "C1", "TR-1", "10000", "", string(test.bearer)))
+		if transaction.ChargeBearer != test.bearer {
+			t.Fatalf("bearer is %v , want %v", transaction.ChargeBearer, test.bearer)
+		}
+		if transaction.TotalDebit != test.debit || transaction.ReceiverAmount != test.received || transaction.TotalFees != 200 {
+			t.Fatalf("%v: debit %v received %v fees %v , want %v %v 200", test.bearer, transaction.TotalDebit, transaction.ReceiverAmount, transaction.TotalFees, test.debit, test.received)
+		}
+		if len(transaction.Fees) != 2 {
+			t.Fatalf("%v: fees are %+v , want sender and receiver fee", test.bearer, transaction.Fees)
+		}
+		for i, fee := range transaction.Fees {
+			if fee.PaidBy != test.paidBy[i] {
+				t.Fatalf("%v: %s fee paid by %v , want %v", test.bearer, fee.Type, fee.PaidBy, test.paidBy[i])
+			}
+		}
+	}
+}
+
+func TestTransferChargesOnlyInScheduleCurrency(t *testing.T) {
+	l := newTransferLedger(t)
+	l.as("HBLTR", "maker", "001")
+	// schedule without currency is in TRY , home currency of HBLTR
+	l.mustCall(l.bank.setFeeSchedule, "incoming", `{"receiver_msp":"HBLTR","fee_fixed":50}`)
+	l.as("HBLPK", "maker", "001")
+	transaction := l.transaction(l.transfer("PK-1", "C1", "TR-1", "1000", "PKR", "BEN"))
+	if transaction.TotalFees != 0 || transaction.ReceiverAmount != 1000 {
+		t.Fatalf("fees %v received %v , want no fee on PKR transfer", transaction.TotalFees, transaction.ReceiverAmount)
+	}
+}
+
+func TestTransferRefusedWhenFeesExceedAmount(t *testing.T) {
+	l := newTransferLedger(t)
+	l.mustCall(l.bank.setFeeSchedule, "outgoing", `{"sender_msp":"HBLPK","fee_fixed":100}`)
+	l.mustRefuse("Fees of transfer are more than amount", l.bank.transferInitiate, l.transferArgs("PK-1", "C1", "TR-1", "100", "", "BEN")...)
+	// sender pays its fee on top with SHA
+	l.transfer("PK-1", "C1", "TR-1", "100", "", "SHA")
+}
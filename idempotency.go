@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//=============================================================================
+// Idempotency keys : client may pass key with transferInitiate , unique per
+// sender account. Key is kept under (idempotency , sender account , key) and
+// a retry with same key returns transaction created first time
+//=============================================================================
+const idempotencyKeyType = "idempotency"
+
+//=================================================================
+//IdempotencyRecord : transaction created for idempotency key
+//=================================================================
+type IdempotencyRecord struct {
+	ObjectType    string `json:"doc_type"`
+	SenderAccount string `json:"sender_account"`
+	Key           string `json:"key"`
+	TransactionID string `json:"transaction_id"`
+	CreatedAt     string `json:"created_at"`
+}
+
+//======================================================================
+//getIdempotencyRecord: this func will read transaction id of key
+//======================================================================
+func getIdempotencyRecord(stub shim.ChaincodeStubInterface, senderAccount string, key string) (IdempotencyRecord, error) {
+	record := IdempotencyRecord{}
+	stateKey, err := stub.CreateCompositeKey(idempotencyKeyType, []string{senderAccount, key})
+	if err != nil {
+		return record, err
+	}
+	asBytes, err := stub.GetState(stateKey)
+	if err != nil {
+		return record, err
+	}
+	json.Unmarshal(asBytes, &record)
+	return record, nil
+}
+
+//=========================================================================
+//putIdempotencyKey: this func will record transaction created for key ,
+//called only once transaction is written
+//=========================================================================
+func putIdempotencyKey(stub shim.ChaincodeStubInterface, senderAccount string, key string, txID string) error {
+	now, err := getTxTime(stub)
+	if err != nil {
+		return err
+	}
+	stateKey, err := stub.CreateCompositeKey(idempotencyKeyType, []string{senderAccount, key})
+	if err != nil {
+		return err
+	}
+	asBytes, _ := json.Marshal(IdempotencyRecord{
+		ObjectType:    idempotencyKeyType,
+		SenderAccount: senderAccount,
+		Key:           key,
+		TransactionID: txID,
+		CreatedAt:     now.Format(time.RFC3339),
+	})
+	return stub.PutState(stateKey, asBytes)
+}
+
+//=====================================================================================
+//replayTransfer: this func will return transaction already created for idempotency
+//key of transfer and its id. Same key with other receiver or amount is refused , key
+//whose transaction is not on ledger is treated as unused
+//=====================================================================================
+func replayTransfer(stub shim.ChaincodeStubInterface, key string, args []string) (pb.Response, string, bool) {
+	record, err := getIdempotencyRecord(stub, args[0], key)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`), "", true
+	}
+	if record.TransactionID == "" {
+		return pb.Response{}, "", false
+	}
+	asBytes, err := getTransactionState(stub, record.TransactionID)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`), "", true
+	}
+	transaction := Transaction{}
+	json.Unmarshal(asBytes, &transaction)
+	if transaction.TransactionID == "" {
+		return pb.Response{}, "", false
+	}
+	amount, _ := strconv.ParseFloat(args[2], 64)
+	if transaction.ReceiverAccount != args[1] || transaction.Amount != amount {
+		return shim.Error(`{"status": 409 , "message": "Idempotency Key ` + key + ` is already used by transaction ` + record.TransactionID + `"}`), "", true
+	}
+	return shim.Success([]byte(`{"status": 200 , "message": "Transaction already initiated" , "data": ` + string(asBytes) + `}`)), record.TransactionID, true
+}
+
+//===================================================================
+//getTransactionByIdempotencyKey: this func will return transaction
+//created for idempotency key of sender account
+//args[0]: sender account number
+//args[1]: idempotency key
+//===================================================================
+func (b *Bank) getTransactionByIdempotencyKey(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Sender Account and Idempotency Key"}`)
+	}
+	record, err := getIdempotencyRecord(stub, args[0], strings.TrimSpace(args[1]))
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if record.TransactionID == "" {
+		return shim.Error(`{"status": 404 , "message": "Transaction not found"}`)
+	}
+	asBytes, err := getTransactionState(stub, record.TransactionID)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if asBytes == nil {
+		return shim.Error(`{"status": 404 , "message": "Transaction not found"}`)
+	}
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
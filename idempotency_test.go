@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIdempotentTransferReplay(t *testing.T) {
+	l := newTransferLedger(t)
+	txID := l.transfer("PK-1", "C1", "TR-1", "1000", "", "", "key-1")
+
+	response := l.mustCall(l.bank.transferInitiate, l.transferArgs("PK-1", "C1", "TR-1", "1000", "", "", "key-1")...)
+	if !strings.Contains(string(response.Payload), "Transaction already initiated") || !strings.Contains(string(response.Payload), txID) {
+		t.Fatalf("replay returned %s , want transaction %s", response.Payload, txID)
+	}
+	if replayed := l.transaction(l.lastTxID); replayed.TransactionID != "" {
+		t.Fatal("replay wrote a new transaction")
+	}
+	ids, err := getAccountTransactionIDs(l.stub, "PK-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != txID {
+		t.Fatalf("transactions of PK-1 are %v , want only %s", ids, txID)
+	}
+
+	response = l.mustCall(l.bank.getTransactionByIdempotencyKey, "PK-1", "key-1")
+	if !strings.Contains(string(response.Payload), txID) {
+		t.Fatalf("lookup by key returned %s , want transaction %s", response.Payload, txID)
+	}
+
+	// a new key is a new transfer
+	if other := l.transfer("PK-1", "C1", "TR-1", "1000", "", "", "key-2"); other == txID {
+		t.Fatal("new key replayed transaction of other key")
+	}
+}
+
+func TestIdempotencyKeyConflict(t *testing.T) {
+	l := newTransferLedger(t)
+	l.onboard("HBLTR", "001", "R2", "TR-2", "Ali Khan", "")
+	l.as("HBLPK", "maker", "001")
+	l.transfer("PK-1", "C1", "TR-1", "1000", "", "", "key-1")
+
+	l.mustRefuse(`"status": 409`, l.bank.transferInitiate, l.transferArgs("PK-1", "C1", "TR-1", "1500", "", "", "key-1")...)
+	l.mustRefuse(`"status": 409`, l.bank.transferInitiate, l.transferArgs("PK-1", "C1", "TR-2", "1000", "", "", "key-1")...)
+	ids, err := getAccountTransactionIDs(l.stub, "PK-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 {
+		t.Fatalf("transactions of PK-1 are %v , want only first transfer", ids)
+	}
+}
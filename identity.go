@@ -0,0 +1,360 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//=============================================================================
+// Identity fingerprint is a salted hash of national id and date of birth. The
+// raw values only travel in transient field identity and never reach ledger.
+// identityKeyType index maps fingerprint to every customer record of the person.
+// Salt and index are kept in private collection of the banks so fingerprint on
+// kyc can not be brute forced from channel state , see collections_config.json
+//=============================================================================
+const (
+	identityKeyType      = "identity"
+	identitySaltConfig   = "identitySalt"
+	identityTransientKey = "identity"
+	identityCollection   = "identityBankCollection"
+)
+
+//==============================================================
+//identityRequest : transient payload with identity of customer
+//==============================================================
+type identityRequest struct {
+	NationalID string `json:"NationalID"`
+	DOB        string `json:"DOB"`
+}
+
+//=====================================================================
+//IdentityRecord : customer records of banks that belong to one person
+//=====================================================================
+type IdentityRecord struct {
+	ObjectType  string   `json:"doc_type"`
+	Fingerprint string   `json:"fingerprint"`
+	Customers   []string `json:"customers"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+//=====================================================================================
+//identityFingerprint: this func will read identity from transient map and return its
+//salted hash , empty when caller did not pass identity
+//=====================================================================================
+func identityFingerprint(stub shim.ChaincodeStubInterface) (string, error) {
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return "", err
+	}
+	if transient[identityTransientKey] == nil {
+		return "", nil
+	}
+	request := identityRequest{}
+	if err := json.Unmarshal(transient[identityTransientKey], &request); err != nil {
+		return "", errors.New("Please check transient field identity")
+	}
+	nationalID := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(request.NationalID))
+	dob, err := time.Parse(businessDayFormat, strings.TrimSpace(request.DOB))
+	if nationalID == "" || err != nil {
+		return "", errors.New("Please Provide NationalID and DOB (YYYY-MM-DD) in transient field identity")
+	}
+	salt, err := getIdentitySalt(stub)
+	if err != nil {
+		return "", err
+	}
+	if salt == nil {
+		return "", errors.New("Identity salt is not configured")
+	}
+	sum := sha256.Sum256([]byte(string(salt) + "|" + nationalID + "|" + dob.Format(businessDayFormat)))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+//=====================================================================
+//getIdentitySalt: this func will read salt of identity fingerprint ,
+//nil until salt is configured
+//=====================================================================
+func getIdentitySalt(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	key, err := stub.CreateCompositeKey(configKeyType, []string{identitySaltConfig})
+	if err != nil {
+		return nil, err
+	}
+	return stub.GetPrivateData(identityCollection, key)
+}
+
+//=============================================================
+//getIdentityRecord: this func will read identity index entry
+//=============================================================
+func getIdentityRecord(stub shim.ChaincodeStubInterface, fingerprint string) (IdentityRecord, error) {
+	record := IdentityRecord{}
+	key, err := stub.CreateCompositeKey(identityKeyType, []string{fingerprint})
+	if err != nil {
+		return record, err
+	}
+	asBytes, err := stub.GetPrivateData(identityCollection, key)
+	if err != nil {
+		return record, err
+	}
+	json.Unmarshal(asBytes, &record)
+	return record, nil
+}
+
+//=============================================================
+//putIdentityRecord: this func will write identity index entry
+//=============================================================
+func putIdentityRecord(stub shim.ChaincodeStubInterface, record IdentityRecord) error {
+	key, err := stub.CreateCompositeKey(identityKeyType, []string{record.Fingerprint})
+	if err != nil {
+		return err
+	}
+	record.ObjectType = identityKeyType
+	asBytes, _ := json.Marshal(record)
+	return stub.PutPrivateData(identityCollection, key, asBytes)
+}
+
+//=====================================================================
+//customerRefOf: this func will return reference MSPID:custID of kyc
+//=====================================================================
+func customerRefOf(kyc Kyc) string {
+	return bankOfBranch(kyc.MSPID) + customerRefSeparator + kyc.CustID
+}
+
+//=========================================================================================
+//registerIdentity: this func will fingerprint identity passed with onboarding and add kyc
+//to identity index. Same person already onboarded at this bank is refused , records of
+//other banks are linked and a black listed link makes new record black listed as well.
+//Once salt is configured identity must be passed , before that kyc is added without it
+//=========================================================================================
+func registerIdentity(stub shim.ChaincodeStubInterface, kyc *Kyc) error {
+	fingerprint, err := identityFingerprint(stub)
+	if err != nil {
+		return err
+	}
+	if fingerprint == "" {
+		salt, err := getIdentitySalt(stub)
+		if err != nil {
+			return err
+		}
+		if salt != nil {
+			return errors.New("Identity must be passed in transient field identity")
+		}
+		return nil
+	}
+	record, err := getIdentityRecord(stub, fingerprint)
+	if err != nil {
+		return err
+	}
+	ref := customerRefOf(*kyc)
+	for _, linked := range record.Customers {
+		mspID, custID, _ := customerRef(stub, linked)
+		if linked == ref {
+			continue
+		}
+		if mspID == bankOfBranch(kyc.MSPID) {
+			return errors.New("Customer is already onboarded as " + custID)
+		}
+		linkedAsBytes, err := getKycStateOf(stub, mspID, custID)
+		if err != nil {
+			return err
+		}
+		linkedKyc := Kyc{}
+		json.Unmarshal(linkedAsBytes, &linkedKyc)
+		if linkedKyc.IsBlackList {
+			kyc.IsBlackList = true
+		}
+	}
+	if record.Fingerprint == "" {
+		now, err := getTxTime(stub)
+		if err != nil {
+			return err
+		}
+		record = IdentityRecord{Fingerprint: fingerprint, CreatedAt: now.Format(time.RFC3339)}
+	}
+	record.Customers = appendUnique(record.Customers, ref)
+	kyc.IdentityFingerprint = fingerprint
+	return putIdentityRecord(stub, record)
+}
+
+//=========================================================================================
+//propagateBlackList: this func will set black list flag on every record linked to kyc by
+//identity fingerprint and recompute their risk score. Only black listing is passed on ,
+//flag of another bank is cleared only by that bank
+//=========================================================================================
+func propagateBlackList(stub shim.ChaincodeStubInterface, kyc Kyc) error {
+	if kyc.IdentityFingerprint == "" || !kyc.IsBlackList {
+		return nil
+	}
+	linked, err := getLinkedKycs(stub, kyc)
+	if err != nil {
+		return err
+	}
+	for _, linkedKyc := range linked {
+		if linkedKyc.IsBlackList {
+			continue
+		}
+		linkedKyc.IsBlackList = true
+		if err := computeCustomerRiskScore(stub, &linkedKyc); err != nil {
+			return err
+		}
+		if err := storeKyc(stub, linkedKyc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//=====================================================================
+//getLinkedKycs: this func will return records of other banks linked
+//to kyc by identity fingerprint
+//=====================================================================
+func getLinkedKycs(stub shim.ChaincodeStubInterface, kyc Kyc) ([]Kyc, error) {
+	linked := []Kyc{}
+	if kyc.IdentityFingerprint == "" {
+		return linked, nil
+	}
+	record, err := getIdentityRecord(stub, kyc.IdentityFingerprint)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range record.Customers {
+		if ref == customerRefOf(kyc) {
+			continue
+		}
+		mspID, custID, _ := customerRef(stub, ref)
+		linkedAsBytes, err := getKycStateOf(stub, mspID, custID)
+		if err != nil {
+			return nil, err
+		}
+		linkedKyc := Kyc{}
+		json.Unmarshal(linkedAsBytes, &linkedKyc)
+		if linkedKyc.CustID != "" {
+			linked = append(linked, linkedKyc)
+		}
+	}
+	return linked, nil
+}
+
+//=====================================================================
+//setIdentitySalt: this func will configure network wide salt of identity
+//fingerprint , salt is passed in transient field salt and can be set once
+//by admin user
+//=====================================================================
+func (b *Bank) setIdentitySalt(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	userType, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok || strings.ToLower(userType) != "admin" {
+		return shim.Error(`{"status": 403 , "message": "Only admin user can set identity salt"}`)
+	}
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if len(transient["salt"]) == 0 {
+		return shim.Error(`{"status": 500 , "message": "Salt must be passed in transient field salt"}`)
+	}
+	key, err := stub.CreateCompositeKey(configKeyType, []string{identitySaltConfig})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	current, err := stub.GetPrivateData(identityCollection, key)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if current != nil {
+		return shim.Error(`{"status": 403 , "message": "Identity salt is already configured"}`)
+	}
+	err = stub.PutPrivateData(identityCollection, key, transient["salt"])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
+}
+
+//======================================================================================
+//setCustomerIdentity: this func will fingerprint identity of customer onboarded before
+//identity index existed. Identity is passed in transient field identity
+//args[0]: custID
+//======================================================================================
+func (b *Bank) setCustomerIdentity(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 1 Argument"}`)
+	}
+	_, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok {
+		return shim.Error(`{"status": 403 , "message":"Client has no attribute UserType"}`)
+	}
+	kycAsBytes, _ := getKycState(stub, args[0])
+	kyc := Kyc{}
+	json.Unmarshal(kycAsBytes, &kyc)
+	if kyc.CustID == "" {
+		return shim.Error(`{"status": 404 , "message": "Customer id Not Found"}`)
+	}
+	if kyc.IdentityFingerprint != "" {
+		return shim.Error(`{"status": 403 , "message": "Customer identity is already registered"}`)
+	}
+	wasBlackListed := kyc.IsBlackList
+	if err := registerIdentity(stub, &kyc); err != nil {
+		return shim.Error(`{"status": 403 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if kyc.IdentityFingerprint == "" {
+		return shim.Error(`{"status": 500 , "message": "Identity must be passed in transient field identity"}`)
+	}
+	if kyc.IsBlackList != wasBlackListed {
+		if err := computeCustomerRiskScore(stub, &kyc); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+	}
+	if err := putKyc(stub, kyc); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	// a black listed customer now linked to other records passes the flag on
+	if kyc.IsBlackList {
+		if err := propagateBlackList(stub, kyc); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+	}
+	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
+}
+
+//==================================================================
+//getLinkedCustomers: this func will return customer records of other
+//banks that belong to same person
+//args[0]: custID
+//==================================================================
+func (b *Bank) getLinkedCustomers(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 1 Argument"}`)
+	}
+	kycAsBytes, _ := getKycState(stub, args[0])
+	kyc := Kyc{}
+	json.Unmarshal(kycAsBytes, &kyc)
+	if kyc.CustID == "" {
+		return shim.Error(`{"status": 404 , "message": "Customer id Not Found"}`)
+	}
+	linked := []string{}
+	if kyc.IdentityFingerprint != "" {
+		record, err := getIdentityRecord(stub, kyc.IdentityFingerprint)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		for _, ref := range record.Customers {
+			if ref != customerRefOf(kyc) {
+				linked = append(linked, ref)
+			}
+		}
+	}
+	asBytes, _ := json.Marshal(linked)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
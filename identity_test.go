@@ -0,0 +1,98 @@
+package main
+
+import "testing"
+
+const janeIdentity = `{"NationalID":"35202-1234567-1","DOB":"1990-04-12"}`
+
+// newIdentityLedger : ledger with identity salt and branches of three banks
+func newIdentityLedger(t *testing.T) *testLedger {
+	l := newTestLedger(t)
+	l.withTransient("salt", "test-salt")
+	l.mustCall(l.bank.setIdentitySalt)
+	for _, mspID := range []string{"HBLPK", "HBLTR", "MCBPK"} {
+		l.addBranch(mspID, "001")
+	}
+	return l
+}
+
+func TestSetIdentitySaltAdminOnlyAndOnce(t *testing.T) {
+	l := newTestLedger(t)
+	l.as("HBLPK", "maker", "001")
+	l.withTransient("salt", "test-salt")
+	l.mustRefuse(`"status": 403`, l.bank.setIdentitySalt)
+	l.as("HBLPK", "admin", "001")
+	l.withTransient("salt", "test-salt")
+	l.mustCall(l.bank.setIdentitySalt)
+	l.withTransient("salt", "other-salt")
+	l.mustRefuse("already configured", l.bank.setIdentitySalt)
+}
+
+func TestAddKycRequiresIdentityOnceSaltIsSet(t *testing.T) {
+	l := newIdentityLedger(t)
+	l.as("HBLPK", "maker", "001")
+	l.mustRefuse("Identity must be passed", l.bank.addKyc, "C1", personalHash("C1"), "false", "Individual", "PK-1", "Jane Smith", "001", "", "")
+	l.onboard("HBLPK", "001", "C1", "PK-1", "Jane Smith", janeIdentity)
+	l.as("HBLPK", "maker", "001")
+	l.withTransient(identityTransientKey, janeIdentity)
+	l.mustRefuse("already onboarded as C1", l.bank.addKyc, "C2", personalHash("C2"), "false", "Individual", "PK-2", "Jane Smith", "001", "", "")
+}
+
+func TestBlackListPropagatesToLinkedCustomers(t *testing.T) {
+	l := newIdentityLedger(t)
+	l.onboard("HBLPK", "001", "C1", "PK-1", "Jane Smith", janeIdentity)
+	l.onboard("HBLTR", "001", "T1", "TR-1", "Jane Smith", janeIdentity)
+
+	l.as("HBLPK", "compliance", "001")
+	l.mustCall(l.bank.addToBlackList, "C1")
+	if !l.kyc("HBLPK", "C1").IsBlackList {
+		t.Fatal("C1 is not black listed")
+	}
+	linked := l.kyc("HBLTR", "T1")
+	if !linked.IsBlackList {
+		t.Fatal("black list of C1 did not reach linked T1")
+	}
+	if linked.RiskLevel != HIGH {
+		t.Fatalf("risk level of T1 is %v , want %v", linked.RiskLevel, HIGH)
+	}
+
+	// same person onboarded later at another bank starts black listed
+	l.onboard("MCBPK", "001", "M1", "MC-1", "Jane Smith", janeIdentity)
+	if !l.kyc("MCBPK", "M1").IsBlackList {
+		t.Fatal("new record of black listed person is not black listed")
+	}
+}
+
+func TestRemoveFromBlackListLeavesOtherBanks(t *testing.T) {
+	l := newIdentityLedger(t)
+	l.onboard("HBLPK", "001", "C1", "PK-1", "Jane Smith", janeIdentity)
+	l.onboard("HBLTR", "001", "T1", "TR-1", "Jane Smith", janeIdentity)
+	l.as("HBLPK", "compliance", "001")
+	l.mustCall(l.bank.addToBlackList, "C1")
+
+	l.mustCall(l.bank.removeFromBlackList, "C1")
+	cleared := l.kyc("HBLPK", "C1")
+	if cleared.IsBlackList {
+		t.Fatal("C1 is still black listed")
+	}
+	previously := false
+	for _, factor := range cleared.RiskFactors {
+		if factor == "previously black listed" {
+			previously = true
+		}
+	}
+	if !previously {
+		t.Fatalf("risk factors of C1 are %v , want previously black listed", cleared.RiskFactors)
+	}
+	if !l.kyc("HBLTR", "T1").IsBlackList {
+		t.Fatal("removal at HBLPK cleared flag of HBLTR")
+	}
+
+	l.as("HBLTR", "compliance", "001")
+	l.mustCall(l.bank.removeFromBlackList, "T1")
+	if l.kyc("HBLTR", "T1").IsBlackList {
+		t.Fatal("T1 is still black listed")
+	}
+	if l.kyc("HBLPK", "C1").IsBlackList {
+		t.Fatal("removal at HBLTR black listed C1 again")
+	}
+}
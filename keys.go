@@ -0,0 +1,601 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//================================================================================
+// Key scheme : every entity is stored under a composite key of its own type so
+// ids of different entities and of different banks can not collide. Customers are
+// keyed by (home bank MSPID , custID) , branches by (MSPID , branch code)
+//================================================================================
+const (
+	kycKeyType          = "kyc"
+	transactionKeyType  = "transaction"
+	branchKeyType       = "branch"
+	accountIndexKeyType = "accountIndex"
+	// accountTxKeyType : (account number , transaction id) of every transfer of account
+	accountTxKeyType = "accountTransaction"
+	// customerRefSeparator : custID of another bank is given as MSPID:custID
+	customerRefSeparator = ":"
+	legacyAccountIndex   = "accNo"
+	defaultMigrateBatch  = 500
+)
+
+//==========================================================================
+//AccountIndexEntry : owner of account number , used to find customer of an
+//account from any bank
+//==========================================================================
+type AccountIndexEntry struct {
+	ObjectType    string `json:"doc_type"`
+	AccountNumber string `json:"account_number"`
+	OwnerName     string `json:"owner_name"`
+	CustID        string `json:"cust_id"`
+	MSPID         string `json:"msp_id"`
+}
+
+//=====================================================================================
+//customerRef: this func will split customer reference into home bank and custID. Plain
+//custID belongs to caller bank , customer of another bank is given as MSPID:custID
+//=====================================================================================
+func customerRef(stub shim.ChaincodeStubInterface, ref string) (string, string, error) {
+	if parts := strings.SplitN(ref, customerRefSeparator, 2); len(parts) == 2 {
+		return parts[0], parts[1], nil
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return "", "", err
+	}
+	return mspID, ref, nil
+}
+
+//=============================================================
+//kycKey: this func will return key of customer of home bank
+//=============================================================
+func kycKey(stub shim.ChaincodeStubInterface, mspID string, custID string) (string, error) {
+	return stub.CreateCompositeKey(kycKeyType, []string{mspID, custID})
+}
+
+//====================================================================
+//kycKeyOf: this func will return key of customer record
+//====================================================================
+func kycKeyOf(stub shim.ChaincodeStubInterface, kyc Kyc) (string, error) {
+	return kycKey(stub, bankOfBranch(kyc.MSPID), kyc.CustID)
+}
+
+//================================================================================
+//getKycState: this func will read customer by reference , empty when not found
+//================================================================================
+func getKycState(stub shim.ChaincodeStubInterface, ref string) ([]byte, error) {
+	mspID, custID, err := customerRef(stub, ref)
+	if err != nil {
+		return nil, err
+	}
+	return getKycStateOf(stub, mspID, custID)
+}
+
+//================================================================================
+//getKycStateOf: this func will read customer of given bank
+//================================================================================
+func getKycStateOf(stub shim.ChaincodeStubInterface, mspID string, custID string) ([]byte, error) {
+	key, err := kycKey(stub, mspID, custID)
+	if err != nil {
+		return nil, err
+	}
+	return stub.GetState(key)
+}
+
+//=====================================================================
+//putKyc: this func will write customer under its home bank , stamped
+//with ledger time and client that updated it. Reference MSPID:custID
+//reads customer of any bank but only home bank can change it
+//=====================================================================
+func putKyc(stub shim.ChaincodeStubInterface, kyc Kyc) error {
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return err
+	}
+	if bankOfBranch(kyc.MSPID) != mspID {
+		return errors.New("Customer " + kyc.CustID + " belongs to " + bankOfBranch(kyc.MSPID))
+	}
+	return storeKyc(stub, kyc)
+}
+
+//=====================================================================
+//storeKyc: this func will write customer of any bank , only for black
+//list propagation and key migration which update records of every bank
+//=====================================================================
+func storeKyc(stub shim.ChaincodeStubInterface, kyc Kyc) error {
+	key, err := kycKeyOf(stub, kyc)
+	if err != nil {
+		return err
+	}
+	if kyc.UpdatedAt, kyc.UpdatedBy, err = auditStamp(stub); err != nil {
+		return err
+	}
+	kyc.ObjectType = "kyc"
+	asBytes, _ := json.Marshal(kyc)
+	return stub.PutState(key, asBytes)
+}
+
+//=============================================================
+//transactionKey: this func will return key of transaction
+//=============================================================
+func transactionKey(stub shim.ChaincodeStubInterface, txID string) (string, error) {
+	return stub.CreateCompositeKey(transactionKeyType, []string{txID})
+}
+
+//=============================================================
+//getTransactionState: this func will read transaction by id
+//=============================================================
+func getTransactionState(stub shim.ChaincodeStubInterface, txID string) ([]byte, error) {
+	key, err := transactionKey(stub, txID)
+	if err != nil {
+		return nil, err
+	}
+	return stub.GetState(key)
+}
+
+//=====================================================================
+//putTransaction: this func will write transaction , ledger time is kept
+//whenever status of transaction changes and on every update
+//=====================================================================
+func putTransaction(stub shim.ChaincodeStubInterface, transaction Transaction) error {
+	key, err := transactionKey(stub, transaction.TransactionID)
+	if err != nil {
+		return err
+	}
+	if transaction.UpdatedAt, transaction.UpdatedBy, err = auditStamp(stub); err != nil {
+		return err
+	}
+	if transaction.StageStatus != transaction.TransactionStatus {
+		now, err := getTxTime(stub)
+		if err != nil {
+			return err
+		}
+		transaction.StageStatus = transaction.TransactionStatus
+		transaction.StatusChangedAt = now.Format(time.RFC3339)
+	}
+	if err := putAccountTransactions(stub, transaction); err != nil {
+		return err
+	}
+	asBytes, _ := json.Marshal(transaction)
+	return stub.PutState(key, asBytes)
+}
+
+//=====================================================================
+//putAccountTransactions: this func will index transaction under sender
+//and receiver account so history of an account is read without scanning
+//every transaction
+//=====================================================================
+func putAccountTransactions(stub shim.ChaincodeStubInterface, transaction Transaction) error {
+	for _, accNo := range []string{transaction.SenderAccount, transaction.ReceiverAccount} {
+		if accNo == "" {
+			continue
+		}
+		key, err := stub.CreateCompositeKey(accountTxKeyType, []string{accNo, transaction.TransactionID})
+		if err != nil {
+			return err
+		}
+		if err := stub.PutState(key, []byte{0x00}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//=====================================================================
+//getAccountTransactionIDs: this func will return ids of transactions
+//of account from account index
+//=====================================================================
+func getAccountTransactionIDs(stub shim.ChaincodeStubInterface, accNo string) ([]string, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(accountTxKeyType, []string{accNo})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+	ids := []string{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, parts, err := stub.SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, parts[1])
+	}
+	return ids, nil
+}
+
+//=====================================================================
+//branchKey: this func will return key of branch stored as MSPID_code
+//=====================================================================
+func branchKey(stub shim.ChaincodeStubInterface, branchCode string) (string, error) {
+	parts := strings.SplitN(branchCode, "_", 2)
+	if len(parts) == 1 {
+		parts = append(parts, "")
+	}
+	return stub.CreateCompositeKey(branchKeyType, parts)
+}
+
+//=============================================================
+//getBranchState: this func will read branch by MSPID_code
+//=============================================================
+func getBranchState(stub shim.ChaincodeStubInterface, branchCode string) ([]byte, error) {
+	key, err := branchKey(stub, branchCode)
+	if err != nil {
+		return nil, err
+	}
+	return stub.GetState(key)
+}
+
+//=========================================================================
+//putBranch: this func will write branch stamped with ledger time and client
+//=========================================================================
+func putBranch(stub shim.ChaincodeStubInterface, branch Branch) error {
+	key, err := branchKey(stub, branch.BranchCode)
+	if err != nil {
+		return err
+	}
+	if branch.UpdatedAt, branch.UpdatedBy, err = auditStamp(stub); err != nil {
+		return err
+	}
+	asBytes, _ := json.Marshal(branch)
+	return stub.PutState(key, asBytes)
+}
+
+//=================================================================
+//getAccountIndex: this func will return owner of account number
+//=================================================================
+func getAccountIndex(stub shim.ChaincodeStubInterface, accNo string) (AccountIndexEntry, error) {
+	entry := AccountIndexEntry{}
+	key, err := stub.CreateCompositeKey(accountIndexKeyType, []string{accNo})
+	if err != nil {
+		return entry, err
+	}
+	asBytes, err := stub.GetState(key)
+	if err != nil {
+		return entry, err
+	}
+	json.Unmarshal(asBytes, &entry)
+	return entry, nil
+}
+
+//=================================================================
+//putAccountIndex: this func will record owner of account number
+//=================================================================
+func putAccountIndex(stub shim.ChaincodeStubInterface, entry AccountIndexEntry) error {
+	key, err := stub.CreateCompositeKey(accountIndexKeyType, []string{entry.AccountNumber})
+	if err != nil {
+		return err
+	}
+	entry.ObjectType = accountIndexKeyType
+	asBytes, _ := json.Marshal(entry)
+	return stub.PutState(key, asBytes)
+}
+
+//=====================================================================
+//ref: this func will return reference of account owner as MSPID:custID
+//=====================================================================
+func (e AccountIndexEntry) ref() string {
+	return e.MSPID + customerRefSeparator + e.CustID
+}
+
+//======================================================================
+//legacyRecord : fields used to recognise records written on plain keys
+//======================================================================
+type legacyRecord struct {
+	ObjectType      string `json:"doc_type"`
+	TransactionID   string `json:"TransactionID"`
+	BranchCode      string `json:"branch_code"`
+	AccountTypeName string `json:"AccountTypeName"`
+}
+
+//==========================================================================
+//MigrationResult : records moved to composite keys by one migration call
+//==========================================================================
+type MigrationResult struct {
+	Customers    int  `json:"customers"`
+	Transactions int  `json:"transactions"`
+	Branches     int  `json:"branches"`
+	AccountTypes int  `json:"account_types"`
+	Accounts     int  `json:"accounts"`
+	Skipped      int  `json:"skipped"`
+	Remaining    bool `json:"remaining"`
+}
+
+//=======================================================================================
+//migrateKeys: this func will move records written on plain keys (customers , transactions ,
+//branches , account types and accNo list) to composite keys of key scheme. Records are
+//moved in batches , call again while remaining is true. Records of every bank are moved
+//so only admin user can run it
+//args[0]: (optional) batch size , default 500
+//=======================================================================================
+func (b *Bank) migrateKeys(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) > 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide optional Batch Size"}`)
+	}
+	userType, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok || strings.ToLower(userType) != "admin" {
+		return shim.Error(`{"status": 403 , "message": "Only admin user can migrate keys"}`)
+	}
+	batch := defaultMigrateBatch
+	if len(args) == 1 {
+		batch, err = strconv.Atoi(args[0])
+		if err != nil || batch <= 0 {
+			return shim.Error(`{"status": 500 , "message": "Can Not Convert Batch Size to Number"}`)
+		}
+	}
+
+	// plain keys only , composite keys are never returned by range query
+	resultsIterator, err := stub.GetStateByRange("", "")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	defer resultsIterator.Close()
+
+	// accNo list is moved first , while customers it points to are still on plain keys
+	result := MigrationResult{}
+	indexAsBytes, err := stub.GetState(legacyAccountIndex)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if indexAsBytes != nil {
+		if err := migrateAccountIndex(stub, indexAsBytes, &result); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+	}
+	moved := 0
+	for resultsIterator.HasNext() {
+		if moved >= batch {
+			result.Remaining = true
+			break
+		}
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		if queryResponse.Key == legacyAccountIndex {
+			continue
+		}
+		migrated, err := migrateRecord(stub, queryResponse.Key, queryResponse.Value, &result)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		if migrated {
+			moved++
+		}
+	}
+	asBytes, _ := json.Marshal(result)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
+
+//=======================================================================================
+//indexAccountTransactions: this func will add transactions written before account index
+//existed to index of their accounts. Transactions are indexed in batches , call again
+//while remaining is true , admin user only
+//args[0]: (optional) batch size , default 500
+//=======================================================================================
+func (b *Bank) indexAccountTransactions(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) > 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide optional Batch Size"}`)
+	}
+	userType, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok || strings.ToLower(userType) != "admin" {
+		return shim.Error(`{"status": 403 , "message": "Only admin user can index account transactions"}`)
+	}
+	batch := defaultMigrateBatch
+	if len(args) == 1 {
+		batch, err = strconv.Atoi(args[0])
+		if err != nil || batch <= 0 {
+			return shim.Error(`{"status": 500 , "message": "Can Not Convert Batch Size to Number"}`)
+		}
+	}
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(transactionKeyType, []string{})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	defer resultsIterator.Close()
+	result := MigrationResult{}
+	for resultsIterator.HasNext() {
+		if result.Transactions >= batch {
+			result.Remaining = true
+			break
+		}
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		transaction := Transaction{}
+		json.Unmarshal(queryResponse.Value, &transaction)
+		if transaction.TransactionID == "" {
+			continue
+		}
+		key, err := stub.CreateCompositeKey(accountTxKeyType, []string{transaction.SenderAccount, transaction.TransactionID})
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		indexed, err := stub.GetState(key)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		if indexed != nil {
+			continue
+		}
+		if err := putAccountTransactions(stub, transaction); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		result.Transactions++
+	}
+	asBytes, _ := json.Marshal(result)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
+
+//=====================================================================================
+//migrateAccountIndex: this func will write accNo list as one index entry per account
+//=====================================================================================
+func migrateAccountIndex(stub shim.ChaincodeStubInterface, value []byte, result *MigrationResult) error {
+	accounts := make(map[string]string)
+	json.Unmarshal(value, &accounts)
+	for accNo, owner := range accounts {
+		parts := strings.SplitN(owner, ",", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		kycAsBytes, err := stub.GetState(parts[1])
+		if err != nil {
+			return err
+		}
+		kyc := Kyc{}
+		json.Unmarshal(kycAsBytes, &kyc)
+		entry := AccountIndexEntry{
+			AccountNumber: accNo,
+			OwnerName:     parts[0],
+			CustID:        parts[1],
+			MSPID:         bankOfBranch(kyc.MSPID),
+		}
+		if err := putAccountIndex(stub, entry); err != nil {
+			return err
+		}
+		result.Accounts++
+	}
+	return stub.DelState(legacyAccountIndex)
+}
+
+//=====================================================================================
+//historyStageStart: this func will return ledger time record on key entered the stage
+//it is still in , inStage tells whether a past value was in that stage. Zero time when
+//key has no history
+//=====================================================================================
+func historyStageStart(stub shim.ChaincodeStubInterface, key string, inStage func([]byte) bool) (time.Time, error) {
+	resultsIterator, err := stub.GetHistoryForKey(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resultsIterator.Close()
+	type change struct {
+		at      time.Time
+		inStage bool
+	}
+	changes := []change{}
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			return time.Time{}, err
+		}
+		if modification.IsDelete || modification.Timestamp == nil {
+			continue
+		}
+		changes = append(changes, change{
+			at:      time.Unix(modification.Timestamp.Seconds, int64(modification.Timestamp.Nanos)).UTC(),
+			inStage: inStage(modification.Value),
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].at.Before(changes[j].at) })
+	start := time.Time{}
+	for i := len(changes) - 1; i >= 0 && changes[i].inStage; i-- {
+		start = changes[i].at
+	}
+	return start, nil
+}
+
+//=================================================================================
+//migrateRecord: this func will write one plain key record to its composite key and
+//delete plain key. Unknown records are left in place and reported as not migrated
+//=================================================================================
+func migrateRecord(stub shim.ChaincodeStubInterface, key string, value []byte, result *MigrationResult) (bool, error) {
+	record := legacyRecord{}
+	json.Unmarshal(value, &record)
+	switch {
+	case record.ObjectType == "kyc":
+		kyc := Kyc{}
+		json.Unmarshal(value, &kyc)
+		if kyc.PersonalKycStatus == APPROVED && kyc.NextReviewDate == "" {
+			// review of kyc approved before review scheduling counts from its approval
+			approvedAt, err := time.Parse(time.RFC3339, kyc.ApprovedAt)
+			if err != nil {
+				approvedAt, err = historyStageStart(stub, key, func(value []byte) bool {
+					old := Kyc{}
+					json.Unmarshal(value, &old)
+					return old.PersonalKycStatus == APPROVED
+				})
+				if err != nil {
+					return false, err
+				}
+			}
+			if !approvedAt.IsZero() {
+				setKycReview(&kyc, approvedAt, kyc.RiskLevel)
+			}
+		}
+		if err := storeKyc(stub, kyc); err != nil {
+			return false, err
+		}
+		result.Customers++
+	case record.ObjectType == "transaction" && record.TransactionID != "":
+		transaction := Transaction{}
+		json.Unmarshal(value, &transaction)
+		if transaction.StageStatus == "" {
+			// stage age of transfer moved here starts when it entered its status , not at migration
+			entered, err := historyStageStart(stub, key, func(value []byte) bool {
+				old := Transaction{}
+				json.Unmarshal(value, &old)
+				return old.TransactionStatus == transaction.TransactionStatus
+			})
+			if err != nil {
+				return false, err
+			}
+			transaction.StageStatus = transaction.TransactionStatus
+			transaction.StatusChangedAt = transaction.CreatedAt
+			if !entered.IsZero() {
+				transaction.StatusChangedAt = entered.Format(time.RFC3339)
+			}
+		}
+		if err := putTransaction(stub, transaction); err != nil {
+			return false, err
+		}
+		result.Transactions++
+	case record.BranchCode != "":
+		branch := Branch{}
+		json.Unmarshal(value, &branch)
+		if err := putBranch(stub, branch); err != nil {
+			return false, err
+		}
+		result.Branches++
+	case record.AccountTypeName != "":
+		accountType := AccountType{}
+		json.Unmarshal(value, &accountType)
+		current, err := getAccountType(stub, accountType.AccountTypeName)
+		if err != nil {
+			return false, err
+		}
+		if current.AccountTypeName == "" {
+			accountType.Category = accountType.category()
+			accountType.Version = 1
+			if err := putAccountType(stub, accountType, current); err != nil {
+				return false, err
+			}
+		}
+		result.AccountTypes++
+	default:
+		result.Skipped++
+		return false, nil
+	}
+	return true, stub.DelState(key)
+}
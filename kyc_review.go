@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//====================================================================
+// kycReviewYears : how often a customer KYC has to be refreshed for
+// each risk tier , high risk customers are reviewed every year
+//====================================================================
+var kycReviewYears = map[Riskrating]int{
+	LOW:    3,
+	MEDIUM: 2,
+	HIGH:   1,
+}
+
+//=====================================================================
+//parseRiskTier: this func will convert client supplied tier to Riskrating
+//=====================================================================
+func parseRiskTier(tier string) (Riskrating, bool) {
+	switch strings.ToLower(tier) {
+	case "low":
+		return LOW, true
+	case "medium":
+		return MEDIUM, true
+	case "high":
+		return HIGH, true
+	}
+	return UNKNOWN, false
+}
+
+//================================================================================
+//scheduleKycReview: this func will stamp approval date , risk tier and next review
+//date on kyc. When no tier is provided the computed customer risk level is used and
+//a customer without any risk level is treated as high risk
+//================================================================================
+func scheduleKycReview(stub shim.ChaincodeStubInterface, kyc *Kyc, tier string) error {
+	riskTier := kyc.RiskLevel
+	if tier != "" {
+		parsed, ok := parseRiskTier(tier)
+		if !ok {
+			return fmt.Errorf("please provide correct Risk Tier (low , medium , high)")
+		}
+		riskTier = parsed
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return err
+	}
+	setKycReview(kyc, now, riskTier)
+	return nil
+}
+
+//=====================================================================
+//setKycReview: this func will stamp approval date , risk tier and next
+//review date counted from approval , unknown tier is treated as high
+//=====================================================================
+func setKycReview(kyc *Kyc, approvedAt time.Time, riskTier Riskrating) {
+	if _, found := kycReviewYears[riskTier]; !found {
+		riskTier = HIGH
+	}
+	kyc.ApprovedAt = approvedAt.Format(time.RFC3339)
+	kyc.RiskTier = riskTier
+	kyc.NextReviewDate = approvedAt.AddDate(kycReviewYears[riskTier], 0, 0).Format(time.RFC3339)
+}
+
+//=================================================================================
+//isKycReviewLapsed: this func will check whether approved kyc has passed its review
+//date. Approved kyc without review date (not yet given one by migrateKeys) is due
+//=================================================================================
+func isKycReviewLapsed(stub shim.ChaincodeStubInterface, kyc Kyc) (bool, error) {
+	if kyc.PersonalKycStatus != APPROVED {
+		return false, nil
+	}
+	if kyc.NextReviewDate == "" {
+		return true, nil
+	}
+	reviewDate, err := time.Parse(time.RFC3339, kyc.NextReviewDate)
+	if err != nil {
+		return false, err
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return false, err
+	}
+	return !now.Before(reviewDate), nil
+}
+
+//===========================================================================
+//getKycDueForReview: this function will return approved customers of caller
+//bank whose kyc review is due or overdue , regulator gets every bank
+//args[0]: (optional) number of days to look ahead
+//===========================================================================
+func (b *Bank) getKycDueForReview(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) > 1 {
+		return shim.Error(`{"status": 500 , "message": "Incorrect number of arguments , Expecting optional number of days"}`)
+	}
+	days := 0
+	if len(args) == 1 {
+		var err error
+		days, err = strconv.Atoi(args[0])
+		if err != nil || days < 0 {
+			return shim.Error(`{"status": 500 , "message": "Can Not Convert Days to Number"}`)
+		}
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	dueBy := now.AddDate(0, 0, days)
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	regulator := isRegulator(stub)
+
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(kycKeyType, []string{})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	defer resultsIterator.Close()
+
+	var buffer bytes.Buffer
+	buffer.WriteString("[")
+	var first = true
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+
+		kyc := Kyc{}
+		json.Unmarshal(queryResponse.Value, &kyc)
+		if kyc.ObjectType != "kyc" || kyc.PersonalKycStatus != APPROVED {
+			continue
+		}
+		if !regulator && bankOfBranch(kyc.MSPID) != mspID {
+			continue
+		}
+		// approved kyc without review date is due , same as isKycReviewLapsed
+		if kyc.NextReviewDate != "" {
+			reviewDate, err := time.Parse(time.RFC3339, kyc.NextReviewDate)
+			if err == nil && reviewDate.After(dueBy) {
+				continue
+			}
+		}
+		if first == false {
+			buffer.WriteString(",")
+		}
+		first = false
+		buffer.WriteString("{\"Key\":")
+		buffer.WriteString("\"")
+		buffer.WriteString(queryResponse.Key)
+		buffer.WriteString("\"")
+
+		buffer.WriteString(", \"Value\":")
+		buffer.WriteString(string(queryResponse.Value))
+
+		buffer.WriteString("}")
+	}
+	buffer.WriteString("]")
+
+	if len(buffer.Bytes()) > 2 {
+		return shim.Success([]byte(`{"status": 200 , "data" : ` + string(buffer.Bytes()) + `}`))
+	}
+	return shim.Success([]byte(`{"status": 200 , "data": "No KYC Due For Review"}`))
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//===========================================================================
+// Limit overrides are kept as their own records under limitOverrideKeyType
+// (account number , override id). Account type limit stays the default and
+// revoked or expired overrides stay on ledger as history
+//===========================================================================
+const limitOverrideKeyType = "limitOverride"
+
+//=============================================================
+// LimitDirection : override applies to money going out or in
+//=============================================================
+type LimitDirection string
+
+const (
+	OUTGOING LimitDirection = "outgoing"
+	INCOMING LimitDirection = "incoming"
+)
+
+//====================================================================
+//LimitOverride : limit of one account for a window of time
+//====================================================================
+type LimitOverride struct {
+	ObjectType    string         `json:"doc_type"`
+	OverrideID    string         `json:"override_id"`
+	AccountNumber string         `json:"account_number"`
+	Amount        float64        `json:"amount"`
+	Direction     LimitDirection `json:"direction"`
+	ValidFrom     string         `json:"valid_from"`
+	ValidTo       string         `json:"valid_to"`
+	Reason        string         `json:"reason"`
+	ApprovedBy    string         `json:"approved_by"`
+	CreatedAt     string         `json:"created_at"`
+	Revoked       bool           `json:"revoked"`
+	RevokedBy     string         `json:"revoked_by,omitempty"`
+	RevokedAt     string         `json:"revoked_at,omitempty"`
+	RevokeReason  string         `json:"revoke_reason,omitempty"`
+}
+
+//==================================================================
+//isActive: this func will check override is in force at given time
+//==================================================================
+func (o LimitOverride) isActive(now time.Time) bool {
+	if o.Revoked {
+		return false
+	}
+	from, err := time.Parse(time.RFC3339, o.ValidFrom)
+	if err != nil || now.Before(from) {
+		return false
+	}
+	if o.ValidTo == "" {
+		return true
+	}
+	to, err := time.Parse(time.RFC3339, o.ValidTo)
+	return err == nil && now.Before(to)
+}
+
+//====================================================================
+//getLimitOverrides: this func will return every override of account
+//====================================================================
+func getLimitOverrides(stub shim.ChaincodeStubInterface, accNo string) ([]LimitOverride, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(limitOverrideKeyType, []string{accNo})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	overrides := []LimitOverride{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		override := LimitOverride{}
+		json.Unmarshal(queryResponse.Value, &override)
+		overrides = append(overrides, override)
+	}
+	sort.Slice(overrides, func(i, j int) bool { return overrides[i].CreatedAt < overrides[j].CreatedAt })
+	return overrides, nil
+}
+
+//=====================================================================================
+//effectiveLimit: this func will resolve limit of account for direction. Latest active
+//override wins , otherwise outgoing limit is the account type default and incoming is
+//not limited (found is false)
+//=====================================================================================
+func effectiveLimit(stub shim.ChaincodeStubInterface, account Account, direction LimitDirection) (float64, bool, error) {
+	now, err := getTxTime(stub)
+	if err != nil {
+		return 0, false, err
+	}
+	overrides, err := getLimitOverrides(stub, account.AccountNumber)
+	if err != nil {
+		return 0, false, err
+	}
+	for i := len(overrides) - 1; i >= 0; i-- {
+		if overrides[i].Direction == direction && overrides[i].isActive(now) {
+			return overrides[i].Amount, true, nil
+		}
+	}
+	if direction == OUTGOING {
+		return account.AccountType.Limit, true, nil
+	}
+	return 0, false, nil
+}
+
+//=====================================================================================
+//transferLimit: this func will return limit a transfer is checked against , the lower
+//of sender outgoing limit and receiver incoming limit
+//=====================================================================================
+func transferLimit(stub shim.ChaincodeStubInterface, sender Account, receiver Account) (float64, error) {
+	limit, _, err := effectiveLimit(stub, sender, OUTGOING)
+	if err != nil {
+		return 0, err
+	}
+	incoming, found, err := effectiveLimit(stub, receiver, INCOMING)
+	if err != nil {
+		return 0, err
+	}
+	if found && incoming < limit {
+		limit = incoming
+	}
+	return limit, nil
+}
+
+//======================================================================================
+//putLimitOverride: this func will validate and write limit override of customer account
+//held at caller bank
+//======================================================================================
+func putLimitOverride(stub shim.ChaincodeStubInterface, custID string, accNo string, amount string, direction string, validFrom string, validTo string, reason string) pb.Response {
+	_, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok {
+		return shim.Error(`{"status": 403 , "message":"Client has no attribute UserType"}`)
+	}
+	customerKyc := Kyc{}
+	customerKycAsBytes, _ := getKycState(stub, custID)
+	json.Unmarshal(customerKycAsBytes, &customerKyc)
+	account, err := resolveAccount(stub, customerKyc.Accounts[accNo])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if account.AccountNumber == "" {
+		return shim.Error(`{"status": 404 , "message" : "Account Not Found"}`)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if bankOfBranch(account.BranchCode) != mspID {
+		return shim.Error(`{"status": 403 , "message": "Account does not belong to ` + mspID + `"}`)
+	}
+	limit, err := strconv.ParseFloat(amount, 64)
+	if err != nil || limit < 0 {
+		return shim.Error(`{"status": 500 , "message": "Can Not Convert Limit to Number"}`)
+	}
+	limitDirection := LimitDirection(strings.ToLower(direction))
+	if limitDirection != OUTGOING && limitDirection != INCOMING {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Direction (outgoing , incoming)"}`)
+	}
+	if strings.TrimSpace(reason) == "" {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Reason of limit change"}`)
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	from := now
+	if validFrom != "" {
+		from, err = time.Parse(time.RFC3339, validFrom)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "Please Provide Valid From as RFC3339"}`)
+		}
+	}
+	to := ""
+	if validTo != "" {
+		end, err := time.Parse(time.RFC3339, validTo)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "Please Provide Valid To as RFC3339"}`)
+		}
+		if !end.After(from) {
+			return shim.Error(`{"status": 500 , "message": "Valid To must be after Valid From"}`)
+		}
+		to = end.UTC().Format(time.RFC3339)
+	}
+	approver, err := cid.GetID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	override := LimitOverride{
+		ObjectType:    limitOverrideKeyType,
+		OverrideID:    stub.GetTxID(),
+		AccountNumber: account.AccountNumber,
+		Amount:        limit,
+		Direction:     limitDirection,
+		ValidFrom:     from.UTC().Format(time.RFC3339),
+		ValidTo:       to,
+		Reason:        reason,
+		ApprovedBy:    approver,
+		CreatedAt:     now.Format(time.RFC3339),
+	}
+	key, err := stub.CreateCompositeKey(limitOverrideKeyType, []string{override.AccountNumber, override.OverrideID})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	asBytes, _ := json.Marshal(override)
+	err = stub.PutState(key, asBytes)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status":200 , "message":"Data Updated" , "override_id": "` + override.OverrideID + `"}`))
+}
+
+//=====================================================================
+//addLimitOverride: this func will add limit override to account
+//args[0]: custID
+//args[1]: account number
+//args[2]: limit amount
+//args[3]: direction (outgoing , incoming)
+//args[4]: valid from (RFC3339) , empty is now
+//args[5]: valid to (RFC3339) , empty has no end
+//args[6]: reason
+//=====================================================================
+func (b *Bank) addLimitOverride(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 7 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 7 arguments"}`)
+	}
+	return putLimitOverride(stub, args[0], args[1], args[2], args[3], args[4], args[5], args[6])
+}
+
+//=====================================================================
+//revokeLimitOverride: this func will end limit override before its time ,
+//only bank of account can revoke it
+//args[0]: account number
+//args[1]: override id
+//args[2]: reason
+//=====================================================================
+func (b *Bank) revokeLimitOverride(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 3 arguments Account Number , Override ID and Reason"}`)
+	}
+	_, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok {
+		return shim.Error(`{"status": 403 , "message":"Client has no attribute UserType"}`)
+	}
+	entry, err := getAccountIndex(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if entry.MSPID != mspID {
+		return shim.Error(`{"status": 403 , "message": "Account does not belong to ` + mspID + `"}`)
+	}
+	key, err := stub.CreateCompositeKey(limitOverrideKeyType, []string{args[0], args[1]})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	asBytes, err := stub.GetState(key)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	override := LimitOverride{}
+	json.Unmarshal(asBytes, &override)
+	if override.OverrideID == "" {
+		return shim.Error(`{"status": 404 , "message": "Limit Override Not Found"}`)
+	}
+	if override.Revoked {
+		return shim.Error(`{"status": 403 , "message": "Limit Override already Revoked"}`)
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	id, _ := cid.GetID(stub)
+	override.Revoked = true
+	override.RevokedBy = id
+	override.RevokedAt = now.Format(time.RFC3339)
+	override.RevokeReason = args[2]
+	asBytes, _ = json.Marshal(override)
+	err = stub.PutState(key, asBytes)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
+}
+
+//=======================================================================
+//AccountLimits : default and effective limits of account with overrides
+//=======================================================================
+type AccountLimits struct {
+	AccountNumber string          `json:"account_number"`
+	DefaultLimit  float64         `json:"default_limit"`
+	OutgoingLimit float64         `json:"outgoing_limit"`
+	IncomingLimit *float64        `json:"incoming_limit"`
+	Overrides     []LimitOverride `json:"overrides"`
+}
+
+//=======================================================================
+//getAccountLimits: this func will return effective limits of account and
+//history of every override
+//args[0]: custID
+//args[1]: account number
+//=======================================================================
+func (b *Bank) getAccountLimits(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 2 arguments CustID and Account Number"}`)
+	}
+	customerKyc := Kyc{}
+	customerKycAsBytes, _ := getKycState(stub, args[0])
+	json.Unmarshal(customerKycAsBytes, &customerKyc)
+	account, err := resolveAccount(stub, customerKyc.Accounts[args[1]])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if account.AccountNumber == "" {
+		return shim.Error(`{"status": 404 , "message" : "Account Not Found"}`)
+	}
+	limits := AccountLimits{
+		AccountNumber: account.AccountNumber,
+		DefaultLimit:  account.AccountType.Limit,
+	}
+	limits.OutgoingLimit, _, err = effectiveLimit(stub, account, OUTGOING)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	incoming, found, err := effectiveLimit(stub, account, INCOMING)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if found {
+		limits.IncomingLimit = &incoming
+	}
+	limits.Overrides, err = getLimitOverrides(stub, account.AccountNumber)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	asBytes, _ := json.Marshal(limits)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
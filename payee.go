@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//=============================================================================
+// Confirmation of payee : name sender expects on receiver account is compared
+// with name of account holder. Result is match , close match with masked name
+// of holder or no match , customer id of holder is never returned. Mode kept
+// under (config , payeeCheckMode) decides how transferInitiate uses it
+//=============================================================================
+const (
+	payeeCheckConfig     = "payeeCheckMode"
+	payeeCloseSimilarity = 0.8
+)
+
+//===============================================================
+// PayeeMatch : result of comparing expected name with holder
+//===============================================================
+type PayeeMatch string
+
+const (
+	PAYEEMATCH      PayeeMatch = "match"
+	PAYEECLOSEMATCH PayeeMatch = "close_match"
+	PAYEENOMATCH    PayeeMatch = "no_match"
+)
+
+//=====================================================================
+// PayeeCheckMode : off skips check , warn records result on transfer
+// and strict refuses transfer unless name matches
+//=====================================================================
+type PayeeCheckMode string
+
+const (
+	PAYEECHECKOFF    PayeeCheckMode = "off"
+	PAYEECHECKWARN   PayeeCheckMode = "warn"
+	PAYEECHECKSTRICT PayeeCheckMode = "strict"
+)
+
+//=====================================================================
+//PayeeCheck : result of confirmation of payee , SuggestedName is masked
+//name of holder given only on close match
+//=====================================================================
+type PayeeCheck struct {
+	ExpectedName  string     `json:"expected_name"`
+	Result        PayeeMatch `json:"result"`
+	SuggestedName string     `json:"suggested_name,omitempty"`
+}
+
+// honorifics are dropped before names are compared
+var honorifics = map[string]bool{"mr": true, "mrs": true, "ms": true, "miss": true, "dr": true, "prof": true}
+
+//=====================================================================
+//nameTokens: this func will return lower case words of name without
+//punctuation and honorifics
+//=====================================================================
+func nameTokens(name string) []string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := []string{}
+	for _, word := range words {
+		if !honorifics[word] {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+//=====================================================================
+//editDistance: this func will return levenshtein distance of a and b
+//=====================================================================
+func editDistance(a string, b string) int {
+	x, y := []rune(a), []rune(b)
+	previous := make([]int, len(y)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(x); i++ {
+		current := make([]int, len(y)+1)
+		current[0] = i
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+			if x[i-1] == y[j-1] {
+				cost = 0
+			}
+			current[j] = current[j-1] + 1
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if previous[j-1]+cost < current[j] {
+				current[j] = previous[j-1] + cost
+			}
+		}
+		previous = current
+	}
+	return previous[len(y)]
+}
+
+//=====================================================================
+//initialsMatch: this func will check names have same words in order ,
+//a single letter word matches any word starting with it
+//=====================================================================
+func initialsMatch(expected []string, actual []string) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+	for i := range expected {
+		if expected[i] == actual[i] {
+			continue
+		}
+		if (len(expected[i]) == 1 || len(actual[i]) == 1) && expected[i][0] == actual[i][0] {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+//=====================================================================
+//maskName: this func will keep first letter of each word of name
+//=====================================================================
+func maskName(name string) string {
+	words := strings.Fields(name)
+	for i, word := range words {
+		letters := []rune(word)
+		words[i] = string(letters[0]) + strings.Repeat("*", len(letters)-1)
+	}
+	return strings.Join(words, " ")
+}
+
+//==========================================================================
+//matchPayeeName: this func will compare name sender expects with name of
+//account holder. Same words is a match , same words in other order , given
+//as initials , or spelled nearly the same is a close match
+//==========================================================================
+func matchPayeeName(expected string, holder string) PayeeCheck {
+	check := PayeeCheck{ExpectedName: expected, Result: PAYEENOMATCH}
+	expectedTokens, holderTokens := nameTokens(expected), nameTokens(holder)
+	if len(expectedTokens) == 0 || len(holderTokens) == 0 {
+		return check
+	}
+	if strings.Join(expectedTokens, " ") == strings.Join(holderTokens, " ") {
+		check.Result = PAYEEMATCH
+		return check
+	}
+	sortedExpected := append([]string{}, expectedTokens...)
+	sortedHolder := append([]string{}, holderTokens...)
+	sort.Strings(sortedExpected)
+	sort.Strings(sortedHolder)
+	a, b := strings.Join(sortedExpected, " "), strings.Join(sortedHolder, " ")
+	longest := len([]rune(a))
+	if n := len([]rune(b)); n > longest {
+		longest = n
+	}
+	similarity := 1 - float64(editDistance(a, b))/float64(longest)
+	if a == b || initialsMatch(expectedTokens, holderTokens) || similarity >= payeeCloseSimilarity {
+		check.Result = PAYEECLOSEMATCH
+		check.SuggestedName = maskName(holder)
+	}
+	return check
+}
+
+//=====================================================================
+//getPayeeCheckMode: this func will return confirmation of payee mode ,
+//warn when not configured
+//=====================================================================
+func getPayeeCheckMode(stub shim.ChaincodeStubInterface) (PayeeCheckMode, error) {
+	key, err := stub.CreateCompositeKey(configKeyType, []string{payeeCheckConfig})
+	if err != nil {
+		return "", err
+	}
+	asBytes, err := stub.GetState(key)
+	if err != nil {
+		return "", err
+	}
+	if asBytes == nil {
+		return PAYEECHECKWARN, nil
+	}
+	return PayeeCheckMode(asBytes), nil
+}
+
+//==========================================================================
+//verifyPayee: this func will check name sender expects on receiver account
+//for transferInitiate. Result is kept on transfer , strict mode refuses a
+//transfer without expected name or whose name does not match
+//==========================================================================
+func verifyPayee(stub shim.ChaincodeStubInterface, receiver Account, expected string) (*PayeeCheck, error) {
+	mode, err := getPayeeCheckMode(stub)
+	if err != nil {
+		return nil, err
+	}
+	if mode == PAYEECHECKOFF {
+		return nil, nil
+	}
+	if expected == "" {
+		if mode == PAYEECHECKSTRICT {
+			return nil, errors.New("Please Provide Receiver Name , confirmation of payee is strict")
+		}
+		return nil, nil
+	}
+	check := matchPayeeName(expected, receiver.OwnerName)
+	if mode == PAYEECHECKSTRICT && check.Result == PAYEECLOSEMATCH {
+		return nil, errors.New("Receiver Name does not match , did you mean " + check.SuggestedName)
+	}
+	if mode == PAYEECHECKSTRICT && check.Result == PAYEENOMATCH {
+		return nil, errors.New("Receiver Name does not match")
+	}
+	return &check, nil
+}
+
+//=====================================================================
+//confirmPayee: this func will tell whether name matches holder of
+//account without returning customer id of holder
+//args[0]: account number
+//args[1]: expected name of account holder
+//=====================================================================
+func (b *Bank) confirmPayee(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Account Number and Name"}`)
+	}
+	if strings.TrimSpace(args[1]) == "" {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Name"}`)
+	}
+	entry, err := getAccountIndex(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if entry.CustID == "" {
+		return shim.Error(`{"status": 404 , "message": "Account Not Found"}`)
+	}
+	asBytes, _ := json.Marshal(matchPayeeName(args[1], entry.OwnerName))
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
+
+//=====================================================================
+//setPayeeCheckMode: this func will configure how transferInitiate uses
+//confirmation of payee on the network , admin user only
+//args[0]: mode (off , warn , strict)
+//=====================================================================
+func (b *Bank) setPayeeCheckMode(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 1 Argument"}`)
+	}
+	userType, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok || strings.ToLower(userType) != "admin" {
+		return shim.Error(`{"status": 403 , "message": "Only admin user can set confirmation of payee mode"}`)
+	}
+	mode := PayeeCheckMode(strings.ToLower(args[0]))
+	if mode != PAYEECHECKOFF && mode != PAYEECHECKWARN && mode != PAYEECHECKSTRICT {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Mode (off , warn , strict)"}`)
+	}
+	key, err := stub.CreateCompositeKey(configKeyType, []string{payeeCheckConfig})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	err = stub.PutState(key, []byte(mode))
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
+}
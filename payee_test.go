@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchPayeeName(t *testing.T) {
+	tests := []struct {
+		expected  string
+		result    PayeeMatch
+		suggested string
+	}{
+		{"Jane Smith", PAYEEMATCH, ""},
+		{"Mrs. jane  SMITH", PAYEEMATCH, ""},
+		{"Smith Jane", PAYEECLOSEMATCH, "J*** S****"},
+		{"J Smith", PAYEECLOSEMATCH, "J*** S****"},
+		{"Jane Smyth", PAYEECLOSEMATCH, "J*** S****"},
+		{"John Doe", PAYEENOMATCH, ""},
+		{"", PAYEENOMATCH, ""},
+	}
+	for _, test := range tests {
+		check := matchPayeeName(test.expected, "Jane Smith")
+		if check.Result != test.result || check.SuggestedName != test.suggested {
+			t.Fatalf("%q: got %v %q , want %v %q", test.expected, check.Result, check.SuggestedName, test.result, test.suggested)
+		}
+	}
+}
+
+func TestConfirmPayee(t *testing.T) {
+	l := newTransferLedger(t)
+	response := l.mustCall(l.bank.confirmPayee, "TR-1", "Jon Doe")
+	if !strings.Contains(string(response.Payload), `"result":"close_match"`) || !strings.Contains(string(response.Payload), `"suggested_name":"J*** D**"`) {
+		t.Fatalf("confirmPayee returned %s , want close match J*** D**", response.Payload)
+	}
+	if strings.Contains(string(response.Payload), "R1") {
+		t.Fatalf("confirmPayee returned customer id of holder: %s", response.Payload)
+	}
+	l.mustRefuse(`"status": 404`, l.bank.confirmPayee, "NOPE", "John Doe")
+}
+
+func TestPayeeCheckModes(t *testing.T) {
+	l := newTransferLedger(t)
+	// warn is default , result is kept on transfer
+	transaction := l.transaction(l.transfer("PK-1", "C1", "TR-1", "100", "", "", "", "Jon Doe"))
+	if transaction.PayeeCheck == nil || transaction.PayeeCheck.Result != PAYEECLOSEMATCH {
+		t.Fatalf("payee check on transfer is %+v , want close match", transaction.PayeeCheck)
+	}
+
+	l.mustBeAdminOnly(l.bank.setPayeeCheckMode, "strict")
+	l.as("HBLPK", "maker", "001")
+	l.mustRefuse("did you mean J*** D**", l.bank.transferInitiate, l.transferArgs("PK-1", "C1", "TR-1", "100", "", "", "", "Jon Doe")...)
+	l.mustRefuse("Receiver Name does not match", l.bank.transferInitiate, l.transferArgs("PK-1", "C1", "TR-1", "100", "", "", "", "Ali Khan")...)
+	l.mustRefuse("confirmation of payee is strict", l.bank.transferInitiate, l.transferArgs("PK-1", "C1", "TR-1", "100")...)
+	l.transfer("PK-1", "C1", "TR-1", "100", "", "", "", "john doe")
+
+	l.as("HBLPK", "admin", "001")
+	l.mustCall(l.bank.setPayeeCheckMode, "off")
+	l.as("HBLPK", "maker", "001")
+	if check := l.transaction(l.transfer("PK-1", "C1", "TR-1", "100", "", "", "", "Ali Khan")).PayeeCheck; check != nil {
+		t.Fatalf("payee checked with mode off: %+v", check)
+	}
+}
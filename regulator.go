@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//=============================================================================
+// regulatorFunctions : read only functions regulator / auditor is allowed to
+// call , every other function is refused in Invoke for regulator MSP
+//=============================================================================
+var regulatorFunctions = map[string]bool{
+	"getCertDetail":                  true,
+	"getBranchDetail":                true,
+	"checkAccount":                   true,
+	"checkStatusOfAccount":           true,
+	"checkPersonalKycStatus":         true,
+	"getCustomerKycData":             true,
+	"queryCustomerKycHistory":        true,
+	"searchPendingCustomer":          true,
+	"getTransactionByID":             true,
+	"getTransactionByAccountNumber":  true,
+	"verifyEDD":                      true,
+	"verifyReceiverEDD":              true,
+	"getKycDueForReview":             true,
+	"getCustomerRiskProfile":         true,
+	"getSuspiciousTransactionReport": true,
+	"getCorridorVolumes":             true,
+	"getFlaggedTransactions":         true,
+	"getBlackListChanges":            true,
+	"getKycApprovalStatistics":       true,
+	"getCtrThresholds":               true,
+	"getCurrencyTransactionReports":  true,
+	"getAccountOwners":               true,
+	"getCorporateEntity":             true,
+	"getUltimateBeneficialOwners":    true,
+	"getDormancyCandidates":          true,
+	"getAccountType":                 true,
+	"getAccountTypes":                true,
+	"getAccountTypeHistory":          true,
+	"getAccountLimits":               true,
+	"getConsolidatedCustomerView":    true,
+	"getBranches":                    true,
+	"getBranchHierarchy":             true,
+	"getCorrespondentRoutes":         true,
+	"getTransferLegs":                true,
+	"getFeeSchedules":                true,
+	"quoteTransferFees":              true,
+	"getStandingInstructions":        true,
+	"getBatchTransfer":               true,
+	"getTransactionByIdempotencyKey": true,
+	"getTransferTTLs":                true,
+	"getTransferReversal":            true,
+	"getDispute":                     true,
+	"getOpenDisputes":                true,
+	"confirmPayee":                   true,
+}
+
+//==============================================================
+//isRegulator: this func will check caller belongs to regulator
+//==============================================================
+func isRegulator(stub shim.ChaincodeStubInterface) bool {
+	mspID, err := cid.GetMSPID(stub)
+	return err == nil && mspID == regulatorMSPID
+}
+
+//===================================================================
+//bankOfBranch: branch codes are stored as MSPID_BranchCode on ledger
+//===================================================================
+func bankOfBranch(branchCode string) string {
+	return strings.SplitN(branchCode, "_", 2)[0]
+}
+
+//=====================================================================
+//CorridorVolume : transfer count and amount from one bank to another
+//=====================================================================
+type CorridorVolume struct {
+	SenderMSP   string                    `json:"sender_msp"`
+	ReceiverMSP string                    `json:"receiver_msp"`
+	Count       int                       `json:"count"`
+	Amount      float64                   `json:"amount"`
+	ByStatus    map[TransactionStatus]int `json:"by_status"`
+}
+
+//==========================================================================
+//getCorridorVolumes: this func will return transaction volume per corridor
+//(sender bank -> receiver bank) across the network
+//args[0]: (optional) transaction status to count
+//==========================================================================
+func (b *Bank) getCorridorVolumes(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if !isRegulator(stub) {
+		return shim.Error(`{"status": 403 , "message": "Only regulator can query network wide data"}`)
+	}
+	if len(args) > 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide optional transaction status"}`)
+	}
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(transactionKeyType, []string{})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	defer resultsIterator.Close()
+
+	corridors := make(map[string]*CorridorVolume)
+	var keys []string
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		transaction := Transaction{}
+		json.Unmarshal(queryResponse.Value, &transaction)
+		if transaction.ObjectType != "transaction" {
+			continue
+		}
+		if len(args) == 1 && string(transaction.TransactionStatus) != args[0] {
+			continue
+		}
+		senderMSP := bankOfBranch(transaction.SenderBranchName)
+		receiverMSP := bankOfBranch(transaction.ReceiverBranchName)
+		key := senderMSP + "->" + receiverMSP
+		corridor, found := corridors[key]
+		if !found {
+			corridor = &CorridorVolume{
+				SenderMSP:   senderMSP,
+				ReceiverMSP: receiverMSP,
+				ByStatus:    make(map[TransactionStatus]int),
+			}
+			corridors[key] = corridor
+			keys = append(keys, key)
+		}
+		corridor.Count++
+		corridor.Amount += transaction.Amount
+		corridor.ByStatus[transaction.TransactionStatus]++
+	}
+	sort.Strings(keys)
+	result := []CorridorVolume{}
+	for _, key := range keys {
+		result = append(result, *corridors[key])
+	}
+	asBytes, _ := json.Marshal(result)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
+
+//=============================================================================
+//getFlaggedTransactions: this func will return flagged , crime related and high
+//risk transactions of every bank
+//=============================================================================
+func (b *Bank) getFlaggedTransactions(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if !isRegulator(stub) {
+		return shim.Error(`{"status": 403 , "message": "Only regulator can query network wide data"}`)
+	}
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(transactionKeyType, []string{})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	defer resultsIterator.Close()
+
+	result := []Transaction{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		transaction := Transaction{}
+		json.Unmarshal(queryResponse.Value, &transaction)
+		if transaction.ObjectType != "transaction" {
+			continue
+		}
+		if transaction.Flagged || isCrimeRelated(transaction.Crimerelated) || transaction.Riskrating == HIGH {
+			result = append(result, transaction)
+		}
+	}
+	asBytes, _ := json.Marshal(result)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
+
+//==============================================================
+//BlackListChange : one change of customer blacklist flag
+//==============================================================
+type BlackListChange struct {
+	CustID      string `json:"cust_id"`
+	MSPID       string `json:"msp_id"`
+	TxID        string `json:"tx_id"`
+	Timestamp   string `json:"timestamp"`
+	IsBlackList bool   `json:"is_black_list"`
+}
+
+//=================================================================================
+//getBlackListChanges: this func will walk history of every customer and return each
+//time customer was added to or removed from black list
+//args[0]: (optional) custID
+//=================================================================================
+func (b *Bank) getBlackListChanges(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if !isRegulator(stub) {
+		return shim.Error(`{"status": 403 , "message": "Only regulator can query network wide data"}`)
+	}
+	if len(args) > 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide optional customer id as MSPID:custID"}`)
+	}
+	var keys []string
+	if len(args) == 1 {
+		mspID, custID, err := customerRef(stub, args[0])
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		key, err := kycKey(stub, mspID, custID)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		keys = append(keys, key)
+	} else {
+		resultsIterator, err := stub.GetStateByPartialCompositeKey(kycKeyType, []string{})
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		for resultsIterator.HasNext() {
+			queryResponse, err := resultsIterator.Next()
+			if err != nil {
+				resultsIterator.Close()
+				return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+			}
+			keys = append(keys, queryResponse.Key)
+		}
+		resultsIterator.Close()
+	}
+
+	result := []BlackListChange{}
+	for _, key := range keys {
+		historyIterator, err := stub.GetHistoryForKey(key)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		// history is returned newest first , walk it oldest first
+		var changes []BlackListChange
+		for historyIterator.HasNext() {
+			response, err := historyIterator.Next()
+			if err != nil {
+				historyIterator.Close()
+				return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+			}
+			kyc := Kyc{}
+			json.Unmarshal(response.Value, &kyc)
+			changes = append(changes, BlackListChange{
+				CustID:      kyc.CustID,
+				MSPID:       bankOfBranch(kyc.MSPID),
+				TxID:        response.TxId,
+				Timestamp:   time.Unix(response.Timestamp.Seconds, int64(response.Timestamp.Nanos)).UTC().Format(time.RFC3339),
+				IsBlackList: kyc.IsBlackList,
+			})
+		}
+		historyIterator.Close()
+		previous := false
+		for i := len(changes) - 1; i >= 0; i-- {
+			if changes[i].IsBlackList != previous {
+				result = append(result, changes[i])
+			}
+			previous = changes[i].IsBlackList
+		}
+	}
+	asBytes, _ := json.Marshal(result)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
+
+//===================================================================
+//KycStatistics : count of personal and account kyc status per bank
+//===================================================================
+type KycStatistics struct {
+	MSPID         string            `json:"msp_id"`
+	Customers     int               `json:"customers"`
+	PersonalKyc   map[KycStatus]int `json:"personal_kyc"`
+	AccountKyc    map[KycStatus]int `json:"account_kyc"`
+	BlackListed   int               `json:"black_listed"`
+	ReviewOverdue int               `json:"review_overdue"`
+}
+
+//=========================================================================
+//getKycApprovalStatistics: this func will return kyc approval statistics
+//of every bank on network
+//=========================================================================
+func (b *Bank) getKycApprovalStatistics(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if !isRegulator(stub) {
+		return shim.Error(`{"status": 403 , "message": "Only regulator can query network wide data"}`)
+	}
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(kycKeyType, []string{})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	defer resultsIterator.Close()
+
+	statistics := make(map[string]*KycStatistics)
+	var banks []string
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		kyc := Kyc{}
+		json.Unmarshal(queryResponse.Value, &kyc)
+		if kyc.ObjectType != "kyc" {
+			continue
+		}
+		mspID := bankOfBranch(kyc.MSPID)
+		bank, found := statistics[mspID]
+		if !found {
+			bank = &KycStatistics{
+				MSPID:       mspID,
+				PersonalKyc: make(map[KycStatus]int),
+				AccountKyc:  make(map[KycStatus]int),
+			}
+			statistics[mspID] = bank
+			banks = append(banks, mspID)
+		}
+		bank.Customers++
+		bank.PersonalKyc[kyc.PersonalKycStatus]++
+		for _, account := range kyc.Accounts {
+			account, _ = resolveAccount(stub, account)
+			bank.AccountKyc[account.AccountKycStatus]++
+		}
+		if kyc.IsBlackList {
+			bank.BlackListed++
+		}
+		if lapsed, _ := isKycReviewLapsed(stub, kyc); lapsed {
+			bank.ReviewOverdue++
+		}
+	}
+	sort.Strings(banks)
+	result := []KycStatistics{}
+	for _, mspID := range banks {
+		result = append(result, *statistics[mspID])
+	}
+	asBytes, _ := json.Marshal(result)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
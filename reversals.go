@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//=============================================================================
+// Reversals : sender bank asks to return full or part of a transfer accepted
+// by receiver bank , receiver bank accepts or rejects it. Accepted reversal is
+// written as a new transaction from receiver back to sender linked to original
+// and routed like any transfer. Part refunds can be asked one at a time until
+// whole amount receiver got is returned
+//=============================================================================
+
+//===============================================================
+// ReversalStatus : state of reversal requested on a transfer
+//===============================================================
+type ReversalStatus string
+
+const (
+	REVERSALREQUESTED ReversalStatus = "requested"
+	REVERSALACCEPTED  ReversalStatus = "accepted"
+	REVERSALREJECTED  ReversalStatus = "rejected"
+)
+
+//=====================================================================
+// reversalReasons : reason codes sender bank can give for reversal
+//=====================================================================
+var reversalReasons = map[string]string{
+	"DUPL": "Duplicate payment",
+	"FRAD": "Fraudulent payment",
+	"AM09": "Wrong amount",
+	"AC03": "Wrong beneficiary account",
+	"CUST": "Requested by customer",
+	"TECH": "Technical problem",
+	"DISP": "Dispute upheld",
+}
+
+//=======================================================================
+//TransferReversal : reversal requested on original transfer , ReversalID
+//is id of transaction returning funds once receiver bank accepts it
+//=======================================================================
+type TransferReversal struct {
+	Amount         float64        `json:"amount"`
+	ReasonCode     string         `json:"reason_code"`
+	Comment        string         `json:"comment,omitempty"`
+	Status         ReversalStatus `json:"status"`
+	RequestedBy    string         `json:"requested_by"`
+	RequestedAt    string         `json:"requested_at"`
+	ProcessedBy    string         `json:"processed_by,omitempty"`
+	ProcessedAt    string         `json:"processed_at,omitempty"`
+	ProcessComment string         `json:"process_comment,omitempty"`
+	ReversalID     string         `json:"reversal_id,omitempty"`
+}
+
+//===================================================================
+//refundableAmount: this func will return amount receiver got , the
+//most that can be returned to sender
+//===================================================================
+func (t Transaction) refundableAmount() float64 {
+	if t.ReceiverAmount > 0 {
+		return t.ReceiverAmount
+	}
+	return t.Amount
+}
+
+//=====================================================================
+//remainingRefund: this func will return amount not yet returned
+//=====================================================================
+func (t Transaction) remainingRefund() float64 {
+	return roundAmount(t.refundableAmount() - t.RefundedAmount)
+}
+
+//=====================================================================
+//openReversal: this func will return reversal waiting for receiver
+//bank , nil when there is none
+//=====================================================================
+func (t *Transaction) openReversal() *TransferReversal {
+	for i := range t.Reversals {
+		if t.Reversals[i].Status == REVERSALREQUESTED {
+			return &t.Reversals[i]
+		}
+	}
+	return nil
+}
+
+//=====================================================================
+//getReversalTransfer: this func will read transfer and check client is
+//branch given by side (sender or receiver branch) of it
+//=====================================================================
+func getReversalTransfer(stub shim.ChaincodeStubInterface, txID string, side func(Transaction) string) (Transaction, error) {
+	transaction := Transaction{}
+	val, ok, err := cid.GetAttributeValue(stub, "branchCode")
+	if err != nil {
+		return transaction, err
+	}
+	if !ok {
+		return transaction, errors.New("Client has no Attribute branchCode")
+	}
+	_, ok, err = cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return transaction, err
+	}
+	if !ok {
+		return transaction, errors.New("Client has no Attribute UserType")
+	}
+	asBytes, err := getTransactionState(stub, txID)
+	if err != nil {
+		return transaction, err
+	}
+	json.Unmarshal(asBytes, &transaction)
+	if transaction.TransactionID == "" {
+		return transaction, errors.New("Transaction not found")
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return transaction, err
+	}
+	if mspID+"_"+val != side(transaction) {
+		return transaction, errors.New("You are not allowed to Change Transaction Status")
+	}
+	return transaction, nil
+}
+
+//=====================================================================
+//acceptReversal: this func will accept reversal of transfer and write
+//transaction returning its amount from receiver to sender , approved by
+//receiver bank and routed back over corridor of transfer
+//=====================================================================
+func acceptReversal(stub shim.ChaincodeStubInterface, transaction *Transaction, reversal *TransferReversal, now string, actor string) error {
+	if reversal.Amount > transaction.remainingRefund() {
+		return errors.New("Amount to return is more than " + strconv.FormatFloat(transaction.remainingRefund(), 'f', -1, 64))
+	}
+	reversal.Status = REVERSALACCEPTED
+	reversal.ReversalID = stub.GetTxID()
+	refund := Transaction{
+		ObjectType:         "transaction",
+		TransactionID:      reversal.ReversalID,
+		SenderAccount:      transaction.ReceiverAccount,
+		SenderName:         transaction.ReceiverName,
+		SenderBranchName:   transaction.ReceiverBranchName,
+		ReceiverAccount:    transaction.SenderAccount,
+		ReceiverName:       transaction.SenderName,
+		ReceiverBranchName: transaction.SenderBranchName,
+		Amount:             reversal.Amount,
+		Reference:          transaction.TransactionID,
+		Purpose:            "Reversal: " + reversalReasons[reversal.ReasonCode],
+		TransactionStatus:  ACCEPTEDSENDERBANK,
+		Comment:            reversal.Comment,
+		Riskrating:         transaction.Riskrating,
+		Currency:           transaction.Currency,
+		Created:            now,
+		CreatedAt:          now,
+		CreatedBy:          actor,
+		ReversalOf:         transaction.TransactionID,
+		ReasonCode:         reversal.ReasonCode,
+	}
+	if err := routeTransaction(stub, &refund); err != nil {
+		return err
+	}
+	transaction.RefundedAmount = roundAmount(transaction.RefundedAmount + reversal.Amount)
+	return putTransaction(stub, refund)
+}
+
+//======================================================================================
+//requestTransferReversal: this func will let sender branch ask receiver bank to return
+//a transfer it has accepted
+//args[0]: transaction id
+//args[1]: reason code (DUPL , FRAD , AM09 , AC03 , CUST , TECH , DISP)
+//args[2]: (optional) amount to return , all not yet returned when empty
+//args[3]: (optional) comment
+//======================================================================================
+func (b *Bank) requestTransferReversal(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) < 2 || len(args) > 4 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Transaction ID , Reason Code , optional Amount and Comment"}`)
+	}
+	transaction, err := getReversalTransfer(stub, args[0], func(t Transaction) string { return t.SenderBranchName })
+	if err != nil {
+		return shim.Error(`{"status": 403 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if transaction.ReversalOf != "" {
+		return shim.Error(`{"status": 403 , "message": "Reversal ` + transaction.TransactionID + ` can not be reversed"}`)
+	}
+	if transaction.TransactionStatus != ACCEPTEDRECEIVERBANK {
+		return shim.Error(`{"status": 403 , "message": "Only transfers accepted by receiver bank can be reversed , transaction is ` + string(transaction.TransactionStatus) + `"}`)
+	}
+	if transaction.openReversal() != nil {
+		return shim.Error(`{"status": 409 , "message": "Reversal of transaction is already requested"}`)
+	}
+	if transaction.remainingRefund() <= 0 {
+		return shim.Error(`{"status": 409 , "message": "Transaction is already fully reversed"}`)
+	}
+	reasonCode := strings.ToUpper(strings.TrimSpace(args[1]))
+	if _, found := reversalReasons[reasonCode]; !found {
+		codes := []string{}
+		for code := range reversalReasons {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		return shim.Error(`{"status": 500 , "message": "Please Provide Reason Code (` + strings.Join(codes, " , ") + `)"}`)
+	}
+	refundable := transaction.remainingRefund()
+	amount := refundable
+	if len(args) > 2 && args[2] != "" {
+		amount, err = strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "Can Not Convert Amount to Number"}`)
+		}
+		amount = roundAmount(amount)
+	}
+	if amount <= 0 || amount > refundable {
+		return shim.Error(`{"status": 403 , "message": "Amount to return must be more than 0 and at most ` + strconv.FormatFloat(refundable, 'f', -1, 64) + `"}`)
+	}
+	now, actor, err := auditStamp(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	reversal := TransferReversal{
+		Amount:      amount,
+		ReasonCode:  reasonCode,
+		Status:      REVERSALREQUESTED,
+		RequestedBy: actor,
+		RequestedAt: now,
+	}
+	if len(args) > 3 {
+		reversal.Comment = args[3]
+	}
+	transaction.Reversals = append(transaction.Reversals, reversal)
+	if err := putTransaction(stub, transaction); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	stub.SetEvent("evtreversal", []byte(transaction.TransactionID))
+	return shim.Success([]byte(`{"status": 200 , "message": "Data Updated"}`))
+}
+
+//=========================================================================================
+//processTransferReversal: this func will let receiver branch accept or reject reversal of
+//transfer , accepting it writes reversal transaction from receiver back to sender
+//args[0]: transaction id
+//args[1]: accepted or rejected
+//args[2]: (optional) comment
+//=========================================================================================
+func (b *Bank) processTransferReversal(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 && len(args) != 3 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Transaction ID , Status and optional Comment"}`)
+	}
+	transaction, err := getReversalTransfer(stub, args[0], func(t Transaction) string { return t.ReceiverBranchName })
+	if err != nil {
+		return shim.Error(`{"status": 403 , "message": "` + string(err.Error()) + `"}`)
+	}
+	reversal := transaction.openReversal()
+	if reversal == nil {
+		return shim.Error(`{"status": 404 , "message": "No reversal requested on transaction"}`)
+	}
+	now, actor, err := auditStamp(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	reversal.ProcessedBy = actor
+	reversal.ProcessedAt = now
+	if len(args) == 3 {
+		reversal.ProcessComment = args[2]
+	}
+	switch ReversalStatus(strings.ToLower(args[1])) {
+	case REVERSALACCEPTED:
+		if err := acceptReversal(stub, &transaction, reversal, now, actor); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+	case REVERSALREJECTED:
+		reversal.Status = REVERSALREJECTED
+	default:
+		return shim.Error(`{"status": 500 , "message": "Please Provide accepted or rejected"}`)
+	}
+	if err := putTransaction(stub, transaction); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	stub.SetEvent("evtreversal", []byte(transaction.TransactionID))
+	return shim.Success([]byte(`{"status": 200 , "message": "Data Updated" , "reversal_id": "` + reversal.ReversalID + `"}`))
+}
+
+//===================================================================
+//getTransferReversal: this func will return reversals of transfer and
+//transactions that returned funds of accepted ones
+//args[0]: transaction id
+//===================================================================
+func (b *Bank) getTransferReversal(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Transaction ID"}`)
+	}
+	asBytes, err := getTransactionState(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	transaction := Transaction{}
+	json.Unmarshal(asBytes, &transaction)
+	if transaction.TransactionID == "" {
+		return shim.Error(`{"status": 404 , "message": "Transaction not found"}`)
+	}
+	if len(transaction.Reversals) == 0 {
+		return shim.Error(`{"status": 404 , "message": "No reversal requested on transaction"}`)
+	}
+	result := struct {
+		TransactionID  string             `json:"transaction_id"`
+		RefundedAmount float64            `json:"refunded_amount"`
+		Reversals      []TransferReversal `json:"reversals"`
+		Refunds        []Transaction      `json:"refunds"`
+	}{TransactionID: transaction.TransactionID, RefundedAmount: transaction.RefundedAmount, Reversals: transaction.Reversals, Refunds: []Transaction{}}
+	for _, reversal := range transaction.Reversals {
+		if reversal.ReversalID == "" {
+			continue
+		}
+		refundAsBytes, err := getTransactionState(stub, reversal.ReversalID)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		refund := Transaction{}
+		json.Unmarshal(refundAsBytes, &refund)
+		result.Refunds = append(result.Refunds, refund)
+	}
+	asBytes, _ = json.Marshal(result)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
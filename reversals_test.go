@@ -0,0 +1,76 @@
+package main
+
+import "testing"
+
+// acceptedTransfer : transfer of 1000 from PK-1 accepted by receiver bank
+func acceptedTransfer(l *testLedger) string {
+	l.t.Helper()
+	txID := l.transfer("PK-1", "C1", "TR-1", "1000")
+	l.as("HBLTR", "checker", "001")
+	l.mustCall(l.bank.processPendingTransactionReceiver, txID, "approved", "credited", "")
+	l.as("HBLPK", "maker", "001")
+	return txID
+}
+
+// reverse : request reversal at sender branch and accept it at receiver
+// branch , returns id of refund
+func reverse(l *testLedger, txID string, amount string) string {
+	l.t.Helper()
+	l.as("HBLPK", "maker", "001")
+	l.mustCall(l.bank.requestTransferReversal, txID, "DUPL", amount)
+	l.as("HBLTR", "checker", "001")
+	l.mustCall(l.bank.processTransferReversal, txID, "accepted")
+	refundID := l.lastTxID
+	l.as("HBLPK", "maker", "001")
+	return refundID
+}
+
+func TestPartialReversalsUpToRefundable(t *testing.T) {
+	l := newTransferLedger(t)
+	txID := acceptedTransfer(l)
+
+	refundID := reverse(l, txID, "400")
+	refund := l.transaction(refundID)
+	if refund.ReversalOf != txID || refund.Amount != 400 || refund.SenderAccount != "TR-1" || refund.ReceiverAccount != "PK-1" {
+		t.Fatalf("refund is %+v , want 400 from TR-1 to PK-1", refund)
+	}
+	if refunded := l.transaction(txID).RefundedAmount; refunded != 400 {
+		t.Fatalf("refunded amount is %v , want 400", refunded)
+	}
+
+	l.mustRefuse("at most 600", l.bank.requestTransferReversal, txID, "DUPL", "700")
+	reverse(l, txID, "600")
+	if refunded := l.transaction(txID).RefundedAmount; refunded != 1000 {
+		t.Fatalf("refunded amount is %v , want 1000", refunded)
+	}
+	l.mustRefuse("already fully reversed", l.bank.requestTransferReversal, txID, "DUPL")
+
+	// refund is counted on reversed transfer and can not be taken back
+	l.as("HBLTR", "checker", "001")
+	l.mustRefuse("can not be cancelled", l.bank.CancelTransacation, refundID, "cancelled")
+	l.mustRefuse("can not be reversed", l.bank.requestTransferReversal, refundID, "DUPL")
+}
+
+func TestRejectedReversalKeepsRefundable(t *testing.T) {
+	l := newTransferLedger(t)
+	txID := acceptedTransfer(l)
+	l.mustCall(l.bank.requestTransferReversal, txID, "CUST", "300")
+	l.mustRefuse(`"status": 409`, l.bank.requestTransferReversal, txID, "CUST", "100")
+	l.as("HBLTR", "checker", "001")
+	l.mustCall(l.bank.processTransferReversal, txID, "rejected", "funds withdrawn")
+	if refunded := l.transaction(txID).RefundedAmount; refunded != 0 {
+		t.Fatalf("refunded amount is %v after rejection , want 0", refunded)
+	}
+	reverse(l, txID, "")
+	if refunded := l.transaction(txID).RefundedAmount; refunded != 1000 {
+		t.Fatalf("refunded amount is %v , want whole transfer", refunded)
+	}
+}
+
+func TestReversalOnlyOfAcceptedTransfer(t *testing.T) {
+	l := newTransferLedger(t)
+	txID := l.transfer("PK-1", "C1", "TR-1", "1000")
+	l.mustRefuse("Only transfers accepted by receiver bank", l.bank.requestTransferReversal, txID, "DUPL")
+	l.as("HBLTR", "checker", "001")
+	l.mustRefuse("not allowed", l.bank.requestTransferReversal, txID, "DUPL")
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//==========================================================================
+// highRiskCountries / highRiskOccupations : customers matching these lists
+// attract extra points in their risk score
+//==========================================================================
+var highRiskCountries = map[string]bool{
+	"AF": true,
+	"IR": true,
+	"KP": true,
+	"MM": true,
+	"SY": true,
+	"YE": true,
+}
+
+var highRiskOccupations = map[string]bool{
+	"money changer":     true,
+	"jeweller":          true,
+	"real estate agent": true,
+	"arms dealer":       true,
+	"casino":            true,
+	"cash intensive":    true,
+}
+
+//===================================================================
+// riskWeights : points added to customer risk score for each factor
+//===================================================================
+const (
+	pepRiskWeight             = 30
+	countryRiskWeight         = 20
+	occupationRiskWeight      = 15
+	blackListHistoryWeight    = 20
+	highRiskTransferWeight    = 5
+	crimeRelatedWeight        = 15
+	eddRejectedWeight         = 10
+	transactionHistoryMaxRisk = 30
+	maxRiskScore              = 100
+)
+
+//======================================================================
+//riskLevelOfScore: this func will convert risk score to Riskrating tier
+//======================================================================
+func riskLevelOfScore(score int) Riskrating {
+	if score >= 60 {
+		return HIGH
+	} else if score >= 30 {
+		return MEDIUM
+	}
+	return LOW
+}
+
+//=====================================================================
+//isCrimeRelated: this func will read free text crime related flag
+//=====================================================================
+func isCrimeRelated(value string) bool {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" || value == "no" || value == "none" {
+		return false
+	}
+	if flag, err := strconv.ParseBool(value); err == nil {
+		return flag
+	}
+	return true
+}
+
+//==================================================================================
+//wasEverBlackListed: this func will walk kyc history and check if customer has ever
+//been on the black list
+//==================================================================================
+func wasEverBlackListed(stub shim.ChaincodeStubInterface, kyc Kyc) (bool, error) {
+	key, err := kycKeyOf(stub, kyc)
+	if err != nil {
+		return false, err
+	}
+	resultsIterator, err := stub.GetHistoryForKey(key)
+	if err != nil {
+		return false, err
+	}
+	defer resultsIterator.Close()
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return false, err
+		}
+		old := Kyc{}
+		json.Unmarshal(response.Value, &old)
+		if old.IsBlackList {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+//==========================================================================================
+//computeCustomerRiskScore: this func will recompute the risk score of customer from profile
+//flags , transaction history of all customer accounts , EDD outcomes and blacklist history.
+//It runs on events that change risk (kyc update , blacklist , EDD rejection , STR) and not
+//on transfers. pending are transfers changed by caller in this invocation. Result is stored
+//on kyc , caller has to write kyc to ledger
+//==========================================================================================
+func computeCustomerRiskScore(stub shim.ChaincodeStubInterface, kyc *Kyc, pending ...Transaction) error {
+	score := 0
+	var factors []string
+
+	if kyc.IsBlackList {
+		score = maxRiskScore
+		factors = append(factors, "customer is black listed")
+	}
+	if kyc.IsPEP {
+		score += pepRiskWeight
+		factors = append(factors, "politically exposed person")
+	}
+	if highRiskCountries[strings.ToUpper(kyc.Country)] {
+		score += countryRiskWeight
+		factors = append(factors, "high risk country "+kyc.Country)
+	}
+	if highRiskOccupations[strings.ToLower(kyc.Occupation)] {
+		score += occupationRiskWeight
+		factors = append(factors, "high risk occupation "+kyc.Occupation)
+	}
+	blackListed, err := wasEverBlackListed(stub, *kyc)
+	if err != nil {
+		return err
+	}
+	if blackListed && !kyc.IsBlackList {
+		score += blackListHistoryWeight
+		factors = append(factors, "previously black listed")
+	}
+
+	if len(kyc.Accounts) > 0 {
+		// transfers changed by this invocation are not visible through GetState yet
+		changed := make(map[string]Transaction)
+		for _, transaction := range pending {
+			changed[transaction.TransactionID] = transaction
+		}
+		seen := make(map[string]bool)
+		historyScore := 0
+		var highRisk, crimeRelated, eddRejected int
+		for accNo := range kyc.Accounts {
+			ids, err := getAccountTransactionIDs(stub, accNo)
+			if err != nil {
+				return err
+			}
+			for _, txID := range ids {
+				if seen[txID] {
+					continue
+				}
+				seen[txID] = true
+				transaction, found := changed[txID]
+				if !found {
+					asBytes, err := getTransactionState(stub, txID)
+					if err != nil {
+						return err
+					}
+					json.Unmarshal(asBytes, &transaction)
+				}
+				_, isReceiver := kyc.Accounts[transaction.ReceiverAccount]
+				if transaction.Riskrating == HIGH {
+					highRisk++
+					historyScore += highRiskTransferWeight
+				}
+				if isCrimeRelated(transaction.Crimerelated) {
+					crimeRelated++
+					historyScore += crimeRelatedWeight
+				}
+				if isReceiver && transaction.RecieverEDD != "" && transaction.TransactionStatus == REJECTEDRECEIVERBANK {
+					eddRejected++
+					historyScore += eddRejectedWeight
+				}
+			}
+		}
+		if historyScore > transactionHistoryMaxRisk {
+			historyScore = transactionHistoryMaxRisk
+		}
+		score += historyScore
+		if highRisk > 0 {
+			factors = append(factors, strconv.Itoa(highRisk)+" high risk transfers")
+		}
+		if crimeRelated > 0 {
+			factors = append(factors, strconv.Itoa(crimeRelated)+" crime related transfers")
+		}
+		if eddRejected > 0 {
+			factors = append(factors, strconv.Itoa(eddRejected)+" transfers rejected after EDD")
+		}
+	}
+
+	if score > maxRiskScore {
+		score = maxRiskScore
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return err
+	}
+	kyc.RiskScore = score
+	kyc.RiskLevel = riskLevelOfScore(score)
+	kyc.RiskFactors = factors
+	kyc.RiskScoredAt = now.Format(time.RFC3339)
+	return nil
+}
+
+//==========================================================================
+//autoApproveTransfer: this func decide whether transfer can be approved at
+//sender bank automatically ("A") or needs manual review ("i")
+//==========================================================================
+func autoApproveTransfer(amount float64, limit float64, senderKyc Kyc) bool {
+	return amount <= limit && senderKyc.RiskLevel != HIGH
+}
+
+//=================================================================================
+//updateCustomerRiskFactors: this func will update customer profile risk flags and
+//recompute risk score
+//args[0]: custID
+//args[1]: occupation
+//args[2]: country (ISO code)
+//args[3]: isPEP bool
+//=================================================================================
+func (b *Bank) updateCustomerRiskFactors(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 4 {
+		return shim.Error(`{"status": 500 , "message": "Incorrect number of arguments , Expecting 4"}`)
+	}
+	_, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok {
+		return shim.Error(`{"status": 403 , "message":"Client has no attribute UserType"}`)
+	}
+	customerAsBytes, err := getKycState(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	kyc := Kyc{}
+	json.Unmarshal(customerAsBytes, &kyc)
+	if kyc.CustID == "" {
+		return shim.Error(`{"status": 404 , "message": "Customer id Not Found"}`)
+	}
+	isPEP, err := strconv.ParseBool(args[3])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "Please Provide true or false for PEP"}`)
+	}
+	kyc.Occupation = args[1]
+	kyc.Country = strings.ToUpper(args[2])
+	kyc.IsPEP = isPEP
+	if err := computeCustomerRiskScore(stub, &kyc); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	err = putKyc(stub, kyc)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
+}
+
+//=============================================================================
+//getCustomerRiskProfile: this func will recompute and return customer risk score
+//args[0]: custID
+//=============================================================================
+func (b *Bank) getCustomerRiskProfile(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(`{"status": 500 , "message": "Incorrect number of arguments , Expecting id of customer"}`)
+	}
+	customerAsBytes, err := getKycState(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	kyc := Kyc{}
+	json.Unmarshal(customerAsBytes, &kyc)
+	if kyc.CustID == "" {
+		return shim.Error(`{"status": 404 , "message": "Customer id Not Found"}`)
+	}
+	if err := computeCustomerRiskScore(stub, &kyc); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	profile := map[string]interface{}{
+		"CustID":      kyc.CustID,
+		"RiskScore":   kyc.RiskScore,
+		"RiskLevel":   kyc.RiskLevel,
+		"RiskFactors": kyc.RiskFactors,
+		"Occupation":  kyc.Occupation,
+		"Country":     kyc.Country,
+		"IsPEP":       kyc.IsPEP,
+		"ScoredAt":    kyc.RiskScoredAt,
+	}
+	asBytes, _ := json.Marshal(profile)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//=============================================================================
+// Correspondent routing : sender bank keeps a route to each receiver bank it
+// can not settle with directly. Route lists intermediary banks in order and a
+// transfer over it is split in legs (sender -> hop1 -> ... -> receiver) that
+// are settled by paying bank and confirmed by receiving bank one by one
+//=============================================================================
+const routeKeyType = "route"
+
+//===============================================================
+// LegStatus : settlement status of one leg of routed transfer
+//===============================================================
+type LegStatus string
+
+const (
+	LEGPENDING   LegStatus = "pending"
+	LEGSETTLED   LegStatus = "settled"
+	LEGCONFIRMED LegStatus = "confirmed"
+	LEGREJECTED  LegStatus = "rejected"
+)
+
+//===================================================================
+//RouteHop : intermediary bank of route and fee it charges per leg
+//===================================================================
+type RouteHop struct {
+	MSPID      string  `json:"msp_id"`
+	FeeFixed   float64 `json:"fee_fixed"`
+	FeePercent float64 `json:"fee_percent"`
+}
+
+//=====================================================================
+//CorrespondentRoute : intermediaries from sender bank to receiver bank
+//=====================================================================
+type CorrespondentRoute struct {
+	ObjectType  string     `json:"doc_type"`
+	SenderMSP   string     `json:"sender_msp"`
+	ReceiverMSP string     `json:"receiver_msp"`
+	Hops        []RouteHop `json:"hops"`
+	UpdatedBy   string     `json:"updated_by"`
+	UpdatedAt   string     `json:"updated_at"`
+}
+
+//=======================================================================
+//TransferLeg : one hop of routed transfer , Fee is kept by ToMSP when it
+//is an intermediary and Amount is what FromMSP pays to ToMSP
+//=======================================================================
+type TransferLeg struct {
+	Sequence    int       `json:"sequence"`
+	FromMSP     string    `json:"from_msp"`
+	ToMSP       string    `json:"to_msp"`
+	Amount      float64   `json:"amount"`
+	Fee         float64   `json:"fee"`
+	Status      LegStatus `json:"status"`
+	SettledBy   string    `json:"settled_by,omitempty"`
+	SettledAt   string    `json:"settled_at,omitempty"`
+	ConfirmedBy string    `json:"confirmed_by,omitempty"`
+	ConfirmedAt string    `json:"confirmed_at,omitempty"`
+	Comment     string    `json:"comment,omitempty"`
+}
+
+//=======================================================
+//fee: this func will return fee hop charges on amount
+//=======================================================
+func (h RouteHop) fee(amount float64) float64 {
+	return math.Round((h.FeeFixed+amount*h.FeePercent/100)*100) / 100
+}
+
+//==============================================================
+//getCorrespondentRoute: this func will read route between banks
+//==============================================================
+func getCorrespondentRoute(stub shim.ChaincodeStubInterface, senderMSP string, receiverMSP string) (CorrespondentRoute, error) {
+	route := CorrespondentRoute{}
+	key, err := stub.CreateCompositeKey(routeKeyType, []string{senderMSP, receiverMSP})
+	if err != nil {
+		return route, err
+	}
+	asBytes, err := stub.GetState(key)
+	if err != nil {
+		return route, err
+	}
+	json.Unmarshal(asBytes, &route)
+	return route, nil
+}
+
+//=====================================================================================
+//routeTransaction: this func will split transaction in legs when sender bank has route
+//to receiver bank , transfers between banks without route are settled directly
+//=====================================================================================
+func routeTransaction(stub shim.ChaincodeStubInterface, transaction *Transaction) error {
+	senderMSP := bankOfBranch(transaction.SenderBranchName)
+	receiverMSP := bankOfBranch(transaction.ReceiverBranchName)
+	if senderMSP == receiverMSP {
+		return nil
+	}
+	route, err := getCorrespondentRoute(stub, senderMSP, receiverMSP)
+	if err != nil || len(route.Hops) == 0 {
+		return err
+	}
+	amount := transaction.Amount
+	from := senderMSP
+	legs := []TransferLeg{}
+	fees := 0.0
+	for i, hop := range route.Hops {
+		fee := hop.fee(amount)
+		if fee >= amount {
+			return errors.New("Fee of correspondent " + hop.MSPID + " is more than amount")
+		}
+		legs = append(legs, TransferLeg{Sequence: i + 1, FromMSP: from, ToMSP: hop.MSPID, Amount: amount, Fee: fee, Status: LEGPENDING})
+		fees += fee
+		amount = math.Round((amount-fee)*100) / 100
+		from = hop.MSPID
+	}
+	legs = append(legs, TransferLeg{Sequence: len(legs) + 1, FromMSP: from, ToMSP: receiverMSP, Amount: amount, Status: LEGPENDING})
+	transaction.Legs = legs
+	transaction.RoutingFees = math.Round(fees*100) / 100
+	return nil
+}
+
+//====================================================================
+//isRouteDelivered: this func will check every leg of transfer is
+//confirmed , transfers without legs are delivered directly
+//====================================================================
+func isRouteDelivered(transaction Transaction) bool {
+	for _, leg := range transaction.Legs {
+		if leg.Status != LEGCONFIRMED {
+			return false
+		}
+	}
+	return true
+}
+
+//==================================================================================
+//setCorrespondentRoute: this func will set intermediaries caller bank uses to reach
+//receiver bank , empty hop list removes route
+//args[0]: receiver bank MSPID
+//args[1]: hops json [{"msp_id":"" , "fee_fixed":0 , "fee_percent":0}]
+//==================================================================================
+func (b *Bank) setCorrespondentRoute(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Receiver MSPID and Hops"}`)
+	}
+	_, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok {
+		return shim.Error(`{"status": 403 , "message":"Client has no attribute UserType"}`)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if args[0] == "" || args[0] == mspID {
+		return shim.Error(`{"status": 500 , "message": "Please Provide MSPID of other bank"}`)
+	}
+	hops := []RouteHop{}
+	if err := json.Unmarshal([]byte(args[1]), &hops); err != nil {
+		return shim.Error(`{"status": 500 , "message": "Please check Hops of route"}`)
+	}
+	seen := map[string]bool{mspID: true, args[0]: true}
+	for _, hop := range hops {
+		if hop.MSPID == "" || seen[hop.MSPID] {
+			return shim.Error(`{"status": 500 , "message": "Intermediary ` + hop.MSPID + ` is not valid or repeated on route"}`)
+		}
+		seen[hop.MSPID] = true
+		if hop.FeeFixed < 0 || hop.FeePercent < 0 || hop.FeePercent >= 100 {
+			return shim.Error(`{"status": 500 , "message": "Fee of intermediary ` + hop.MSPID + ` is not valid"}`)
+		}
+	}
+	key, err := stub.CreateCompositeKey(routeKeyType, []string{mspID, args[0]})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if len(hops) == 0 {
+		if err := stub.DelState(key); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		return shim.Success([]byte(`{"status": 200 , "message": "Data Updated"}`))
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	id, _ := cid.GetID(stub)
+	route := CorrespondentRoute{
+		ObjectType:  routeKeyType,
+		SenderMSP:   mspID,
+		ReceiverMSP: args[0],
+		Hops:        hops,
+		UpdatedBy:   mspID + "_" + id,
+		UpdatedAt:   now.Format(time.RFC3339),
+	}
+	asBytes, _ := json.Marshal(route)
+	if err := stub.PutState(key, asBytes); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status": 200 , "message": "Data Updated"}`))
+}
+
+//==================================================================
+//getCorrespondentRoutes: this func will return routes of bank
+//args[0]: (optional) sender bank MSPID , caller bank when not given
+//==================================================================
+func (b *Bank) getCorrespondentRoutes(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) > 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide optional MSPID"}`)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if len(args) == 1 && args[0] != "" {
+		mspID = args[0]
+	}
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(routeKeyType, []string{mspID})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	defer resultsIterator.Close()
+
+	routes := []CorrespondentRoute{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		route := CorrespondentRoute{}
+		json.Unmarshal(queryResponse.Value, &route)
+		routes = append(routes, route)
+	}
+	asBytes, _ := json.Marshal(routes)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
+
+//=======================================================================================
+//updateTransferLeg: this func will load leg of routed transfer for bank of caller. Legs
+//are settled in order , so leg can only move when every leg before it is confirmed
+//=======================================================================================
+func updateTransferLeg(stub shim.ChaincodeStubInterface, txID string, sequence string) (Transaction, int, string, error) {
+	transaction := Transaction{}
+	_, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return transaction, 0, "", err
+	}
+	if !ok {
+		return transaction, 0, "", errors.New("Client has no attribute UserType")
+	}
+	asBytes, err := getTransactionState(stub, txID)
+	if err != nil {
+		return transaction, 0, "", err
+	}
+	json.Unmarshal(asBytes, &transaction)
+	if transaction.TransactionID == "" {
+		return transaction, 0, "", errors.New("Transaction not found")
+	}
+	seq, err := strconv.Atoi(sequence)
+	if err != nil || seq < 1 || seq > len(transaction.Legs) {
+		return transaction, 0, "", errors.New("Please Provide valid Leg Sequence")
+	}
+	if transaction.TransactionStatus != ACCEPTEDSENDERBANK {
+		return transaction, 0, "", errors.New("Transaction is " + string(transaction.TransactionStatus))
+	}
+	for _, leg := range transaction.Legs[:seq-1] {
+		if leg.Status != LEGCONFIRMED {
+			return transaction, 0, "", errors.New("Leg " + strconv.Itoa(leg.Sequence) + " is not confirmed")
+		}
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return transaction, 0, "", err
+	}
+	id, _ := cid.GetID(stub)
+	return transaction, seq - 1, mspID + "_" + id, nil
+}
+
+//===================================================================================
+//settleTransferLeg: this func will record paying bank of leg has sent funds to next
+//bank on route
+//args[0]: transaction id
+//args[1]: leg sequence
+//args[2]: (optional) settlement reference
+//===================================================================================
+func (b *Bank) settleTransferLeg(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 && len(args) != 3 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Transaction ID , Leg Sequence and optional Reference"}`)
+	}
+	transaction, i, actor, err := updateTransferLeg(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(`{"status": 403 , "message": "` + string(err.Error()) + `"}`)
+	}
+	leg := &transaction.Legs[i]
+	if bankOfBranch(actor) != leg.FromMSP {
+		return shim.Error(`{"status": 403 , "message": "Only ` + leg.FromMSP + ` can settle this leg"}`)
+	}
+	if leg.Status != LEGPENDING {
+		return shim.Error(`{"status": 403 , "message": "Leg is ` + string(leg.Status) + `"}`)
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	leg.Status = LEGSETTLED
+	leg.SettledBy = actor
+	leg.SettledAt = now.Format(time.RFC3339)
+	if len(args) == 3 {
+		leg.Comment = args[2]
+	}
+	if err := putTransaction(stub, transaction); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	stub.SetEvent("evttransferleg", []byte(transaction.TransactionID))
+	return shim.Success([]byte(`{"status": 200 , "message": "Data Updated"}`))
+}
+
+//================================================================================
+//confirmTransferLeg: this func will record receiving bank of leg got funds or
+//reject leg so that paying bank has to settle it again
+//args[0]: transaction id
+//args[1]: leg sequence
+//args[2]: confirmed or rejected
+//args[3]: (optional) comment
+//================================================================================
+func (b *Bank) confirmTransferLeg(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 && len(args) != 4 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Transaction ID , Leg Sequence , Status and optional Comment"}`)
+	}
+	transaction, i, actor, err := updateTransferLeg(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(`{"status": 403 , "message": "` + string(err.Error()) + `"}`)
+	}
+	leg := &transaction.Legs[i]
+	if bankOfBranch(actor) != leg.ToMSP {
+		return shim.Error(`{"status": 403 , "message": "Only ` + leg.ToMSP + ` can confirm this leg"}`)
+	}
+	if leg.Status != LEGSETTLED {
+		return shim.Error(`{"status": 403 , "message": "Leg is ` + string(leg.Status) + `"}`)
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	switch LegStatus(strings.ToLower(args[2])) {
+	case LEGCONFIRMED:
+		leg.Status = LEGCONFIRMED
+	case LEGREJECTED:
+		// rejected leg goes back to paying bank to settle again
+		leg.Status = LEGPENDING
+	default:
+		return shim.Error(`{"status": 500 , "message": "Please Provide confirmed or rejected"}`)
+	}
+	leg.ConfirmedBy = actor
+	leg.ConfirmedAt = now.Format(time.RFC3339)
+	if len(args) == 4 {
+		leg.Comment = args[3]
+	}
+	if err := putTransaction(stub, transaction); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	stub.SetEvent("evttransferleg", []byte(transaction.TransactionID))
+	return shim.Success([]byte(`{"status": 200 , "message": "Data Updated"}`))
+}
+
+//==============================================================
+//getTransferLegs: this func will return legs of routed transfer
+//args[0]: transaction id
+//==============================================================
+func (b *Bank) getTransferLegs(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Transaction ID"}`)
+	}
+	asBytes, err := getTransactionState(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	transaction := Transaction{}
+	json.Unmarshal(asBytes, &transaction)
+	if transaction.TransactionID == "" {
+		return shim.Error(`{"status": 404 , "message": "Transaction not found"}`)
+	}
+	legs := transaction.Legs
+	if legs == nil {
+		legs = []TransferLeg{}
+	}
+	asBytes, _ = json.Marshal(legs)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//=======================================================================
+// Transfers from joint and business accounts wait in AWAITINGSIGNATURES
+// until signatories required by account mandate have signed them
+//=======================================================================
+const (
+	AWAITINGSIGNATURES TransactionStatus = "Awaiting Signatures"
+	EXPIRED            TransactionStatus = "Expired"
+)
+
+const (
+	configKeyType                = "config"
+	signatureTimeoutConfig       = "signatureTimeoutHours"
+	defaultSignatureTimeoutHours = 48
+)
+
+//===============================================================
+//TransferSignature : approval of a signatory on a transfer
+//===============================================================
+type TransferSignature struct {
+	CustID   string `json:"cust_id"`
+	SignerID string `json:"signer_id"`
+	SignedAt string `json:"signed_at"`
+}
+
+//=====================================================================
+//TransferSigning : signatures a transfer has and how many it needs
+//=====================================================================
+type TransferSigning struct {
+	Required   int
+	Deadline   string
+	Signatures []TransferSignature
+}
+
+//===================================================================
+//requiredSignatures: this func will return signatures needed by the
+//mandate of joint / business account
+//===================================================================
+func requiredSignatures(account Account) int {
+	signatories := 0
+	for _, owner := range account.Owners {
+		if owner.canSign() {
+			signatories++
+		}
+	}
+	switch account.Mandate {
+	case ANYTWO:
+		return 2
+	case ALL:
+		return signatories
+	}
+	return 1
+}
+
+//=====================================================================
+//getSignatureTimeout: this func will return configured signature time
+//=====================================================================
+func getSignatureTimeout(stub shim.ChaincodeStubInterface) (time.Duration, error) {
+	key, err := stub.CreateCompositeKey(configKeyType, []string{signatureTimeoutConfig})
+	if err != nil {
+		return 0, err
+	}
+	asBytes, err := stub.GetState(key)
+	if err != nil {
+		return 0, err
+	}
+	hours := defaultSignatureTimeoutHours
+	if asBytes != nil {
+		hours, err = strconv.Atoi(string(asBytes))
+		if err != nil {
+			return 0, err
+		}
+	}
+	return time.Duration(hours) * time.Hour, nil
+}
+
+//=====================================================================================
+//newTransferSigning: this func will start signature collection of a transfer from
+//joint / business account. First signatory listed in transfer payload signs with the
+//identity that submitted the transfer
+//=====================================================================================
+func newTransferSigning(stub shim.ChaincodeStubInterface, account Account, accounts map[string]interface{}) (*TransferSigning, error) {
+	now, err := getTxTime(stub)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := getSignatureTimeout(stub)
+	if err != nil {
+		return nil, err
+	}
+	signerID, err := cid.GetID(stub)
+	if err != nil {
+		return nil, err
+	}
+	signing := &TransferSigning{
+		Required: requiredSignatures(account),
+		Deadline: now.Add(timeout).Format(time.RFC3339),
+	}
+	customers, _ := accounts["Customers"].([]interface{})
+	for _, value := range customers {
+		customer, _ := value.(map[string]interface{})
+		custID, _ := customer["CustID"].(string)
+		for _, owner := range account.Owners {
+			if owner.CustID == custID && owner.canSign() {
+				signing.Signatures = append(signing.Signatures, TransferSignature{
+					CustID:   custID,
+					SignerID: signerID,
+					SignedAt: now.Format(time.RFC3339),
+				})
+				return signing, nil
+			}
+		}
+	}
+	return signing, nil
+}
+
+//==================================================================================
+//signTransfer: this func will add approval of a signatory to transfer waiting for
+//signatures. Only bank of account takes signatures and signatory proves identity with
+//national id and date of birth in transient field identity , each signatory signs once
+//args[0]: transaction id
+//args[1]: custID of signatory
+//args[2]: personal hash of signatory
+//==================================================================================
+func (b *Bank) signTransfer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 3 arguments Transaction Id , CustID and Hash"}`)
+	}
+	_, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok {
+		return shim.Error(`{"status": 403 , "message":"Client has no attribute UserType"}`)
+	}
+	transaction := Transaction{}
+	asBytes, err := getTransactionState(stub, args[0])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	json.Unmarshal(asBytes, &transaction)
+	if transaction.TransactionID == "" {
+		return shim.Error(`{"status": 404 , "message": "Transaction not found"}`)
+	}
+	if transaction.TransactionStatus != AWAITINGSIGNATURES {
+		return shim.Error(`{"status": 403 , "message": "Transaction is not waiting for signatures"}`)
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	deadline, err := time.Parse(time.RFC3339, transaction.SignatureDeadline)
+	if err == nil && now.After(deadline) {
+		transaction.TransactionStatus = EXPIRED
+		transaction.Comment = "Signatures not collected before " + transaction.SignatureDeadline
+		if err := putTransaction(stub, transaction); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		if err := releaseCurrencyTransactionReport(stub, transaction, nil); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		stub.SetEvent("evtsender", []byte(transaction.TransactionID))
+		return shim.Success([]byte(`{"status": 200 , "message": "Transaction Expired"}`))
+	}
+
+	account, err := getSharedAccount(stub, transaction.SenderAccount)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if bankOfBranch(account.BranchCode) != mspID {
+		return shim.Error(`{"status": 403 , "message": "Account does not belong to ` + mspID + `"}`)
+	}
+	isSignatory := false
+	for _, owner := range account.Owners {
+		if owner.CustID == args[1] && owner.canSign() {
+			isSignatory = true
+		}
+	}
+	if !isSignatory {
+		return shim.Error(`{"status": 403 , "message": "Customer is not signatory of account"}`)
+	}
+	kycAsBytes, _ := getKycState(stub, args[1])
+	kyc := Kyc{}
+	json.Unmarshal(kycAsBytes, &kyc)
+	if kyc.IsBlackList {
+		return shim.Error(`{"status": 403 , "message": "Signatory is in Black List"}`)
+	}
+	if kyc.PersonalHash != args[2] {
+		return shim.Error(`{"status": 403 , "message": "Personal Hash not Match"}`)
+	}
+	if kyc.IdentityFingerprint == "" {
+		return shim.Error(`{"status": 403 , "message": "Identity of signatory is not registered"}`)
+	}
+	fingerprint, err := identityFingerprint(stub)
+	if err != nil {
+		return shim.Error(`{"status": 403 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if fingerprint != kyc.IdentityFingerprint {
+		return shim.Error(`{"status": 403 , "message": "Identity of signatory not Match"}`)
+	}
+	signerID, err := cid.GetID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	for _, signature := range transaction.Signatures {
+		if signature.CustID == args[1] {
+			return shim.Error(`{"status": 403 , "message": "Signatory has already signed"}`)
+		}
+	}
+	transaction.Signatures = append(transaction.Signatures, TransferSignature{
+		CustID:   args[1],
+		SignerID: signerID,
+		SignedAt: now.Format(time.RFC3339),
+	})
+	if len(transaction.Signatures) >= transaction.RequiredSignatures {
+		transaction.TransactionStatus = transaction.PendingStatus
+		transaction.PendingStatus = ""
+	}
+	err = putTransaction(stub, transaction)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	err = stub.SetEvent("evtsender", []byte(transaction.TransactionID))
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
+}
+
+//==================================================================
+//setSignatureTimeout: this func will configure network wide hours a
+//transfer can wait for signatures before it expires , admin user only
+//args[0]: hours
+//==================================================================
+func (b *Bank) setSignatureTimeout(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 1 Argument"}`)
+	}
+	userType, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok || strings.ToLower(userType) != "admin" {
+		return shim.Error(`{"status": 403 , "message": "Only admin user can set signature timeout"}`)
+	}
+	hours, err := strconv.Atoi(args[0])
+	if err != nil || hours <= 0 {
+		return shim.Error(`{"status": 500 , "message": "Can Not Convert Hours to Number"}`)
+	}
+	key, err := stub.CreateCompositeKey(configKeyType, []string{signatureTimeoutConfig})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	err = stub.PutState(key, []byte(strconv.Itoa(hours)))
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status":200 , "message":"Data Updated"}`))
+}
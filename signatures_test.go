@@ -0,0 +1,18 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetSignatureTimeoutAdminOnly(t *testing.T) {
+	l := newTestLedger(t)
+	l.mustBeAdminOnly(l.bank.setSignatureTimeout, "12")
+	timeout, err := getSignatureTimeout(l.stub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timeout != 12*time.Hour {
+		t.Fatalf("signature timeout is %v , want 12h", timeout)
+	}
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//=============================================================================
+// Standing instructions are transfers customer asked bank to repeat. Scheduler
+// identity (userType scheduler) calls executeDueInstructions , every due
+// instruction goes through transferInitiate like a transfer entered at branch
+// and outcome of run is kept on instruction
+//=============================================================================
+const (
+	standingInstructionKeyType = "standingInstruction"
+	schedulerUserType          = "scheduler"
+	defaultInstructionBatch    = 50
+)
+
+//===============================================================
+// Frequency : how often standing instruction is executed
+//===============================================================
+type Frequency string
+
+const (
+	DAILY   Frequency = "daily"
+	WEEKLY  Frequency = "weekly"
+	MONTHLY Frequency = "monthly"
+	YEARLY  Frequency = "yearly"
+)
+
+//===============================================================
+// InstructionStatus : status of standing instruction
+//===============================================================
+type InstructionStatus string
+
+const (
+	INSTRUCTIONACTIVE    InstructionStatus = "active"
+	INSTRUCTIONCANCELLED InstructionStatus = "cancelled"
+	INSTRUCTIONCOMPLETED InstructionStatus = "completed"
+)
+
+//==============================================================
+//InstructionRun : outcome of one execution of instruction
+//==============================================================
+type InstructionRun struct {
+	RunDate       string `json:"run_date"`
+	ExecutedAt    string `json:"executed_at"`
+	TransactionID string `json:"transaction_id,omitempty"`
+	Success       bool   `json:"success"`
+	Message       string `json:"message"`
+}
+
+//=====================================================================
+//StandingInstruction : recurring transfer from account of customer
+//=====================================================================
+type StandingInstruction struct {
+	ObjectType        string            `json:"doc_type"`
+	InstructionID     string            `json:"instruction_id"`
+	MSPID             string            `json:"msp_id"`
+	SenderAccount     string            `json:"sender_account"`
+	ReceiverAccount   string            `json:"receiver_account"`
+	ReceiverName      string            `json:"receiver_name,omitempty"`
+	Amount            float64           `json:"amount"`
+	Purpose           string            `json:"purpose"`
+	VerifiedBy        string            `json:"verified_by"`
+	VerifiedCustomers []string          `json:"verified_customers"`
+	Reference         string            `json:"reference"`
+	Currency          string            `json:"currency,omitempty"`
+	ChargeBearer      ChargeBearer      `json:"charge_bearer"`
+	Frequency         Frequency         `json:"frequency"`
+	StartDate         string            `json:"start_date"`
+	EndDate           string            `json:"end_date,omitempty"`
+	NextExecutionDate string            `json:"next_execution_date"`
+	Status            InstructionStatus `json:"status"`
+	Runs              []InstructionRun  `json:"runs"`
+	CreatedBy         string            `json:"created_by"`
+	CreatedAt         string            `json:"created_at"`
+}
+
+//=====================================================================================
+//runDate: this func will return date of nth run counted from start date. Monthly and
+//yearly runs keep day of start date and fall on last day of a shorter month
+//=====================================================================================
+func (f Frequency) runDate(start time.Time, n int) time.Time {
+	switch f {
+	case DAILY:
+		return start.AddDate(0, 0, n)
+	case WEEKLY:
+		return start.AddDate(0, 0, 7*n)
+	}
+	months := n
+	if f == YEARLY {
+		months = 12 * n
+	}
+	first := time.Date(start.Year(), start.Month()+time.Month(months), 1, 0, 0, 0, 0, start.Location())
+	day := start.Day()
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, start.Location())
+}
+
+//=====================================================================
+//nextDate: this func will return first run after date , runs are
+//counted from start date so they do not drift after a short month
+//=====================================================================
+func (f Frequency) nextDate(start time.Time, date time.Time) time.Time {
+	n := 1
+	for !f.runDate(start, n).After(date) {
+		n++
+	}
+	return f.runDate(start, n)
+}
+
+//==========================================================================
+//idempotencyKey: this func will return idempotency key of next run ,
+//instruction id and run date make key so a run is never doubled
+//==========================================================================
+func (s StandingInstruction) idempotencyKey() string {
+	return s.InstructionID + "/" + s.NextExecutionDate
+}
+
+//==========================================================================
+//transferArgs: this func will return arguments of transferInitiate for run.
+//Customers verified at creation are passed without hashes so they can sign
+//==========================================================================
+func (s StandingInstruction) transferArgs(now time.Time) []string {
+	customers := []map[string]string{}
+	for _, custID := range s.VerifiedCustomers {
+		customers = append(customers, map[string]string{"CustID": custID})
+	}
+	verification, _ := json.Marshal(map[string]interface{}{"Customers": customers})
+	return []string{
+		s.SenderAccount,
+		s.ReceiverAccount,
+		strconv.FormatFloat(s.Amount, 'f', -1, 64),
+		s.Purpose,
+		string(verification),
+		s.Reference,
+		"",
+		now.Format(time.RFC3339),
+		"",
+		"",
+		"",
+		s.Currency,
+		string(s.ChargeBearer),
+		s.idempotencyKey(),
+		s.ReceiverName,
+	}
+}
+
+//===========================================================================
+//putStandingInstruction: this func will write standing instruction
+//===========================================================================
+func putStandingInstruction(stub shim.ChaincodeStubInterface, instruction StandingInstruction) error {
+	key, err := stub.CreateCompositeKey(standingInstructionKeyType, []string{instruction.InstructionID})
+	if err != nil {
+		return err
+	}
+	asBytes, _ := json.Marshal(instruction)
+	return stub.PutState(key, asBytes)
+}
+
+//=====================================================================================
+//createStandingInstruction: this func will add recurring transfer from account of
+//caller bank. Customers are verified once here , only their ids and identity that
+//verified them are kept on instruction
+//args[0]: sender account number
+//args[1]: receiver account number
+//args[2]: amount
+//args[3]: purpose
+//args[4]: verification json , same as transferInitiate
+//args[5]: reference
+//args[6]: frequency (daily , weekly , monthly , yearly)
+//args[7]: start date (YYYY-MM-DD)
+//args[8]: end date (YYYY-MM-DD) , empty for no end
+//args[9]: (optional) currency
+//args[10]: (optional) charge bearer (OUR , SHA , BEN)
+//args[11]: (optional) receiver name for confirmation of payee
+//=====================================================================================
+func (b *Bank) createStandingInstruction(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) < 9 || len(args) > 12 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide 9 Arguments , optional Currency , Charge Bearer and Receiver Name"}`)
+	}
+	_, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok {
+		return shim.Error(`{"status": 403 , "message":"Client has no attribute UserType"}`)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	found, kyc := getKycOfBankAccount(stub, args[0])
+	if !found {
+		return shim.Error(`{"status": 404 , "message": "Sender Account not found"}`)
+	}
+	senderAccount, err := resolveAccount(stub, kyc.Accounts[args[0]])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if bankOfBranch(senderAccount.BranchCode) != mspID {
+		return shim.Error(`{"status": 403 , "message": "Sender Account does not belong to ` + mspID + `"}`)
+	}
+	var accountsForVerification map[string]interface{}
+	if err := json.Unmarshal([]byte(args[4]), &accountsForVerification); err != nil || accountsForVerification["Customers"] == nil {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Customers to verify"}`)
+	}
+	category := senderAccount.category()
+	if isOk, customerArray := verifyHash(stub, accountsForVerification, category, true); !isOk {
+		return shim.Error(`{"status": 403 , "message": "` + strings.Join(customerArray, ",") + `"}`)
+	}
+	if category == BUSINESSCATEGORY && senderAccount.BusinessHash != accountsForVerification["BusinessHash"] {
+		return shim.Error(`{"status": 403 , "message": "Business Hash Not Match"}`)
+	}
+	verified := []string{}
+	customers, _ := accountsForVerification["Customers"].([]interface{})
+	for _, value := range customers {
+		customer, _ := value.(map[string]interface{})
+		custID, _ := customer["CustID"].(string)
+		verified = appendUnique(verified, custID)
+	}
+	if isExist, _ := b.IsAccountExists(stub, args[1]); !isExist {
+		return shim.Error(`{"status": 404 , "message": "Receiver Account not found"}`)
+	}
+	amount, err := strconv.ParseFloat(args[2], 64)
+	if err != nil || amount <= 0 {
+		return shim.Error(`{"status": 500 , "message": "Can Not Convert Amount to Number"}`)
+	}
+	frequency := Frequency(strings.ToLower(args[6]))
+	if frequency != DAILY && frequency != WEEKLY && frequency != MONTHLY && frequency != YEARLY {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Frequency (daily , weekly , monthly , yearly)"}`)
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	start, err := time.Parse(businessDayFormat, args[7])
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Start Date as YYYY-MM-DD"}`)
+	}
+	if start.Format(businessDayFormat) < now.Format(businessDayFormat) {
+		return shim.Error(`{"status": 500 , "message": "Start Date is in past"}`)
+	}
+	if args[8] != "" {
+		end, err := time.Parse(businessDayFormat, args[8])
+		if err != nil || end.Before(start) {
+			return shim.Error(`{"status": 500 , "message": "Please Provide End Date as YYYY-MM-DD on or after Start Date"}`)
+		}
+	}
+	instruction := StandingInstruction{
+		ObjectType:        standingInstructionKeyType,
+		InstructionID:     stub.GetTxID(),
+		MSPID:             mspID,
+		SenderAccount:     args[0],
+		ReceiverAccount:   args[1],
+		Amount:            amount,
+		Purpose:           args[3],
+		VerifiedCustomers: verified,
+		Reference:         args[5],
+		ChargeBearer:      SHA,
+		Frequency:         frequency,
+		StartDate:         args[7],
+		EndDate:           args[8],
+		NextExecutionDate: args[7],
+		Status:            INSTRUCTIONACTIVE,
+		Runs:              []InstructionRun{},
+	}
+	if len(args) > 9 {
+		instruction.Currency = strings.ToUpper(args[9])
+	}
+	if len(args) > 10 {
+		if instruction.ChargeBearer, err = parseChargeBearer(args[10]); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+	}
+	if len(args) > 11 {
+		instruction.ReceiverName = strings.TrimSpace(args[11])
+	}
+	id, _ := cid.GetID(stub)
+	instruction.CreatedBy = mspID + "_" + id
+	instruction.CreatedAt = now.Format(time.RFC3339)
+	instruction.VerifiedBy = instruction.CreatedBy
+	if err := putStandingInstruction(stub, instruction); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status": 200 , "message": "Data Updated" , "data": "` + instruction.InstructionID + `"}`))
+}
+
+//=====================================================================
+//cancelStandingInstruction: this func will stop standing instruction
+//args[0]: instruction id
+//=====================================================================
+func (b *Bank) cancelStandingInstruction(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Instruction ID"}`)
+	}
+	_, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok {
+		return shim.Error(`{"status": 403 , "message":"Client has no attribute UserType"}`)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	key, err := stub.CreateCompositeKey(standingInstructionKeyType, []string{args[0]})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	asBytes, err := stub.GetState(key)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	instruction := StandingInstruction{}
+	json.Unmarshal(asBytes, &instruction)
+	if instruction.InstructionID == "" || instruction.MSPID != mspID {
+		return shim.Error(`{"status": 404 , "message": "Standing Instruction Not Found"}`)
+	}
+	if instruction.Status != INSTRUCTIONACTIVE {
+		return shim.Error(`{"status": 403 , "message": "Standing Instruction is ` + string(instruction.Status) + `"}`)
+	}
+	instruction.Status = INSTRUCTIONCANCELLED
+	if err := putStandingInstruction(stub, instruction); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status": 200 , "message": "Data Updated"}`))
+}
+
+//=====================================================================
+//getStandingInstructions: this func will return standing instructions
+//of caller bank
+//args[0]: (optional) sender account number
+//=====================================================================
+func (b *Bank) getStandingInstructions(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) > 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide optional Account Number"}`)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(standingInstructionKeyType, []string{})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	defer resultsIterator.Close()
+
+	result := []StandingInstruction{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		instruction := StandingInstruction{}
+		json.Unmarshal(queryResponse.Value, &instruction)
+		if instruction.MSPID != mspID && !isRegulator(stub) {
+			continue
+		}
+		if len(args) == 1 && args[0] != "" && instruction.SenderAccount != args[0] {
+			continue
+		}
+		result = append(result, instruction)
+	}
+	asBytes, _ := json.Marshal(result)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
+
+//=====================================================================================
+//executeDueInstructions: this func will run standing instructions of caller bank due
+//on or before today. Each run creates transfer through initiateTransfer , a refused
+//run writes nothing but is recorded and instruction moves on to next date
+//args[0]: (optional) max instructions to run , default 50
+//=====================================================================================
+func (b *Bank) executeDueInstructions(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) > 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide optional Batch Size"}`)
+	}
+	userType, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok || strings.ToLower(userType) != schedulerUserType {
+		return shim.Error(`{"status": 403 , "message": "Only scheduler can execute standing instructions"}`)
+	}
+	batch := defaultInstructionBatch
+	if len(args) == 1 {
+		if batch, err = strconv.Atoi(args[0]); err != nil || batch <= 0 {
+			return shim.Error(`{"status": 500 , "message": "Please Provide valid Batch Size"}`)
+		}
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	today := now.Format(businessDayFormat)
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(standingInstructionKeyType, []string{})
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	due := []StandingInstruction{}
+	for resultsIterator.HasNext() && len(due) < batch {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			resultsIterator.Close()
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		instruction := StandingInstruction{}
+		json.Unmarshal(queryResponse.Value, &instruction)
+		if instruction.MSPID == mspID && instruction.Status == INSTRUCTIONACTIVE && instruction.NextExecutionDate <= today {
+			due = append(due, instruction)
+		}
+	}
+	resultsIterator.Close()
+
+	runs := []InstructionRun{}
+	ctr := newCtrBatch()
+	for _, instruction := range due {
+		run := InstructionRun{
+			RunDate:    instruction.NextExecutionDate,
+			ExecutedAt: now.Format(time.RFC3339),
+		}
+		childID := stub.GetTxID() + "-" + strconv.Itoa(len(runs))
+		response, txID, err := b.initiateTransfer(stub, instruction.transferArgs(now), transferOptions{TxID: childID, Scheduled: true, Ctr: ctr})
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		if response.Status == shim.OK {
+			run.Success = true
+			run.TransactionID = txID
+			run.Message = "Transfer Initiated"
+			if txID != childID {
+				// run date was already executed , run points to its transfer
+				run.Message = "Transfer already Initiated"
+			}
+		} else {
+			run.Message = response.Message
+		}
+		instruction.Runs = append(instruction.Runs, run)
+		start, _ := time.Parse(businessDayFormat, instruction.StartDate)
+		date, _ := time.Parse(businessDayFormat, instruction.NextExecutionDate)
+		instruction.NextExecutionDate = instruction.Frequency.nextDate(start, date).Format(businessDayFormat)
+		if instruction.EndDate != "" && instruction.NextExecutionDate > instruction.EndDate {
+			instruction.Status = INSTRUCTIONCOMPLETED
+		}
+		if err := putStandingInstruction(stub, instruction); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		runs = append(runs, run)
+	}
+	if err := ctr.raiseEvent(stub); err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	asBytes, _ := json.Marshal(runs)
+	return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+//=======================================================================
+// regulatorMSPID : MSP of the regulator organisation
+// strCollection : private data collection shared with regulator only ,
+// see collections_config.json
+//=======================================================================
+const (
+	regulatorMSPID = "RegulatorMSP"
+	strCollection  = "strRegulatorCollection"
+)
+
+//=====================================================================
+//STRTransaction : snapshot of transaction reported in STR
+//=====================================================================
+type STRTransaction struct {
+	Transaction
+	SenderCustID   string `json:"sender_cust_id"`
+	ReceiverCustID string `json:"receiver_cust_id"`
+}
+
+//=========================================================================
+//SuspiciousTransactionReport : this struct store STR filed by compliance
+//=========================================================================
+type SuspiciousTransactionReport struct {
+	ObjectType   string           `json:"doc_type"`
+	ReportID     string           `json:"report_id"`
+	ReportingMSP string           `json:"reporting_msp"`
+	ReportedBy   string           `json:"reported_by"`
+	Created      string           `json:"created"`
+	Narrative    string           `json:"narrative"`
+	Indicators   []string         `json:"indicators"`
+	Customers    []string         `json:"customers"`
+	Accounts     []string         `json:"accounts"`
+	Transactions []STRTransaction `json:"transactions"`
+}
+
+//==================================================================
+//strRequest : STR input passed in transient map under key "str" so
+//narrative never lands on the channel ledger
+//==================================================================
+type strRequest struct {
+	TransactionIDs []string `json:"TransactionIDs"`
+	Narrative      string   `json:"Narrative"`
+}
+
+//==========================================================================
+//appendUnique: this func will add value to list if it is not already there
+//==========================================================================
+func appendUnique(list []string, value string) []string {
+	if value == "" {
+		return list
+	}
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
+
+//=============================================================================
+//createSuspiciousTransactionReport: this func will build STR from transactions
+//and store it in regulator private collection. Only compliance users can file STR
+//transient["str"]: {"TransactionIDs": [...], "Narrative": "..."}
+//=============================================================================
+func (b *Bank) createSuspiciousTransactionReport(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 0 {
+		return shim.Error(`{"status": 500 , "message": "STR must be passed in transient field str"}`)
+	}
+	userType, ok, err := cid.GetAttributeValue(stub, "userType")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	if !ok || strings.ToLower(userType) != "compliance" {
+		return shim.Error(`{"status": 403 , "message": "Only compliance user can file STR"}`)
+	}
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	request := strRequest{}
+	if err := json.Unmarshal(transient["str"], &request); err != nil {
+		return shim.Error(`{"status": 500 , "message": "Please check transient field str"}`)
+	}
+	if len(request.TransactionIDs) == 0 || request.Narrative == "" {
+		return shim.Error(`{"status": 500 , "message": "Please Provide Transaction IDs and Narrative"}`)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	id, err := cid.GetID(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+
+	report := SuspiciousTransactionReport{
+		ObjectType:   "str",
+		ReportID:     stub.GetTxID(),
+		ReportingMSP: mspID,
+		ReportedBy:   id,
+		Created:      now.Format(time.RFC3339),
+		Narrative:    request.Narrative,
+	}
+	for _, txID := range request.TransactionIDs {
+		asBytes, err := getTransactionState(stub, txID)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		transaction := Transaction{}
+		json.Unmarshal(asBytes, &transaction)
+		if transaction.ObjectType != "transaction" {
+			return shim.Error(`{"status": 404 , "message": "Transaction not found ` + txID + `"}`)
+		}
+		// only the bank on either side of the transfer can report it
+		if !strings.HasPrefix(transaction.SenderBranchName, mspID+"_") && !strings.HasPrefix(transaction.ReceiverBranchName, mspID+"_") {
+			return shim.Error(`{"status": 403 , "message": "Transaction ` + txID + ` does not belong to your bank"}`)
+		}
+		_, senderCustID := b.IsAccountExists(stub, transaction.SenderAccount)
+		_, receiverCustID := b.IsAccountExists(stub, transaction.ReceiverAccount)
+		report.Transactions = append(report.Transactions, STRTransaction{
+			Transaction:    transaction,
+			SenderCustID:   senderCustID,
+			ReceiverCustID: receiverCustID,
+		})
+		report.Customers = appendUnique(report.Customers, senderCustID)
+		report.Customers = appendUnique(report.Customers, receiverCustID)
+		report.Accounts = appendUnique(report.Accounts, transaction.SenderAccount)
+		report.Accounts = appendUnique(report.Accounts, transaction.ReceiverAccount)
+		if transaction.Flagged {
+			report.Indicators = appendUnique(report.Indicators, "flagged")
+		}
+		if isCrimeRelated(transaction.Crimerelated) {
+			report.Indicators = appendUnique(report.Indicators, "crime related")
+		}
+		if transaction.Riskrating == HIGH {
+			report.Indicators = appendUnique(report.Indicators, "high risk rating")
+		}
+	}
+
+	asBytes, _ := json.Marshal(report)
+	err = stub.PutPrivateData(strCollection, report.ReportID, asBytes)
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	// customers of reporting bank in STR get their risk recomputed from transfer history
+	for _, ref := range report.Customers {
+		home, _, err := customerRef(stub, ref)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		if home != mspID {
+			continue
+		}
+		kycAsBytes, err := getKycState(stub, ref)
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		kyc := Kyc{}
+		json.Unmarshal(kycAsBytes, &kyc)
+		if kyc.CustID == "" {
+			continue
+		}
+		if err := computeCustomerRiskScore(stub, &kyc); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		if err := putKyc(stub, kyc); err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+	}
+	err = stub.SetEvent("evtstr", []byte(report.ReportID))
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	return shim.Success([]byte(`{"status": 200 , "data": "` + report.ReportID + `"}`))
+}
+
+//==========================================================================
+//getSuspiciousTransactionReport: this func will return STR to regulator
+//args[0]: report id , without argument all STRs are returned
+//==========================================================================
+func (b *Bank) getSuspiciousTransactionReport(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) > 1 {
+		return shim.Error(`{"status": 500 , "message": "Please Provide report id or no argument"}`)
+	}
+	if !isRegulator(stub) {
+		return shim.Error(`{"status": 403 , "message": "Only regulator can read STR"}`)
+	}
+	if len(args) == 1 {
+		asBytes, err := stub.GetPrivateData(strCollection, args[0])
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		if asBytes == nil {
+			return shim.Error(`{"status": 404 , "message": "STR not found"}`)
+		}
+		return shim.Success([]byte(`{"status": 200 , "data": ` + string(asBytes) + `}`))
+	}
+
+	resultsIterator, err := stub.GetPrivateDataByRange(strCollection, "", "")
+	if err != nil {
+		return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+	}
+	defer resultsIterator.Close()
+
+	var buffer bytes.Buffer
+	buffer.WriteString("[")
+	var first = true
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(`{"status": 500 , "message": "` + string(err.Error()) + `"}`)
+		}
+		if first == false {
+			buffer.WriteString(",")
+		}
+		first = false
+		buffer.WriteString(string(queryResponse.Value))
+	}
+	buffer.WriteString("]")
+
+	if len(buffer.Bytes()) > 2 {
+		return shim.Success([]byte(`{"status": 200 , "data": ` + string(buffer.Bytes()) + `}`))
+	}
+	return shim.Success([]byte(`{"status": 200 , "data": "No STR Found"}`))
+}